@@ -0,0 +1,395 @@
+// Package cache keeps recently read users in memory in front of a user repository.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+	"github.com/torwig/user-service/log"
+	"github.com/torwig/user-service/service"
+)
+
+const (
+	DefaultTTL         = time.Minute
+	DefaultNegativeTTL = 5 * time.Second
+	// DefaultPrimaryReadWindow matches the default read-your-writes window of the repository.
+	DefaultPrimaryReadWindow = 5 * time.Second
+)
+
+type Config struct {
+	// Size is the maximum number of cached users, the cache is disabled if it's 0.
+	Size int
+	// TTL is how long a user is served from the cache.
+	TTL time.Duration
+	// NegativeTTL is how long a missing user is remembered, usually shorter than TTL.
+	NegativeTTL time.Duration
+	// ListenNotify spreads invalidations to the other replicas through the database.
+	ListenNotify bool
+	// PrimaryReadWindow is how long after an invalidation the user is read from the primary database,
+	// so that a lagging read replica doesn't fill the cache with the old user. It should exceed the replication lag.
+	PrimaryReadWindow time.Duration
+}
+
+func (c Config) Enabled() bool {
+	return c.Size > 0
+}
+
+func (c Config) withDefaults() Config {
+	if c.TTL == 0 {
+		c.TTL = DefaultTTL
+	}
+	if c.NegativeTTL == 0 {
+		c.NegativeTTL = DefaultNegativeTTL
+	}
+	if c.PrimaryReadWindow == 0 {
+		c.PrimaryReadWindow = DefaultPrimaryReadWindow
+	}
+
+	return c
+}
+
+// InvalidationPublisher tells the other replicas that a user has changed.
+type InvalidationPublisher interface {
+	PublishUserInvalidation(ctx context.Context, id int64) error
+}
+
+type entry struct {
+	id        int64
+	user      entities.User
+	found     bool
+	expiresAt time.Time
+}
+
+// UserRepository is a read-through cache of Get in front of another repository.
+// Changes made through it invalidate the changed user. Not found users are cached too,
+// so that repeated lookups of a missing ID don't reach the database.
+type UserRepository struct {
+	next      service.UserRepository
+	cfg       Config
+	publisher InvalidationPublisher
+	now       func() time.Time
+
+	mu      sync.Mutex
+	entries map[int64]*list.Element
+	// recency keeps the most recently used entries in the front.
+	recency *list.List
+	// generation grows with every invalidation, so that a user read before it isn't cached after it.
+	generation uint64
+	// primaryReadsUntil tells until when the recently invalidated users are read from the primary database,
+	// the zero ID stands for all the users after a purge.
+	primaryReadsUntil map[int64]time.Time
+}
+
+type Option func(r *UserRepository)
+
+// WithInvalidationPublisher publishes every local invalidation, e.g. to the other replicas.
+func WithInvalidationPublisher(p InvalidationPublisher) Option {
+	return func(r *UserRepository) {
+		r.publisher = p
+	}
+}
+
+func NewUserRepository(next service.UserRepository, cfg Config, options ...Option) *UserRepository {
+	r := &UserRepository{
+		next:    next,
+		cfg:     cfg.withDefaults(),
+		now:     time.Now,
+		entries: make(map[int64]*list.Element),
+		recency: list.New(),
+
+		primaryReadsUntil: make(map[int64]time.Time),
+	}
+
+	for _, o := range options {
+		o(r)
+	}
+
+	return r
+}
+
+func (r *UserRepository) Get(ctx context.Context, id int64) (entities.User, error) {
+	if e, ok := r.lookup(id); ok {
+		if !e.found {
+			return entities.User{}, entities.ErrUserNotFound
+		}
+
+		return e.user, nil
+	}
+
+	generation, primaryRead := r.currentGeneration(id)
+	if primaryRead {
+		// a replica may not have caught up with the change yet
+		ctx = entities.ContextWithPrimaryRead(ctx)
+	}
+
+	user, err := r.next.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, entities.ErrUserNotFound) {
+			r.store(generation, entry{id: id, expiresAt: r.now().Add(r.cfg.NegativeTTL)})
+		}
+
+		return user, err
+	}
+
+	r.store(generation, entry{id: id, user: user, found: true, expiresAt: r.now().Add(r.cfg.TTL)})
+
+	return user, nil
+}
+
+// GetByExternalID isn't cached as the entries are kept by the ID of the user only.
+func (r *UserRepository) GetByExternalID(ctx context.Context, source, externalID string) (entities.User, error) {
+	return r.next.GetByExternalID(ctx, source, externalID)
+}
+
+func (r *UserRepository) Create(ctx context.Context, params entities.CreateUserParams) (entities.User, error) {
+	user, err := r.next.Create(ctx, params)
+	if err != nil {
+		return user, err
+	}
+
+	// the ID may have been looked up (and remembered as missing) before the user was created
+	r.invalidate(ctx, user.ID)
+
+	return user, nil
+}
+
+func (r *UserRepository) Update(
+	ctx context.Context,
+	id int64,
+	params entities.UpdateUserParams,
+) (entities.User, error) {
+	user, err := r.next.Update(ctx, id, params)
+	if err != nil {
+		return user, err
+	}
+
+	r.invalidate(ctx, id)
+
+	return user, nil
+}
+
+func (r *UserRepository) Replace(
+	ctx context.Context,
+	id int64,
+	params entities.ReplaceUserParams,
+) (entities.User, error) {
+	user, err := r.next.Replace(ctx, id, params)
+	if err != nil {
+		return user, err
+	}
+
+	r.invalidate(ctx, id)
+
+	return user, nil
+}
+
+func (r *UserRepository) Upsert(
+	ctx context.Context,
+	externalID entities.ExternalID,
+	params entities.ReplaceUserParams,
+) (entities.User, bool, error) {
+	user, created, err := r.next.Upsert(ctx, externalID, params)
+	if err != nil {
+		return user, created, err
+	}
+
+	// a created user may have been remembered as missing
+	r.invalidate(ctx, user.ID)
+
+	return user, created, nil
+}
+
+func (r *UserRepository) Delete(ctx context.Context, id int64, cause entities.StatusChangeCause) error {
+	if err := r.next.Delete(ctx, id, cause); err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, id)
+
+	return nil
+}
+
+func (r *UserRepository) Restore(
+	ctx context.Context,
+	id int64,
+	cause entities.StatusChangeCause,
+) (entities.User, error) {
+	user, err := r.next.Restore(ctx, id, cause)
+	if err != nil {
+		return user, err
+	}
+
+	r.invalidate(ctx, id)
+
+	return user, nil
+}
+
+func (r *UserRepository) VerifyEmail(ctx context.Context, id int64, email string) (entities.User, error) {
+	user, err := r.next.VerifyEmail(ctx, id, email)
+	if err != nil {
+		return user, err
+	}
+
+	r.invalidate(ctx, id)
+
+	return user, nil
+}
+
+func (r *UserRepository) VerifyPhone(ctx context.Context, id int64, phoneNumber string) (entities.User, error) {
+	user, err := r.next.VerifyPhone(ctx, id, phoneNumber)
+	if err != nil {
+		return user, err
+	}
+
+	r.invalidate(ctx, id)
+
+	return user, nil
+}
+
+func (r *UserRepository) ChangeStatus(
+	ctx context.Context,
+	id int64,
+	from entities.UserStatus,
+	params entities.ChangeUserStatusParams,
+) (entities.User, error) {
+	user, err := r.next.ChangeStatus(ctx, id, from, params)
+	if err != nil {
+		return user, err
+	}
+
+	r.invalidate(ctx, id)
+
+	return user, nil
+}
+
+func (r *UserRepository) ListStatusChanges(ctx context.Context, userID int64) ([]entities.UserStatusChange, error) {
+	return r.next.ListStatusChanges(ctx, userID)
+}
+
+// List isn't cached as pages change with every new user.
+func (r *UserRepository) List(ctx context.Context, params entities.ListUsersParams) ([]entities.User, error) {
+	return r.next.List(ctx, params)
+}
+
+// Invalidate forgets the cached user, e.g. after another replica has changed it.
+// For a while the user is read from the primary database.
+func (r *UserRepository) Invalidate(id int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.generation++
+	r.readFromPrimary(id)
+
+	if elem, ok := r.entries[id]; ok {
+		r.remove(elem)
+	}
+}
+
+// Purge forgets all the cached users, e.g. when invalidations from the other replicas may have been missed.
+func (r *UserRepository) Purge() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.generation++
+	r.entries = make(map[int64]*list.Element)
+	r.recency.Init()
+	r.readFromPrimary(0)
+}
+
+// Len returns the number of cached users.
+func (r *UserRepository) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.recency.Len()
+}
+
+func (r *UserRepository) invalidate(ctx context.Context, id int64) {
+	r.Invalidate(id)
+
+	if r.publisher == nil {
+		return
+	}
+
+	// the change has been made already, the other replicas catch up when their entries expire
+	if err := r.publisher.PublishUserInvalidation(ctx, id); err != nil {
+		log.FromContext(ctx).Warnf("failed to publish invalidation of user %d: %s", id, err)
+	}
+}
+
+func (r *UserRepository) lookup(id int64) (entry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	elem, ok := r.entries[id]
+	if !ok {
+		return entry{}, false
+	}
+
+	e := elem.Value.(entry)
+	if !r.now().Before(e.expiresAt) {
+		r.remove(elem)
+
+		return entry{}, false
+	}
+
+	r.recency.MoveToFront(elem)
+
+	return e, true
+}
+
+// currentGeneration also reports whether the user has to be read from the primary database.
+func (r *UserRepository) currentGeneration(id int64) (uint64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+
+	return r.generation, now.Before(r.primaryReadsUntil[id]) || now.Before(r.primaryReadsUntil[0])
+}
+
+func (r *UserRepository) readFromPrimary(id int64) {
+	now := r.now()
+
+	if len(r.primaryReadsUntil) >= r.cfg.Size {
+		for id, until := range r.primaryReadsUntil {
+			if !now.Before(until) {
+				delete(r.primaryReadsUntil, id)
+			}
+		}
+	}
+
+	r.primaryReadsUntil[id] = now.Add(r.cfg.PrimaryReadWindow)
+}
+
+// store caches the entry unless something was invalidated since the entry was read.
+func (r *UserRepository) store(generation uint64, e entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if generation != r.generation {
+		return
+	}
+
+	if elem, ok := r.entries[e.id]; ok {
+		elem.Value = e
+		r.recency.MoveToFront(elem)
+
+		return
+	}
+
+	r.entries[e.id] = r.recency.PushFront(e)
+
+	if r.recency.Len() > r.cfg.Size {
+		r.remove(r.recency.Back())
+	}
+}
+
+func (r *UserRepository) remove(elem *list.Element) {
+	r.recency.Remove(elem)
+	delete(r.entries, elem.Value.(entry).id)
+}
@@ -0,0 +1,136 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torwig/user-service/adapters/cache"
+	"github.com/torwig/user-service/adapters/repository"
+	"github.com/torwig/user-service/adapters/repository/repositorytest"
+	"github.com/torwig/user-service/entities"
+)
+
+// countingRepository counts the lookups that missed the cache and those of them that went to the primary.
+type countingRepository struct {
+	*repository.InMemoryRepository
+	gets        int
+	primaryGets int
+}
+
+func (r *countingRepository) Get(ctx context.Context, id int64) (entities.User, error) {
+	r.gets++
+	if entities.IsPrimaryRead(ctx) {
+		r.primaryGets++
+	}
+
+	return r.InMemoryRepository.Get(ctx, id)
+}
+
+type recordingPublisher struct {
+	ids []int64
+}
+
+func (p *recordingPublisher) PublishUserInvalidation(_ context.Context, id int64) error {
+	p.ids = append(p.ids, id)
+
+	return nil
+}
+
+func TestUserRepository_Conformance(t *testing.T) {
+	repositorytest.TestUserRepository(t,
+		cache.NewUserRepository(repository.NewInMemoryRepository(), cache.Config{Size: 100}))
+}
+
+func TestUserRepository_Get(t *testing.T) {
+	ctx := context.Background()
+	next := &countingRepository{InMemoryRepository: repository.NewInMemoryRepository()}
+	publisher := &recordingPublisher{}
+	repo := cache.NewUserRepository(next, cache.Config{Size: 2}, cache.WithInvalidationPublisher(publisher))
+
+	user, err := repo.Create(ctx, entities.CreateUserParams{FirstName: "Ann"})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err = repo.Get(ctx, user.ID)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 1, next.gets, "user must be read from the cache")
+
+	firstName := "Bob"
+	_, err = repo.Update(ctx, user.ID, entities.UpdateUserParams{FirstName: &firstName})
+	require.NoError(t, err)
+
+	updatedUser, err := repo.Get(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, firstName, updatedUser.FirstName, "update must invalidate the cached user")
+	assert.Equal(t, 2, next.gets)
+	assert.Equal(t, []int64{user.ID, user.ID}, publisher.ids)
+
+	for i := 0; i < 2; i++ {
+		_, err = repo.Get(ctx, 1000)
+		require.ErrorIs(t, err, entities.ErrUserNotFound)
+	}
+
+	assert.Equal(t, 3, next.gets, "missing user must be remembered")
+
+	_, err = repo.Get(ctx, 1001)
+	require.ErrorIs(t, err, entities.ErrUserNotFound)
+	assert.Equal(t, 2, repo.Len(), "least recently used user must be evicted")
+
+	repo.Invalidate(1001)
+	assert.Equal(t, 1, repo.Len())
+
+	repo.Purge()
+	assert.Equal(t, 0, repo.Len())
+}
+
+func TestUserRepository_TTL(t *testing.T) {
+	ctx := context.Background()
+	next := &countingRepository{InMemoryRepository: repository.NewInMemoryRepository()}
+	repo := cache.NewUserRepository(next, cache.Config{Size: 10, TTL: time.Millisecond})
+
+	user, err := next.Create(ctx, entities.CreateUserParams{FirstName: "Ann"})
+	require.NoError(t, err)
+
+	_, err = repo.Get(ctx, user.ID)
+	require.NoError(t, err)
+
+	time.Sleep(2 * time.Millisecond)
+
+	_, err = repo.Get(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, next.gets, "expired user must be read again")
+}
+
+func TestUserRepository_PrimaryReadAfterInvalidation(t *testing.T) {
+	ctx := context.Background()
+	next := &countingRepository{InMemoryRepository: repository.NewInMemoryRepository()}
+	repo := cache.NewUserRepository(next, cache.Config{Size: 10, PrimaryReadWindow: time.Hour})
+
+	user, err := next.Create(ctx, entities.CreateUserParams{FirstName: "Ann"})
+	require.NoError(t, err)
+
+	_, err = repo.Get(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, next.primaryGets, "user may be read from a replica")
+
+	repo.Invalidate(user.ID)
+
+	_, err = repo.Get(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, next.primaryGets, "invalidated user must be read from the primary")
+
+	_, err = repo.Get(ctx, 1000)
+	require.ErrorIs(t, err, entities.ErrUserNotFound)
+	assert.Equal(t, 1, next.primaryGets, "another user may be read from a replica")
+
+	repo.Purge()
+
+	_, err = repo.Get(ctx, 1000)
+	require.ErrorIs(t, err, entities.ErrUserNotFound)
+	assert.Equal(t, 2, next.primaryGets, "every user must be read from the primary after a purge")
+}
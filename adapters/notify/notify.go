@@ -0,0 +1,156 @@
+// Package notify delivers messages to users. Its notifiers don't reach the users themselves
+// but record the messages, for local development, tests and single-node deployments that relay them.
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/service"
+	"go.uber.org/zap"
+)
+
+const (
+	NotifierLog  = "log"
+	NotifierFile = "file"
+
+	MessageEmailVerification = "email_verification"
+	MessagePhoneVerification = "phone_verification"
+)
+
+var ErrUnknownNotifier = errors.New("unknown notifier")
+
+type Config struct {
+	// Notifier is how messages are delivered: log or file.
+	Notifier string
+	// File is where the file notifier appends the messages.
+	File string
+}
+
+// Sender delivers both emails and text messages.
+type Sender interface {
+	service.Notifier
+	service.SMSSender
+}
+
+// New returns the notifier chosen by the config.
+func New(cfg Config, logger *zap.SugaredLogger) (Sender, error) {
+	switch cfg.Notifier {
+	case NotifierLog:
+		return NewLogNotifier(logger), nil
+	case NotifierFile:
+		return NewFileNotifier(cfg.File), nil
+	default:
+		return nil, errors.Wrap(ErrUnknownNotifier, cfg.Notifier)
+	}
+}
+
+// LogNotifier writes the messages to the log, including the tokens they carry.
+type LogNotifier struct {
+	logger *zap.SugaredLogger
+}
+
+func NewLogNotifier(logger *zap.SugaredLogger) *LogNotifier {
+	return &LogNotifier{logger: logger}
+}
+
+func (n *LogNotifier) SendEmailVerification(_ context.Context, email, token string) error {
+	n.logger.Infow("sending email verification", "to", email, "token", token)
+
+	return nil
+}
+
+func (n *LogNotifier) SendPhoneVerification(_ context.Context, phoneNumber, code string) error {
+	n.logger.Infow("sending phone verification", "to", phoneNumber, "code", code)
+
+	return nil
+}
+
+// Message is a line of the file written by FileNotifier.
+type Message struct {
+	Type   string    `json:"type"`
+	To     string    `json:"to"`
+	Token  string    `json:"token,omitempty"`
+	Code   string    `json:"code,omitempty"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// FileNotifier appends the messages to a file as JSON lines, so that tests and other processes can read them.
+type FileNotifier struct {
+	mu   sync.Mutex
+	path string
+	now  func() time.Time
+}
+
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{path: path, now: time.Now}
+}
+
+func (n *FileNotifier) SendEmailVerification(_ context.Context, email, token string) error {
+	return n.append(Message{Type: MessageEmailVerification, To: email, Token: token})
+}
+
+func (n *FileNotifier) SendPhoneVerification(_ context.Context, phoneNumber, code string) error {
+	return n.append(Message{Type: MessagePhoneVerification, To: phoneNumber, Code: code})
+}
+
+func (n *FileNotifier) append(m Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	m.SentAt = n.now().UTC()
+
+	line, err := json.Marshal(m)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode message")
+	}
+
+	f, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return errors.Wrap(err, "failed to open messages file")
+	}
+
+	_, err = f.Write(append(line, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "failed to write message")
+	}
+
+	return nil
+}
+
+// ReadMessages returns the messages written to the file by FileNotifier in the order they were sent.
+func ReadMessages(path string) ([]Message, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open messages file")
+	}
+
+	defer f.Close()
+
+	var messages []Message
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var m Message
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			return nil, errors.Wrap(err, "failed to decode message")
+		}
+
+		messages = append(messages, m)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read messages file")
+	}
+
+	return messages, nil
+}
@@ -0,0 +1,214 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+)
+
+const apiKeyTableName = "api_keys"
+
+func (r *PostgresRepository) CreateAPIKey(
+	ctx context.Context,
+	params entities.StoreAPIKeyParams,
+) (entities.APIKey, error) {
+	defer r.observeQuery("create_api_key", time.Now())
+
+	var key entities.APIKey
+
+	stmt := sq.
+		Insert(apiKeyTableName).
+		Columns("user_id", "name", "prefix", "key_hash", "scopes", "expires_at").
+		Values(params.UserID, params.Name, params.Prefix, params.KeyHash, params.Scopes, utcTime(params.ExpiresAt)).
+		Suffix("RETURNING id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, " +
+			"revoked, created_at, revoked_at").
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return key, errors.Wrap(err, "failed to build a query")
+	}
+
+	err = pgxscan.Get(ctx, r.db, &key, sql, args...)
+	if err != nil {
+		return key, errors.Wrap(err, "failed to execute a query")
+	}
+
+	return key, nil
+}
+
+func (r *PostgresRepository) GetAPIKey(ctx context.Context, id int64) (entities.APIKey, error) {
+	defer r.observeQuery("get_api_key", time.Now())
+
+	return r.getAPIKey(ctx, sq.Eq{"id": id})
+}
+
+func (r *PostgresRepository) GetAPIKeyByPrefix(ctx context.Context, prefix string) (entities.APIKey, error) {
+	defer r.observeQuery("get_api_key_by_prefix", time.Now())
+
+	return r.getAPIKey(ctx, sq.Eq{"prefix": prefix})
+}
+
+func (r *PostgresRepository) getAPIKey(ctx context.Context, pred sq.Eq) (entities.APIKey, error) {
+	var key entities.APIKey
+
+	stmt := sq.
+		Select("id", "user_id", "name", "prefix", "key_hash", "scopes", "expires_at", "last_used_at",
+			"revoked", "created_at", "revoked_at").
+		From(apiKeyTableName).
+		Where(pred).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return key, errors.Wrap(err, "failed to build a query")
+	}
+
+	err = pgxscan.Get(ctx, r.db, &key, sql, args...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return key, entities.ErrAPIKeyNotFound
+		}
+
+		return key, errors.Wrap(err, "failed to execute a query")
+	}
+
+	return key, nil
+}
+
+func (r *PostgresRepository) ListAPIKeys(ctx context.Context, userID int64) ([]entities.APIKey, error) {
+	defer r.observeQuery("list_api_keys", time.Now())
+
+	var keys []entities.APIKey
+
+	stmt := sq.
+		Select("id", "user_id", "name", "prefix", "key_hash", "scopes", "expires_at", "last_used_at",
+			"revoked", "created_at", "revoked_at").
+		From(apiKeyTableName).
+		Where(sq.Eq{"user_id": userID}).
+		OrderBy("id").
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build a query")
+	}
+
+	err = pgxscan.Select(ctx, r.db, &keys, sql, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to execute a query")
+	}
+
+	return keys, nil
+}
+
+func (r *PostgresRepository) UpdateAPIKey(
+	ctx context.Context,
+	id int64,
+	params entities.UpdateAPIKeyParams,
+) (entities.APIKey, error) {
+	defer r.observeQuery("update_api_key", time.Now())
+
+	nothingToUpdate := params == entities.UpdateAPIKeyParams{}
+	if nothingToUpdate {
+		return r.GetAPIKey(ctx, id)
+	}
+
+	var key entities.APIKey
+
+	stmt := sq.
+		Update(apiKeyTableName)
+	if params.Name != nil {
+		stmt = stmt.Set("name", *params.Name)
+	}
+	if params.Scopes != nil {
+		stmt = stmt.Set("scopes", *params.Scopes)
+	}
+	if params.ExpiresAt != nil {
+		stmt = stmt.Set("expires_at", utcTime(params.ExpiresAt))
+	}
+
+	stmt = stmt.
+		Where(sq.Eq{"id": id}).
+		Suffix("RETURNING id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, " +
+			"revoked, created_at, revoked_at").
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return key, errors.Wrap(err, "failed to build a query")
+	}
+
+	err = pgxscan.Get(ctx, r.db, &key, sql, args...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return key, entities.ErrAPIKeyNotFound
+		}
+
+		return key, errors.Wrap(err, "failed to execute a query")
+	}
+
+	return key, nil
+}
+
+func (r *PostgresRepository) RevokeAPIKey(ctx context.Context, id int64) error {
+	defer r.observeQuery("revoke_api_key", time.Now())
+
+	stmt := sq.
+		Update(apiKeyTableName).
+		Set("revoked", true).
+		Set("revoked_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": id, "revoked": false}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build a query")
+	}
+
+	_, err = r.db.Exec(ctx, sql, args...)
+	if err != nil {
+		return errors.Wrap(err, "failed to execute a query")
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) TouchAPIKey(ctx context.Context, id int64, usedAt time.Time) error {
+	defer r.observeQuery("touch_api_key", time.Now())
+
+	stmt := sq.
+		Update(apiKeyTableName).
+		Set("last_used_at", usedAt.UTC()).
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build a query")
+	}
+
+	_, err = r.db.Exec(ctx, sql, args...)
+	if err != nil {
+		return errors.Wrap(err, "failed to execute a query")
+	}
+
+	return nil
+}
+
+// utcTime normalizes an optional time before it is written to a column
+// of the "timestamp" type which doesn't keep time zone information.
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	utc := t.UTC()
+
+	return &utc
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+)
+
+var errAPIKeyPrefixTaken = errors.New("API key prefix is already taken")
+
+func (r *InMemoryRepository) CreateAPIKey(_ context.Context, params entities.StoreAPIKeyParams) (entities.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// the prefix is unique, just like in the table
+	for _, key := range r.apiKeys {
+		if key.Prefix == params.Prefix {
+			return entities.APIKey{}, errAPIKeyPrefixTaken
+		}
+	}
+
+	r.lastKeyID++
+
+	scopes := params.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	key := entities.APIKey{
+		ID:        r.lastKeyID,
+		UserID:    params.UserID,
+		Name:      params.Name,
+		Prefix:    params.Prefix,
+		KeyHash:   params.KeyHash,
+		Scopes:    scopes,
+		ExpiresAt: utcTime(params.ExpiresAt),
+		CreatedAt: r.timestamp(),
+	}
+
+	r.apiKeys[key.ID] = copyAPIKey(key)
+
+	return copyAPIKey(key), nil
+}
+
+func (r *InMemoryRepository) GetAPIKey(_ context.Context, id int64) (entities.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.apiKeys[id]
+	if !ok {
+		return entities.APIKey{}, entities.ErrAPIKeyNotFound
+	}
+
+	return copyAPIKey(key), nil
+}
+
+func (r *InMemoryRepository) GetAPIKeyByPrefix(_ context.Context, prefix string) (entities.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.apiKeys {
+		if key.Prefix == prefix {
+			return copyAPIKey(key), nil
+		}
+	}
+
+	return entities.APIKey{}, entities.ErrAPIKeyNotFound
+}
+
+func (r *InMemoryRepository) ListAPIKeys(_ context.Context, userID int64) ([]entities.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var keys []entities.APIKey
+
+	for _, key := range r.apiKeys {
+		if key.UserID == userID {
+			keys = append(keys, copyAPIKey(key))
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+
+	return keys, nil
+}
+
+func (r *InMemoryRepository) UpdateAPIKey(
+	_ context.Context,
+	id int64,
+	params entities.UpdateAPIKeyParams,
+) (entities.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.apiKeys[id]
+	if !ok {
+		return entities.APIKey{}, entities.ErrAPIKeyNotFound
+	}
+
+	if params.Name != nil {
+		key.Name = *params.Name
+	}
+	if params.Scopes != nil {
+		key.Scopes = *params.Scopes
+	}
+	if params.ExpiresAt != nil {
+		key.ExpiresAt = utcTime(params.ExpiresAt)
+	}
+
+	r.apiKeys[id] = copyAPIKey(key)
+
+	return copyAPIKey(key), nil
+}
+
+func (r *InMemoryRepository) RevokeAPIKey(_ context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.apiKeys[id]
+	if !ok || key.IsRevoked() {
+		return nil
+	}
+
+	revokedAt := r.timestamp()
+	key.Revoked = true
+	key.RevokedAt = &revokedAt
+
+	r.apiKeys[id] = key
+
+	return nil
+}
+
+func (r *InMemoryRepository) TouchAPIKey(_ context.Context, id int64, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.apiKeys[id]
+	if !ok {
+		return nil
+	}
+
+	key.LastUsedAt = utcTime(&usedAt)
+
+	r.apiKeys[id] = key
+
+	return nil
+}
+
+// copyAPIKey returns a key that doesn't share memory with the stored one.
+func copyAPIKey(key entities.APIKey) entities.APIKey {
+	key.Scopes = append([]string{}, key.Scopes...)
+	key.ExpiresAt = copyTime(key.ExpiresAt)
+	key.LastUsedAt = copyTime(key.LastUsedAt)
+	key.RevokedAt = copyTime(key.RevokedAt)
+
+	return key
+}
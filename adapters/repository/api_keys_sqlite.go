@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/georgysavva/scany/v2/sqlscan"
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+)
+
+var apiKeyColumns = []string{
+	"id", "user_id", "name", "prefix", "key_hash", "scopes", "expires_at", "last_used_at",
+	"revoked", "created_at", "revoked_at",
+}
+
+// sqliteAPIKey is a row of the API keys table, where scopes are kept as a JSON array.
+type sqliteAPIKey struct {
+	ID         int64
+	UserID     int64
+	Name       string
+	Prefix     string
+	KeyHash    string
+	Scopes     string
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	Revoked    bool
+	CreatedAt  time.Time
+	RevokedAt  *time.Time
+}
+
+func (k sqliteAPIKey) toEntity() (entities.APIKey, error) {
+	key := entities.APIKey{
+		ID:         k.ID,
+		UserID:     k.UserID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		KeyHash:    k.KeyHash,
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+		Revoked:    k.Revoked,
+		CreatedAt:  k.CreatedAt,
+		RevokedAt:  k.RevokedAt,
+	}
+
+	if err := json.Unmarshal([]byte(k.Scopes), &key.Scopes); err != nil {
+		return key, errors.Wrap(err, "failed to decode scopes")
+	}
+
+	return key, nil
+}
+
+func encodeScopes(scopes []string) (string, error) {
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	encoded, err := json.Marshal(scopes)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode scopes")
+	}
+
+	return string(encoded), nil
+}
+
+func (r *SQLiteRepository) CreateAPIKey(
+	ctx context.Context,
+	params entities.StoreAPIKeyParams,
+) (entities.APIKey, error) {
+	scopes, err := encodeScopes(params.Scopes)
+	if err != nil {
+		return entities.APIKey{}, err
+	}
+
+	stmt := sq.
+		Insert(apiKeyTableName).
+		Columns("user_id", "name", "prefix", "key_hash", "scopes", "expires_at", "created_at").
+		Values(params.UserID, params.Name, params.Prefix, params.KeyHash, scopes, utcTime(params.ExpiresAt),
+			r.timestamp()).
+		Suffix("RETURNING " + strings.Join(apiKeyColumns, ", "))
+
+	return r.getAPIKeyWith(ctx, stmt)
+}
+
+func (r *SQLiteRepository) GetAPIKey(ctx context.Context, id int64) (entities.APIKey, error) {
+	return r.getAPIKeyWith(ctx, sq.Select(apiKeyColumns...).From(apiKeyTableName).Where(sq.Eq{"id": id}))
+}
+
+func (r *SQLiteRepository) GetAPIKeyByPrefix(ctx context.Context, prefix string) (entities.APIKey, error) {
+	return r.getAPIKeyWith(ctx, sq.Select(apiKeyColumns...).From(apiKeyTableName).Where(sq.Eq{"prefix": prefix}))
+}
+
+// getAPIKeyWith runs a statement that returns a single key.
+func (r *SQLiteRepository) getAPIKeyWith(ctx context.Context, stmt sq.Sqlizer) (entities.APIKey, error) {
+	var row sqliteAPIKey
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return entities.APIKey{}, errors.Wrap(err, "failed to build a query")
+	}
+
+	err = sqlscan.Get(ctx, r.db, &row, sql, args...)
+	if err != nil {
+		if sqlscan.NotFound(err) {
+			return entities.APIKey{}, entities.ErrAPIKeyNotFound
+		}
+
+		return entities.APIKey{}, errors.Wrap(err, "failed to execute a query")
+	}
+
+	return row.toEntity()
+}
+
+func (r *SQLiteRepository) ListAPIKeys(ctx context.Context, userID int64) ([]entities.APIKey, error) {
+	var rows []sqliteAPIKey
+
+	stmt := sq.
+		Select(apiKeyColumns...).
+		From(apiKeyTableName).
+		Where(sq.Eq{"user_id": userID}).
+		OrderBy("id")
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build a query")
+	}
+
+	err = sqlscan.Select(ctx, r.db, &rows, sql, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to execute a query")
+	}
+
+	keys := make([]entities.APIKey, 0, len(rows))
+
+	for _, row := range rows {
+		key, err := row.toEntity()
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (r *SQLiteRepository) UpdateAPIKey(
+	ctx context.Context,
+	id int64,
+	params entities.UpdateAPIKeyParams,
+) (entities.APIKey, error) {
+	nothingToUpdate := params == entities.UpdateAPIKeyParams{}
+	if nothingToUpdate {
+		return r.GetAPIKey(ctx, id)
+	}
+
+	stmt := sq.
+		Update(apiKeyTableName)
+	if params.Name != nil {
+		stmt = stmt.Set("name", *params.Name)
+	}
+	if params.Scopes != nil {
+		scopes, err := encodeScopes(*params.Scopes)
+		if err != nil {
+			return entities.APIKey{}, err
+		}
+
+		stmt = stmt.Set("scopes", scopes)
+	}
+	if params.ExpiresAt != nil {
+		stmt = stmt.Set("expires_at", utcTime(params.ExpiresAt))
+	}
+
+	stmt = stmt.
+		Where(sq.Eq{"id": id}).
+		Suffix("RETURNING " + strings.Join(apiKeyColumns, ", "))
+
+	return r.getAPIKeyWith(ctx, stmt)
+}
+
+func (r *SQLiteRepository) RevokeAPIKey(ctx context.Context, id int64) error {
+	stmt := sq.
+		Update(apiKeyTableName).
+		Set("revoked", true).
+		Set("revoked_at", r.timestamp()).
+		Where(sq.Eq{"id": id, "revoked": false})
+
+	return r.exec(ctx, stmt)
+}
+
+func (r *SQLiteRepository) TouchAPIKey(ctx context.Context, id int64, usedAt time.Time) error {
+	stmt := sq.
+		Update(apiKeyTableName).
+		Set("last_used_at", usedAt.UTC()).
+		Where(sq.Eq{"id": id})
+
+	return r.exec(ctx, stmt)
+}
+
+func (r *SQLiteRepository) exec(ctx context.Context, stmt sq.Sqlizer) error {
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build a query")
+	}
+
+	_, err = r.db.ExecContext(ctx, sql, args...)
+	if err != nil {
+		return errors.Wrap(err, "failed to execute a query")
+	}
+
+	return nil
+}
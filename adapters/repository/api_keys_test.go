@@ -0,0 +1,114 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torwig/user-service/entities"
+)
+
+func TestPostgresRepository_CreateAPIKey(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Microsecond)
+
+	params := entities.StoreAPIKeyParams{
+		CreateAPIKeyParams: entities.CreateAPIKeyParams{
+			UserID:    1001,
+			Name:      "nightly-sync",
+			Scopes:    []string{string(entities.APIKeyScopeViewUsers)},
+			ExpiresAt: &expiresAt,
+		},
+		Prefix:  "0a1b2c3d4e5f",
+		KeyHash: "hash",
+	}
+
+	createdKey, err := repo.CreateAPIKey(context.Background(), params)
+	require.NoError(t, err)
+	assert.Greater(t, createdKey.ID, int64(0))
+	assert.False(t, createdKey.IsRevoked())
+	assert.Equal(t, params.UserID, createdKey.UserID)
+	assert.Equal(t, params.Name, createdKey.Name)
+	assert.Equal(t, params.Scopes, createdKey.Scopes)
+	assert.Equal(t, params.Prefix, createdKey.Prefix)
+	assert.Equal(t, params.KeyHash, createdKey.KeyHash)
+	require.NotNil(t, createdKey.ExpiresAt)
+	assert.True(t, expiresAt.Equal(*createdKey.ExpiresAt))
+	assert.Nil(t, createdKey.LastUsedAt)
+
+	foundKey, err := repo.GetAPIKeyByPrefix(context.Background(), params.Prefix)
+	require.NoError(t, err)
+	assert.Equal(t, createdKey.ID, foundKey.ID)
+}
+
+func TestPostgresRepository_GetAPIKey(t *testing.T) {
+	_, err := repo.GetAPIKey(context.Background(), 999_999_999)
+	require.ErrorIs(t, err, entities.ErrAPIKeyNotFound)
+
+	_, err = repo.GetAPIKeyByPrefix(context.Background(), "missing")
+	require.ErrorIs(t, err, entities.ErrAPIKeyNotFound)
+}
+
+func TestPostgresRepository_ListAPIKeys(t *testing.T) {
+	for _, prefix := range []string{"list00000001", "list00000002"} {
+		_, err := repo.CreateAPIKey(context.Background(), entities.StoreAPIKeyParams{
+			CreateAPIKeyParams: entities.CreateAPIKeyParams{UserID: 1002, Name: prefix, Scopes: []string{}},
+			Prefix:             prefix,
+			KeyHash:            "hash",
+		})
+		require.NoError(t, err)
+	}
+
+	keys, err := repo.ListAPIKeys(context.Background(), 1002)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, "list00000001", keys[0].Prefix)
+	assert.Equal(t, "list00000002", keys[1].Prefix)
+}
+
+func TestPostgresRepository_UpdateAPIKey(t *testing.T) {
+	createdKey, err := repo.CreateAPIKey(context.Background(), entities.StoreAPIKeyParams{
+		CreateAPIKeyParams: entities.CreateAPIKeyParams{UserID: 1003, Name: "ci", Scopes: []string{}},
+		Prefix:             "update000001",
+		KeyHash:            "hash",
+	})
+	require.NoError(t, err)
+
+	scopes := []string{string(entities.APIKeyScopeCreateUsers), string(entities.APIKeyScopeViewUsers)}
+
+	updatedKey, err := repo.UpdateAPIKey(context.Background(), createdKey.ID, entities.UpdateAPIKeyParams{
+		Name:   stringPtr("deploy"),
+		Scopes: &scopes,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "deploy", updatedKey.Name)
+	assert.Equal(t, scopes, updatedKey.Scopes)
+
+	_, err = repo.UpdateAPIKey(context.Background(), 999_999_999, entities.UpdateAPIKeyParams{Name: stringPtr("x")})
+	require.ErrorIs(t, err, entities.ErrAPIKeyNotFound)
+}
+
+func TestPostgresRepository_RevokeAPIKey(t *testing.T) {
+	createdKey, err := repo.CreateAPIKey(context.Background(), entities.StoreAPIKeyParams{
+		CreateAPIKeyParams: entities.CreateAPIKeyParams{UserID: 1004, Name: "old", Scopes: []string{}},
+		Prefix:             "revoke000001",
+		KeyHash:            "hash",
+	})
+	require.NoError(t, err)
+
+	usedAt := time.Now().UTC().Truncate(time.Microsecond)
+
+	err = repo.TouchAPIKey(context.Background(), createdKey.ID, usedAt)
+	require.NoError(t, err)
+
+	err = repo.RevokeAPIKey(context.Background(), createdKey.ID)
+	require.NoError(t, err)
+
+	revokedKey, err := repo.GetAPIKey(context.Background(), createdKey.ID)
+	require.NoError(t, err)
+	assert.True(t, revokedKey.IsRevoked())
+	assert.NotNil(t, revokedKey.RevokedAt)
+	require.NotNil(t, revokedKey.LastUsedAt)
+	assert.True(t, usedAt.Equal(*revokedKey.LastUsedAt))
+}
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+)
+
+const auditLogTableName = "audit_log"
+
+func (r *PostgresRepository) RecordAudit(ctx context.Context, record entities.AuditRecord) error {
+	defer r.observeQuery("record_audit", time.Now())
+
+	stmt := sq.
+		Insert(auditLogTableName).
+		Columns("operator", "action", "user_id", "details", "error").
+		Values(record.Operator, record.Action, record.UserID, record.Details, record.Error).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build a query")
+	}
+
+	_, err = r.db.Exec(ctx, sql, args...)
+	if err != nil {
+		return errors.Wrap(err, "failed to execute a query")
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+)
+
+// userEmailIndexName is the unique index keeping an email to a single active user.
+const userEmailIndexName = "users_email_idx"
+
+// VerifyEmail marks the email of the user as verified, unless the user doesn't have that email anymore,
+// in which case ErrEmailChanged is returned. Verifying a verified email keeps the time of the first verification.
+func (r *PostgresRepository) VerifyEmail(ctx context.Context, id int64, email string) (entities.User, error) {
+	defer r.observeQuery("verify_user_email", time.Now())
+
+	var user entities.User
+
+	stmt := sq.
+		Update(userTableName).
+		Set("email_verified_at", sq.Expr("COALESCE(email_verified_at, NOW())")).
+		Set("updated_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": id, "email": email, "deleted": false}).
+		Suffix(returningUser).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return user, errors.Wrap(err, "failed to build a query")
+	}
+
+	err = pgxscan.Get(ctx, r.db, &user, sql, args...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return user, entities.ErrEmailChanged
+		}
+
+		return user, errors.Wrap(err, "failed to execute a query")
+	}
+
+	r.recentWrites.record(ctx)
+
+	return user, loadExternalIDs(ctx, r.db, &user)
+}
+
+// keepEmailVerification keeps the time the email was verified only if the email stays the same.
+func keepEmailVerification(email string) sq.Sqlizer {
+	return sq.Expr("CASE WHEN email = ? THEN email_verified_at END", email)
+}
+
+func isEmailTaken(err error) bool {
+	var pgErr *pgconn.PgError
+
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolationErrCode && pgErr.ConstraintName == userEmailIndexName
+}
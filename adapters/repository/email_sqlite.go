@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/georgysavva/scany/v2/sqlscan"
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// VerifyEmail marks the email of the user as verified, unless the user doesn't have that email anymore,
+// in which case ErrEmailChanged is returned. Verifying a verified email keeps the time of the first verification.
+func (r *SQLiteRepository) VerifyEmail(ctx context.Context, id int64, email string) (entities.User, error) {
+	var user entities.User
+
+	now := r.timestamp()
+
+	stmt := sq.
+		Update(userTableName).
+		Set("email_verified_at", sq.Expr("COALESCE(email_verified_at, ?)", now)).
+		Set("updated_at", now).
+		Where(sq.Eq{"id": id, "email": email, "deleted": false}).
+		Suffix(returningUser)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return user, errors.Wrap(err, "failed to build a query")
+	}
+
+	user, err = getSQLiteUser(ctx, r.db, sql, args...)
+	if err != nil {
+		if sqlscan.NotFound(err) {
+			return user, entities.ErrEmailChanged
+		}
+
+		return user, errors.Wrap(err, "failed to execute a query")
+	}
+
+	return user, loadSQLiteExternalIDs(ctx, r.db, &user)
+}
+
+// isSQLiteEmailTaken reports whether the statement violated the unique index of emails,
+// SQLite doesn't tell the index but the columns it covers.
+func isSQLiteEmailTaken(err error) bool {
+	var sqliteErr *sqlite.Error
+
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE &&
+		strings.Contains(sqliteErr.Error(), userTableName+".email")
+}
@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+)
+
+const (
+	userExternalIDTableName = "user_external_ids"
+	uniqueViolationErrCode  = "23505"
+)
+
+type userExternalID struct {
+	UserID     int64
+	Source     string
+	ExternalID string
+}
+
+// GetByExternalID returns the user the external ID of the source belongs to.
+func (r *PostgresRepository) GetByExternalID(ctx context.Context, source, externalID string) (entities.User, error) {
+	defer r.observeQuery("get_user_by_external_id", time.Now())
+
+	var user entities.User
+
+	columns := make([]string, 0, len(userColumns))
+	for _, column := range userColumns {
+		columns = append(columns, "u."+column)
+	}
+
+	stmt := sq.
+		Select(columns...).
+		From(userTableName + " u").
+		Join(userExternalIDTableName + " e ON e.user_id = u.id").
+		Where(sq.Eq{"e.source": source, "e.external_id": externalID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return user, errors.Wrap(err, "failed to build a query")
+	}
+
+	reader := r.reader(ctx)
+
+	err = pgxscan.Get(ctx, reader, &user, sql, args...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return user, entities.ErrUserNotFound
+		}
+
+		return user, errors.Wrap(err, "failed to execute a query")
+	}
+
+	return user, loadExternalIDs(ctx, reader, &user)
+}
+
+// loadExternalIDs fills in the external IDs of the users with a single query.
+func loadExternalIDs(ctx context.Context, q pgxscan.Querier, users ...*entities.User) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*entities.User, len(users))
+	ids := make([]int64, 0, len(users))
+
+	for _, user := range users {
+		byID[user.ID] = user
+		ids = append(ids, user.ID)
+	}
+
+	stmt := sq.
+		Select("user_id", "source", "external_id").
+		From(userExternalIDTableName).
+		Where(sq.Eq{"user_id": ids}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build a query")
+	}
+
+	var rows []userExternalID
+
+	err = pgxscan.Select(ctx, q, &rows, sql, args...)
+	if err != nil {
+		return errors.Wrap(err, "failed to execute a query")
+	}
+
+	for _, row := range rows {
+		user := byID[row.UserID]
+		user.ExternalIDs = append(user.ExternalIDs, entities.ExternalID{Source: row.Source, ID: row.ExternalID})
+	}
+
+	// sorted here rather than by the database, whose collation may differ
+	for _, user := range users {
+		entities.SortExternalIDs(user.ExternalIDs)
+	}
+
+	return nil
+}
+
+// setExternalIDs replaces the external IDs of the user within the transaction.
+func setExternalIDs(ctx context.Context, tx pgx.Tx, user *entities.User, externalIDs []entities.ExternalID) error {
+	sql, args, err := sq.
+		Delete(userExternalIDTableName).
+		Where(sq.Eq{"user_id": user.ID}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build a query")
+	}
+
+	_, err = tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return errors.Wrap(err, "failed to execute a query")
+	}
+
+	user.ExternalIDs = nil
+
+	if len(externalIDs) == 0 {
+		return nil
+	}
+
+	stmt := sq.
+		Insert(userExternalIDTableName).
+		Columns("user_id", "source", "external_id").
+		PlaceholderFormat(sq.Dollar)
+	for _, externalID := range externalIDs {
+		stmt = stmt.Values(user.ID, externalID.Source, externalID.ID)
+	}
+
+	sql, args, err = stmt.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build a query")
+	}
+
+	_, err = tx.Exec(ctx, sql, args...)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return entities.ErrExternalIDTaken
+		}
+
+		return errors.Wrap(err, "failed to execute a query")
+	}
+
+	user.ExternalIDs = append([]entities.ExternalID(nil), externalIDs...)
+	entities.SortExternalIDs(user.ExternalIDs)
+
+	return nil
+}
+
+// userPointers returns pointers to the elements of users, so that they can be modified in place.
+func userPointers(users []entities.User) []*entities.User {
+	pointers := make([]*entities.User, 0, len(users))
+	for i := range users {
+		pointers = append(pointers, &users[i])
+	}
+
+	return pointers
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolationErrCode
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/georgysavva/scany/v2/sqlscan"
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// GetByExternalID returns the user the external ID of the source belongs to.
+func (r *SQLiteRepository) GetByExternalID(ctx context.Context, source, externalID string) (entities.User, error) {
+	var user entities.User
+
+	columns := make([]string, 0, len(userColumns))
+	for _, column := range userColumns {
+		columns = append(columns, "u."+column)
+	}
+
+	stmt := sq.
+		Select(columns...).
+		From(userTableName + " u").
+		Join(userExternalIDTableName + " e ON e.user_id = u.id").
+		Where(sq.Eq{"e.source": source, "e.external_id": externalID})
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return user, errors.Wrap(err, "failed to build a query")
+	}
+
+	user, err = getSQLiteUser(ctx, r.db, sql, args...)
+	if err != nil {
+		if sqlscan.NotFound(err) {
+			return user, entities.ErrUserNotFound
+		}
+
+		return user, errors.Wrap(err, "failed to execute a query")
+	}
+
+	return user, loadSQLiteExternalIDs(ctx, r.db, &user)
+}
+
+// inTx runs fn in a transaction, which is committed if fn succeeds.
+func (r *SQLiteRepository) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin a transaction")
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit a transaction")
+	}
+
+	return nil
+}
+
+// loadSQLiteExternalIDs fills in the external IDs of the users with a single query.
+func loadSQLiteExternalIDs(ctx context.Context, q sqlscan.Querier, users ...*entities.User) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*entities.User, len(users))
+	ids := make([]int64, 0, len(users))
+
+	for _, user := range users {
+		byID[user.ID] = user
+		ids = append(ids, user.ID)
+	}
+
+	sql, args, err := sq.
+		Select("user_id", "source", "external_id").
+		From(userExternalIDTableName).
+		Where(sq.Eq{"user_id": ids}).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build a query")
+	}
+
+	var rows []userExternalID
+
+	err = sqlscan.Select(ctx, q, &rows, sql, args...)
+	if err != nil {
+		return errors.Wrap(err, "failed to execute a query")
+	}
+
+	for _, row := range rows {
+		user := byID[row.UserID]
+		user.ExternalIDs = append(user.ExternalIDs, entities.ExternalID{Source: row.Source, ID: row.ExternalID})
+	}
+
+	for _, user := range users {
+		entities.SortExternalIDs(user.ExternalIDs)
+	}
+
+	return nil
+}
+
+// setSQLiteExternalIDs replaces the external IDs of the user within the transaction.
+func setSQLiteExternalIDs(
+	ctx context.Context,
+	tx *sql.Tx,
+	user *entities.User,
+	externalIDs []entities.ExternalID,
+) error {
+	query, args, err := sq.Delete(userExternalIDTableName).Where(sq.Eq{"user_id": user.ID}).ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build a query")
+	}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(err, "failed to execute a query")
+	}
+
+	user.ExternalIDs = nil
+
+	if len(externalIDs) == 0 {
+		return nil
+	}
+
+	stmt := sq.
+		Insert(userExternalIDTableName).
+		Columns("user_id", "source", "external_id")
+	for _, externalID := range externalIDs {
+		stmt = stmt.Values(user.ID, externalID.Source, externalID.ID)
+	}
+
+	query, args, err = stmt.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build a query")
+	}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		var sqliteErr *sqlite.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY {
+			return entities.ErrExternalIDTaken
+		}
+
+		return errors.Wrap(err, "failed to execute a query")
+	}
+
+	user.ExternalIDs = append([]entities.ExternalID(nil), externalIDs...)
+	entities.SortExternalIDs(user.ExternalIDs)
+
+	return nil
+}
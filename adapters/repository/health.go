@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+)
+
+const (
+	schemaMigrationsTableName = "schema_migrations"
+	undefinedTableErrCode     = "42P01"
+)
+
+var (
+	ErrSchemaNotVersioned = errors.New("database schema isn't versioned")
+	ErrSchemaDirty        = errors.New("database schema is dirty after a failed migration")
+	ErrSchemaOutdated     = errors.New("database schema version doesn't match the migrations")
+)
+
+// Ping checks that a connection to the database (and the read replica, if any) can be acquired and used.
+func (r *PostgresRepository) Ping(ctx context.Context) error {
+	if err := r.db.Ping(ctx); err != nil {
+		return errors.Wrap(err, "failed to ping the database")
+	}
+
+	if r.replica != nil {
+		if err := r.replica.Ping(ctx); err != nil {
+			return errors.Wrap(err, "failed to ping the read replica")
+		}
+	}
+
+	return nil
+}
+
+// CheckSchemaVersion checks that the migrations up to the expected version have been applied cleanly.
+func (r *PostgresRepository) CheckSchemaVersion(ctx context.Context, expected uint) error {
+	var (
+		version uint
+		dirty   bool
+	)
+
+	err := r.db.QueryRow(ctx, "SELECT version, dirty FROM "+schemaMigrationsTableName+" LIMIT 1").
+		Scan(&version, &dirty)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) || isUndefinedTable(err) {
+			return ErrSchemaNotVersioned
+		}
+
+		return errors.Wrap(err, "failed to get schema version")
+	}
+
+	if dirty {
+		return errors.Wrapf(ErrSchemaDirty, "version %d", version)
+	}
+
+	if version != expected {
+		return errors.Wrapf(ErrSchemaOutdated, "version %d, expected %d", version, expected)
+	}
+
+	return nil
+}
+
+func isUndefinedTable(err error) bool {
+	var pgErr *pgconn.PgError
+
+	return errors.As(err, &pgErr) && pgErr.Code == undefinedTableErrCode
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+)
+
+const idempotencyTableName = "idempotency_keys"
+
+// ReserveIdempotencyKey stores a new in-progress record for the key that expires after the lease.
+// If the key is already taken by a record that hasn't expired yet, that record is returned instead
+// and the flag is false.
+func (r *PostgresRepository) ReserveIdempotencyKey(
+	ctx context.Context,
+	key string,
+	fingerprint string,
+	lease time.Duration,
+) (entities.IdempotencyRecord, bool, error) {
+	defer r.observeQuery("reserve_idempotency_key", time.Now())
+
+	var record entities.IdempotencyRecord
+
+	stmt := sq.
+		Insert(idempotencyTableName).
+		Columns("key", "fingerprint", "expires_at").
+		Values(key, fingerprint, sq.Expr("LOCALTIMESTAMP + ?::interval", lease)).
+		Suffix("ON CONFLICT (key) DO UPDATE SET " +
+			"fingerprint = EXCLUDED.fingerprint, status_code = 0, response_headers = NULL, response_body = NULL, " +
+			"created_at = LOCALTIMESTAMP, expires_at = EXCLUDED.expires_at " +
+			"WHERE " + idempotencyTableName + ".expires_at <= LOCALTIMESTAMP " +
+			"RETURNING key, fingerprint, status_code, response_headers, response_body, created_at, expires_at").
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return record, false, errors.Wrap(err, "failed to build a query")
+	}
+
+	err = pgxscan.Get(ctx, r.db, &record, sql, args...)
+	if err == nil {
+		return record, true, nil
+	}
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return record, false, errors.Wrap(err, "failed to execute a query")
+	}
+
+	// the key is taken by a live record
+	record, err = r.getIdempotencyRecord(ctx, key)
+	if err != nil {
+		return record, false, err
+	}
+
+	return record, false, nil
+}
+
+func (r *PostgresRepository) getIdempotencyRecord(ctx context.Context, key string) (entities.IdempotencyRecord, error) {
+	var record entities.IdempotencyRecord
+
+	stmt := sq.
+		Select("key", "fingerprint", "status_code", "response_headers", "response_body", "created_at", "expires_at").
+		From(idempotencyTableName).
+		Where(sq.Eq{"key": key}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return record, errors.Wrap(err, "failed to build a query")
+	}
+
+	err = pgxscan.Get(ctx, r.db, &record, sql, args...)
+	if err != nil {
+		return record, errors.Wrap(err, "failed to execute a query")
+	}
+
+	return record, nil
+}
+
+// CompleteIdempotencyKey stores the response to the request and keeps the key for ttl from now.
+func (r *PostgresRepository) CompleteIdempotencyKey(
+	ctx context.Context,
+	key string,
+	statusCode int,
+	headers map[string][]string,
+	body []byte,
+	ttl time.Duration,
+) error {
+	defer r.observeQuery("complete_idempotency_key", time.Now())
+
+	stmt := sq.
+		Update(idempotencyTableName).
+		Set("status_code", statusCode).
+		Set("response_headers", headers).
+		Set("response_body", body).
+		Set("expires_at", sq.Expr("LOCALTIMESTAMP + ?::interval", ttl)).
+		Where(sq.Eq{"key": key}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build a query")
+	}
+
+	_, err = r.db.Exec(ctx, sql, args...)
+	if err != nil {
+		return errors.Wrap(err, "failed to execute a query")
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey forgets the key so that the request can be retried.
+func (r *PostgresRepository) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	defer r.observeQuery("release_idempotency_key", time.Now())
+
+	stmt := sq.
+		Delete(idempotencyTableName).
+		Where(sq.Eq{"key": key}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build a query")
+	}
+
+	_, err = r.db.Exec(ctx, sql, args...)
+	if err != nil {
+		return errors.Wrap(err, "failed to execute a query")
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) PurgeIdempotencyKeys(ctx context.Context) error {
+	defer r.observeQuery("purge_idempotency_keys", time.Now())
+
+	stmt := sq.
+		Delete(idempotencyTableName).
+		Where("expires_at <= LOCALTIMESTAMP").
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build a query")
+	}
+
+	return r.slowStatements(ctx, r.db, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, sql, args...); err != nil {
+			return errors.Wrap(err, "failed to execute a query")
+		}
+
+		return nil
+	})
+}
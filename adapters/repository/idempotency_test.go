@@ -0,0 +1,105 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresRepository_IdempotencyKeys(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Reserve and complete", func(t *testing.T) {
+		record, reserved, err := repo.ReserveIdempotencyKey(ctx, "user:1|complete", "fingerprint", time.Minute)
+		require.NoError(t, err)
+		require.True(t, reserved)
+		assert.Equal(t, "user:1|complete", record.Key)
+		assert.Equal(t, "fingerprint", record.Fingerprint)
+		assert.False(t, record.IsCompleted())
+		assert.True(t, record.ExpiresAt.After(record.CreatedAt))
+
+		// the request is still in progress
+		record, reserved, err = repo.ReserveIdempotencyKey(ctx, "user:1|complete", "fingerprint", time.Minute)
+		require.NoError(t, err)
+		require.False(t, reserved)
+		assert.False(t, record.IsCompleted())
+
+		headers := map[string][]string{"Content-Type": {"application/json"}}
+		err = repo.CompleteIdempotencyKey(ctx, "user:1|complete", 201, headers, []byte(`{"id":1}`), time.Hour)
+		require.NoError(t, err)
+
+		record, reserved, err = repo.ReserveIdempotencyKey(ctx, "user:1|complete", "fingerprint", time.Minute)
+		require.NoError(t, err)
+		require.False(t, reserved)
+		assert.True(t, record.IsCompleted())
+		assert.Equal(t, 201, record.StatusCode)
+		assert.Equal(t, headers, record.ResponseHeaders)
+		assert.Equal(t, []byte(`{"id":1}`), record.ResponseBody)
+		assert.WithinDuration(t, record.CreatedAt.Add(time.Hour), record.ExpiresAt, time.Minute)
+	})
+
+	t.Run("Fingerprint mismatch", func(t *testing.T) {
+		_, reserved, err := repo.ReserveIdempotencyKey(ctx, "user:1|mismatch", "first", time.Minute)
+		require.NoError(t, err)
+		require.True(t, reserved)
+
+		// the record holding the key is returned, so that the caller can tell the requests apart
+		record, reserved, err := repo.ReserveIdempotencyKey(ctx, "user:1|mismatch", "second", time.Minute)
+		require.NoError(t, err)
+		require.False(t, reserved)
+		assert.Equal(t, "first", record.Fingerprint)
+	})
+
+	t.Run("Release", func(t *testing.T) {
+		_, reserved, err := repo.ReserveIdempotencyKey(ctx, "user:1|release", "fingerprint", time.Minute)
+		require.NoError(t, err)
+		require.True(t, reserved)
+
+		require.NoError(t, repo.ReleaseIdempotencyKey(ctx, "user:1|release"))
+
+		_, reserved, err = repo.ReserveIdempotencyKey(ctx, "user:1|release", "fingerprint", time.Minute)
+		require.NoError(t, err)
+		assert.True(t, reserved)
+	})
+
+	t.Run("Expired lease", func(t *testing.T) {
+		_, reserved, err := repo.ReserveIdempotencyKey(ctx, "user:1|lease", "first", 100*time.Millisecond)
+		require.NoError(t, err)
+		require.True(t, reserved)
+
+		time.Sleep(200 * time.Millisecond)
+
+		// the instance holding the key is gone, so the key is taken over by another request
+		record, reserved, err := repo.ReserveIdempotencyKey(ctx, "user:1|lease", "second", time.Minute)
+		require.NoError(t, err)
+		require.True(t, reserved)
+		assert.Equal(t, "second", record.Fingerprint)
+		assert.False(t, record.IsCompleted())
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		_, reserved, err := repo.ReserveIdempotencyKey(ctx, "user:1|purge", "fingerprint", 100*time.Millisecond)
+		require.NoError(t, err)
+		require.True(t, reserved)
+
+		time.Sleep(200 * time.Millisecond)
+
+		require.NoError(t, repo.PurgeIdempotencyKeys(ctx))
+
+		conn, err := pgx.Connect(ctx, postgresDSN)
+		require.NoError(t, err)
+
+		defer func() {
+			_ = conn.Close(context.Background())
+		}()
+
+		var count int
+		err = conn.QueryRow(ctx, "SELECT COUNT(*) FROM idempotency_keys WHERE key = $1", "user:1|purge").Scan(&count)
+		require.NoError(t, err)
+		assert.Zero(t, count)
+	})
+}
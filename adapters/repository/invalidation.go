@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/log"
+)
+
+const (
+	userInvalidationChannel = "user_invalidations"
+
+	// listening is retried after a delay doubled on every failure in a row
+	invalidationListenMinRetryDelay = time.Second
+	invalidationListenMaxRetryDelay = time.Minute
+)
+
+// UserInvalidator forgets cached users.
+type UserInvalidator interface {
+	Invalidate(id int64)
+	Purge()
+}
+
+// PublishUserInvalidation notifies all the listening replicas, this one included, that the user has changed.
+func (r *PostgresRepository) PublishUserInvalidation(ctx context.Context, id int64) error {
+	defer r.observeQuery("publish_user_invalidation", time.Now())
+
+	_, err := r.db.Exec(ctx, "SELECT pg_notify($1, $2)", userInvalidationChannel, strconv.FormatInt(id, 10))
+	if err != nil {
+		return errors.Wrap(err, "failed to execute a query")
+	}
+
+	return nil
+}
+
+// ListenUserInvalidations passes the invalidations published by the replicas to invalidator until ctx is done.
+// It listens on a dedicated connection rather than one of the pool. Notifications sent while the connection
+// is broken are lost, so the invalidator is purged every time listening starts over.
+func (r *PostgresRepository) ListenUserInvalidations(ctx context.Context, invalidator UserInvalidator) {
+	logger := log.FromContext(ctx)
+	delay := invalidationListenMinRetryDelay
+
+	for {
+		listened, err := r.listenUserInvalidations(ctx, invalidator)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if listened {
+			delay = invalidationListenMinRetryDelay
+		}
+
+		logger.Warnf("stopped listening to user invalidations, retrying in %s: %s", delay, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		delay = min(2*delay, invalidationListenMaxRetryDelay)
+	}
+}
+
+// listenUserInvalidations reports whether listening has started before it failed.
+func (r *PostgresRepository) listenUserInvalidations(ctx context.Context, invalidator UserInvalidator) (bool, error) {
+	conn, err := pgx.ConnectConfig(ctx, r.db.Config().ConnConfig)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to connect to the database")
+	}
+
+	defer func() {
+		_ = conn.Close(context.Background())
+	}()
+
+	_, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{userInvalidationChannel}.Sanitize())
+	if err != nil {
+		return false, errors.Wrap(err, "failed to listen")
+	}
+
+	invalidator.Purge()
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, errors.Wrap(err, "failed to wait for notification")
+		}
+
+		id, err := strconv.ParseInt(notification.Payload, 10, 64)
+		if err != nil {
+			log.FromContext(ctx).Warnf("invalid user invalidation %q", notification.Payload)
+
+			continue
+		}
+
+		invalidator.Invalidate(id)
+	}
+}
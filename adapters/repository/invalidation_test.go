@@ -0,0 +1,94 @@
+package repository_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingInvalidator struct {
+	mu     sync.Mutex
+	ids    []int64
+	purges int
+}
+
+func (i *recordingInvalidator) Invalidate(id int64) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.ids = append(i.ids, id)
+}
+
+func (i *recordingInvalidator) Purge() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.purges++
+}
+
+func (i *recordingInvalidator) state() ([]int64, int) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return append([]int64(nil), i.ids...), i.purges
+}
+
+func TestPostgresRepository_UserInvalidations(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	invalidator := &recordingInvalidator{}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		repo.ListenUserInvalidations(ctx, invalidator)
+	}()
+
+	// listening has started once the invalidator is purged
+	require.Eventually(t, func() bool {
+		_, purges := invalidator.state()
+		return purges == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, repo.PublishUserInvalidation(ctx, 42))
+
+	require.Eventually(t, func() bool {
+		ids, _ := invalidator.state()
+		return len(ids) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	ids, _ := invalidator.state()
+	assert.Equal(t, []int64{42}, ids)
+
+	// the invalidations sent while the connection is broken are lost, so the invalidator is purged again
+	conn, err := pgx.Connect(ctx, postgresDSN)
+	require.NoError(t, err)
+
+	defer func() {
+		_ = conn.Close(context.Background())
+	}()
+
+	_, err = conn.Exec(ctx, "SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE query LIKE 'LISTEN %'")
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		_, purges := invalidator.state()
+		return purges == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, repo.PublishUserInvalidation(ctx, 43))
+
+	require.Eventually(t, func() bool {
+		ids, _ := invalidator.state()
+		return len(ids) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,484 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/torwig/user-service/entities"
+)
+
+// InMemoryRepository keeps users and API keys in the memory of a single process, e.g. for tests and demos.
+// It behaves like PostgresRepository: users are deleted softly and the same errors are returned.
+type InMemoryRepository struct {
+	mu     sync.RWMutex
+	users  map[int64]entities.User
+	lastID int64
+	// externalIDs maps the external IDs to the users they belong to.
+	externalIDs map[entities.ExternalID]int64
+	apiKeys     map[int64]entities.APIKey
+	lastKeyID   int64
+	// phoneVerifications are the codes sent to the users by their IDs.
+	phoneVerifications map[int64]entities.PhoneVerification
+	// statusesBeforeDelete are the statuses the deleted users had, they're given back on restore.
+	statusesBeforeDelete map[int64]entities.UserStatus
+	// statusChanges are kept in the order they were made.
+	statusChanges      []entities.UserStatusChange
+	lastStatusChangeID int64
+	now                func() time.Time
+}
+
+func NewInMemoryRepository() *InMemoryRepository {
+	return &InMemoryRepository{
+		users:                make(map[int64]entities.User),
+		externalIDs:          make(map[entities.ExternalID]int64),
+		apiKeys:              make(map[int64]entities.APIKey),
+		phoneVerifications:   make(map[int64]entities.PhoneVerification),
+		statusesBeforeDelete: make(map[int64]entities.UserStatus),
+		now:                  time.Now,
+	}
+}
+
+// timestamp returns the current time the way it's read back from a column of the "timestamp" type.
+func (r *InMemoryRepository) timestamp() time.Time {
+	return r.now().UTC().Truncate(time.Microsecond)
+}
+
+// Ping always succeeds as there is nothing to connect to.
+func (r *InMemoryRepository) Ping(_ context.Context) error {
+	return nil
+}
+
+func (r *InMemoryRepository) Create(_ context.Context, params entities.CreateUserParams) (entities.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.checkExternalIDs(0, params.ExternalIDs); err != nil {
+		return entities.User{}, err
+	}
+
+	if err := r.checkEmail(0, params.Email); err != nil {
+		return entities.User{}, err
+	}
+
+	attributes, err := params.Attributes.Normalize()
+	if err != nil {
+		return entities.User{}, err
+	}
+
+	r.lastID++
+	now := r.timestamp()
+
+	user := entities.User{
+		ID:          r.lastID,
+		FirstName:   params.FirstName,
+		LastName:    params.LastName,
+		PhoneNumber: params.PhoneNumber,
+		Address:     params.Address,
+		Email:       params.Email,
+		Status:      initialStatus(params.Status),
+		Attributes:  attributes,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	r.setExternalIDs(&user, params.ExternalIDs)
+	r.users[user.ID] = user
+
+	return copyUser(user), nil
+}
+
+func (r *InMemoryRepository) Get(_ context.Context, id int64) (entities.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return entities.User{}, entities.ErrUserNotFound
+	}
+
+	return copyUser(user), nil
+}
+
+func (r *InMemoryRepository) Update(
+	_ context.Context,
+	id int64,
+	params entities.UpdateUserParams,
+) (entities.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return entities.User{}, entities.ErrUserNotFound
+	}
+
+	var attributes entities.Attributes
+
+	if params.Attributes != nil {
+		var err error
+		if attributes, err = entities.MergeAttributes(user.Attributes, *params.Attributes).Normalize(); err != nil {
+			return entities.User{}, err
+		}
+	}
+
+	if params.Email != nil {
+		if err := r.checkEmail(id, *params.Email); err != nil {
+			return entities.User{}, err
+		}
+	}
+
+	if params.ExternalIDs != nil {
+		if err := r.checkExternalIDs(id, *params.ExternalIDs); err != nil {
+			return entities.User{}, err
+		}
+
+		r.setExternalIDs(&user, *params.ExternalIDs)
+	}
+
+	if attributes != nil {
+		user.Attributes = attributes
+	}
+
+	if params.FirstName != nil {
+		user.FirstName = *params.FirstName
+	}
+	if params.LastName != nil {
+		user.LastName = *params.LastName
+	}
+	if params.PhoneNumber != nil {
+		setPhoneNumber(&user, *params.PhoneNumber)
+	}
+	if params.Address != nil {
+		user.Address = *params.Address
+	}
+	if params.Email != nil {
+		setEmail(&user, *params.Email)
+	}
+
+	if params != (entities.UpdateUserParams{}) {
+		user.UpdatedAt = r.timestamp()
+	}
+
+	r.users[id] = user
+
+	return copyUser(user), nil
+}
+
+// Replace sets all the mutable fields of the user.
+func (r *InMemoryRepository) Replace(
+	_ context.Context,
+	id int64,
+	params entities.ReplaceUserParams,
+) (entities.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return entities.User{}, entities.ErrUserNotFound
+	}
+
+	return r.replace(user, params, r.timestamp())
+}
+
+// Upsert replaces the user the external ID belongs to or creates a new user with it, the flag reports whether
+// the user was created. A deleted user isn't replaced, ErrUserDeleted is returned.
+func (r *InMemoryRepository) Upsert(
+	_ context.Context,
+	externalID entities.ExternalID,
+	params entities.ReplaceUserParams,
+) (entities.User, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	params.ExternalIDs = entities.WithExternalID(params.ExternalIDs, externalID)
+
+	if id, ok := r.externalIDs[externalID]; ok {
+		user := r.users[id]
+		if user.IsDeleted() {
+			return entities.User{}, false, entities.ErrUserDeleted
+		}
+
+		user, err := r.replace(user, params, r.timestamp())
+
+		return user, false, err
+	}
+
+	r.lastID++
+	now := r.timestamp()
+
+	user, err := r.replace(entities.User{ID: r.lastID, Status: entities.UserStatusActive, CreatedAt: now}, params, now)
+
+	return user, true, err
+}
+
+// replace stores the user with the given fields, the caller must hold the lock.
+func (r *InMemoryRepository) replace(
+	user entities.User,
+	params entities.ReplaceUserParams,
+	updatedAt time.Time,
+) (entities.User, error) {
+	if err := r.checkExternalIDs(user.ID, params.ExternalIDs); err != nil {
+		return entities.User{}, err
+	}
+
+	if err := r.checkEmail(user.ID, params.Email); err != nil {
+		return entities.User{}, err
+	}
+
+	attributes, err := params.Attributes.Normalize()
+	if err != nil {
+		return entities.User{}, err
+	}
+
+	r.setExternalIDs(&user, params.ExternalIDs)
+	setEmail(&user, params.Email)
+	user.Attributes = attributes
+	user.FirstName = params.FirstName
+	user.LastName = params.LastName
+	setPhoneNumber(&user, params.PhoneNumber)
+	user.Address = params.Address
+	user.UpdatedAt = updatedAt
+
+	r.users[user.ID] = user
+
+	return copyUser(user), nil
+}
+
+// GetByExternalID returns the user the external ID of the source belongs to.
+func (r *InMemoryRepository) GetByExternalID(_ context.Context, source, externalID string) (entities.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, ok := r.externalIDs[entities.ExternalID{Source: source, ID: externalID}]
+	if !ok {
+		return entities.User{}, entities.ErrUserNotFound
+	}
+
+	return copyUser(r.users[id]), nil
+}
+
+// checkExternalIDs returns ErrExternalIDTaken if any of the IDs belongs to a user other than the given one,
+// the caller must hold the lock.
+func (r *InMemoryRepository) checkExternalIDs(userID int64, externalIDs []entities.ExternalID) error {
+	for _, externalID := range externalIDs {
+		if owner, ok := r.externalIDs[externalID]; ok && owner != userID {
+			return entities.ErrExternalIDTaken
+		}
+	}
+
+	return nil
+}
+
+// setExternalIDs replaces the external IDs of the user, the caller must hold the lock.
+func (r *InMemoryRepository) setExternalIDs(user *entities.User, externalIDs []entities.ExternalID) {
+	for _, externalID := range user.ExternalIDs {
+		delete(r.externalIDs, externalID)
+	}
+
+	user.ExternalIDs = nil
+
+	if len(externalIDs) == 0 {
+		return
+	}
+
+	for _, externalID := range externalIDs {
+		r.externalIDs[externalID] = user.ID
+	}
+
+	user.ExternalIDs = append([]entities.ExternalID(nil), externalIDs...)
+	entities.SortExternalIDs(user.ExternalIDs)
+}
+
+func (r *InMemoryRepository) List(_ context.Context, params entities.ListUsersParams) ([]entities.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var users []entities.User
+
+	for _, user := range r.users {
+		if user.ID <= params.AfterID || (user.IsDeleted() && !params.IncludeDeleted) ||
+			!hasAttributes(user.Attributes, params.Attributes) {
+			continue
+		}
+
+		users = append(users, copyUser(user))
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+
+	if uint64(len(users)) > params.Limit {
+		users = users[:params.Limit]
+	}
+
+	return users, nil
+}
+
+// Delete marks the user as deleted, deleting a missing or already deleted user is not an error.
+func (r *InMemoryRepository) Delete(_ context.Context, id int64, cause entities.StatusChangeCause) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok || user.Deleted {
+		return nil
+	}
+
+	now := r.timestamp()
+	r.recordStatusChange(id, user.Status, entities.UserStatusDeleted, cause, now)
+	r.statusesBeforeDelete[id] = user.Status
+	user.Deleted = true
+	user.Status = entities.UserStatusDeleted
+	user.DeletedAt = &now
+	user.UpdatedAt = now
+
+	r.users[id] = user
+
+	return nil
+}
+
+func (r *InMemoryRepository) Restore(
+	_ context.Context,
+	id int64,
+	cause entities.StatusChangeCause,
+) (entities.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return entities.User{}, entities.ErrUserNotFound
+	}
+
+	now := r.timestamp()
+
+	// another user has taken the email since the deletion
+	if user.Deleted {
+		if err := r.checkEmail(id, user.Email); err != nil {
+			return entities.User{}, err
+		}
+
+		status, ok := r.statusesBeforeDelete[id]
+		if !ok {
+			status = entities.UserStatusActive
+		}
+
+		r.recordStatusChange(id, entities.UserStatusDeleted, status, cause, now)
+		user.Status = status
+		delete(r.statusesBeforeDelete, id)
+	}
+
+	user.Deleted = false
+	user.DeletedAt = nil
+	user.UpdatedAt = now
+
+	r.users[id] = user
+
+	return copyUser(user), nil
+}
+
+// VerifyEmail marks the email of the user as verified, unless the user doesn't have that email anymore,
+// in which case ErrEmailChanged is returned. Verifying a verified email keeps the time of the first verification.
+func (r *InMemoryRepository) VerifyEmail(_ context.Context, id int64, email string) (entities.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok || user.Deleted || user.Email != email {
+		return entities.User{}, entities.ErrEmailChanged
+	}
+
+	now := r.timestamp()
+	if user.EmailVerifiedAt == nil {
+		user.EmailVerifiedAt = &now
+	}
+
+	user.UpdatedAt = now
+
+	r.users[id] = user
+
+	return copyUser(user), nil
+}
+
+// checkEmail returns ErrEmailTaken if an active user other than the given one has the email,
+// the caller must hold the lock.
+func (r *InMemoryRepository) checkEmail(userID int64, email string) error {
+	if email == "" {
+		return nil
+	}
+
+	for _, user := range r.users {
+		if user.ID != userID && !user.Deleted && user.Email == email {
+			return entities.ErrEmailTaken
+		}
+	}
+
+	return nil
+}
+
+// setEmail changes the email of the user, the verification is kept only if the email stays the same.
+func setEmail(user *entities.User, email string) {
+	if user.Email != email {
+		user.EmailVerifiedAt = nil
+	}
+
+	user.Email = email
+}
+
+// copyUser returns a user that doesn't share memory with the stored one.
+func copyUser(user entities.User) entities.User {
+	user.DeletedAt = copyTime(user.DeletedAt)
+	user.EmailVerifiedAt = copyTime(user.EmailVerifiedAt)
+	user.PhoneVerifiedAt = copyTime(user.PhoneVerifiedAt)
+	user.ExternalIDs = append([]entities.ExternalID(nil), user.ExternalIDs...)
+	user.Attributes = copyJSONValue(map[string]any(user.Attributes)).(map[string]any)
+
+	return user
+}
+
+// copyJSONValue returns a deep copy of a decoded JSON value.
+func copyJSONValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		c := make(map[string]any, len(v))
+		for k, e := range v {
+			c[k] = copyJSONValue(e)
+		}
+
+		return c
+	case []any:
+		c := make([]any, 0, len(v))
+		for _, e := range v {
+			c = append(c, copyJSONValue(e))
+		}
+
+		return c
+	default:
+		return v
+	}
+}
+
+// hasAttributes reports whether the attributes have all the attributes of the filter.
+func hasAttributes(attributes, filter entities.Attributes) bool {
+	for k, v := range filter {
+		if value, ok := attributes[k]; !ok || value != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	c := *t
+
+	return &c
+}
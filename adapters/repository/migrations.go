@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	migratePgx "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the "pgx" database/sql driver
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/db"
+)
+
+// migrationLockTimeout bounds how long a replica waits for another one to finish migrating.
+const migrationLockTimeout = 5 * time.Minute
+
+type MigrationStatus struct {
+	// Version is the version of the last applied migration, 0 if none has been applied.
+	Version uint
+	// Dirty is set if the last migration failed and the schema has to be fixed manually.
+	Dirty bool
+	// Latest is the version of the newest embedded migration.
+	Latest uint
+}
+
+func (s MigrationStatus) IsCurrent() bool {
+	return !s.Dirty && s.Version == s.Latest
+}
+
+// Migrator applies the migrations embedded into the binary. Migrations are run under
+// a Postgres advisory lock, so replicas migrating the same database at once don't race.
+type Migrator struct {
+	migrate *migrate.Migrate
+}
+
+func NewMigrator(dsn string) (*Migrator, error) {
+	source, err := iofs.New(db.Migrations, "migrations")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read embedded migrations")
+	}
+
+	sqlDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open a database connection")
+	}
+
+	driver, err := migratePgx.WithInstance(sqlDB, &migratePgx.Config{})
+	if err != nil {
+		_ = sqlDB.Close()
+
+		return nil, errors.Wrap(err, "failed to create database driver for the migration process")
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
+	if err != nil {
+		_ = sqlDB.Close()
+
+		return nil, errors.Wrap(err, "failed to init migrations")
+	}
+
+	m.LockTimeout = migrationLockTimeout
+
+	return &Migrator{migrate: m}, nil
+}
+
+// Up applies all the migrations that haven't been applied yet.
+func (m *Migrator) Up() error {
+	if err := m.migrate.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return errors.Wrap(err, "failed to apply migrations")
+	}
+
+	return nil
+}
+
+// Down rolls back the given number of the last applied migrations.
+func (m *Migrator) Down(steps int) error {
+	if err := m.migrate.Steps(-steps); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return errors.Wrap(err, "failed to roll back migrations")
+	}
+
+	return nil
+}
+
+// DownAll rolls back every applied migration.
+func (m *Migrator) DownAll() error {
+	if err := m.migrate.Down(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return errors.Wrap(err, "failed to roll back migrations")
+	}
+
+	return nil
+}
+
+// Force sets the schema version and clears the dirty flag without running any migration,
+// after a failed migration has been fixed by hand.
+func (m *Migrator) Force(version int) error {
+	if err := m.migrate.Force(version); err != nil {
+		return errors.Wrap(err, "failed to force schema version")
+	}
+
+	return nil
+}
+
+func (m *Migrator) Status() (MigrationStatus, error) {
+	latest, err := db.LatestVersion()
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+
+	status := MigrationStatus{Latest: latest}
+
+	status.Version, status.Dirty, err = m.migrate.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return MigrationStatus{}, errors.Wrap(err, "failed to get schema version")
+	}
+
+	return status, nil
+}
+
+func (m *Migrator) Close() error {
+	sourceErr, dbErr := m.migrate.Close()
+	if sourceErr != nil {
+		return errors.Wrap(sourceErr, "failed to close migrations source")
+	}
+
+	if dbErr != nil {
+		return errors.Wrap(dbErr, "failed to close database connection")
+	}
+
+	return nil
+}
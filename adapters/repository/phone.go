@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+)
+
+const phoneVerificationTableName = "phone_verifications"
+
+var (
+	phoneVerificationColumns = []string{
+		"user_id", "phone_number", "code_hash", "attempts", "expires_at", "created_at",
+	}
+	returningPhoneVerification = "RETURNING " + strings.Join(phoneVerificationColumns, ", ")
+)
+
+// VerifyPhone marks the phone number of the user as verified, unless the user doesn't have that number anymore,
+// in which case ErrPhoneChanged is returned. Verifying a verified number keeps the time of the first verification.
+func (r *PostgresRepository) VerifyPhone(ctx context.Context, id int64, phoneNumber string) (entities.User, error) {
+	defer r.observeQuery("verify_user_phone", time.Now())
+
+	var user entities.User
+
+	stmt := sq.
+		Update(userTableName).
+		Set("phone_verified_at", sq.Expr("COALESCE(phone_verified_at, NOW())")).
+		Set("updated_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": id, "phone_number": phoneNumber, "deleted": false}).
+		Suffix(returningUser).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return user, errors.Wrap(err, "failed to build a query")
+	}
+
+	err = pgxscan.Get(ctx, r.db, &user, sql, args...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return user, entities.ErrPhoneChanged
+		}
+
+		return user, errors.Wrap(err, "failed to execute a query")
+	}
+
+	r.recentWrites.record(ctx)
+
+	return user, loadExternalIDs(ctx, r.db, &user)
+}
+
+// keepPhoneVerification keeps the time the phone number was verified only if the number stays the same.
+func keepPhoneVerification(phoneNumber string) sq.Sqlizer {
+	return sq.Expr("CASE WHEN phone_number = ? THEN phone_verified_at END", phoneNumber)
+}
+
+// SavePhoneVerification stores the code sent to the user, replacing the previous one along with its attempts.
+func (r *PostgresRepository) SavePhoneVerification(
+	ctx context.Context,
+	params entities.PhoneVerification,
+) (entities.PhoneVerification, error) {
+	defer r.observeQuery("save_phone_verification", time.Now())
+
+	var verification entities.PhoneVerification
+
+	stmt := sq.
+		Insert(phoneVerificationTableName).
+		Columns("user_id", "phone_number", "code_hash", "expires_at").
+		Values(params.UserID, params.PhoneNumber, params.CodeHash, params.ExpiresAt.UTC()).
+		Suffix("ON CONFLICT (user_id) DO UPDATE SET " +
+			"phone_number = EXCLUDED.phone_number, code_hash = EXCLUDED.code_hash, attempts = 0, " +
+			"expires_at = EXCLUDED.expires_at, created_at = NOW()").
+		Suffix(returningPhoneVerification).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return verification, errors.Wrap(err, "failed to build a query")
+	}
+
+	err = pgxscan.Get(ctx, r.db, &verification, sql, args...)
+	if err != nil {
+		return verification, errors.Wrap(err, "failed to execute a query")
+	}
+
+	return verification, nil
+}
+
+func (r *PostgresRepository) GetPhoneVerification(
+	ctx context.Context,
+	userID int64,
+) (entities.PhoneVerification, error) {
+	defer r.observeQuery("get_phone_verification", time.Now())
+
+	var verification entities.PhoneVerification
+
+	stmt := sq.
+		Select(phoneVerificationColumns...).
+		From(phoneVerificationTableName).
+		Where(sq.Eq{"user_id": userID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return verification, errors.Wrap(err, "failed to build a query")
+	}
+
+	err = pgxscan.Get(ctx, r.db, &verification, sql, args...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return verification, entities.ErrPhoneVerificationNotFound
+		}
+
+		return verification, errors.Wrap(err, "failed to execute a query")
+	}
+
+	return verification, nil
+}
+
+// CountPhoneVerificationAttempt records an attempt to enter the code sent to the user
+// and returns the verification with the attempt counted.
+func (r *PostgresRepository) CountPhoneVerificationAttempt(
+	ctx context.Context,
+	userID int64,
+) (entities.PhoneVerification, error) {
+	defer r.observeQuery("count_phone_verification_attempt", time.Now())
+
+	var verification entities.PhoneVerification
+
+	stmt := sq.
+		Update(phoneVerificationTableName).
+		Set("attempts", sq.Expr("attempts + 1")).
+		Where(sq.Eq{"user_id": userID}).
+		Suffix(returningPhoneVerification).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return verification, errors.Wrap(err, "failed to build a query")
+	}
+
+	err = pgxscan.Get(ctx, r.db, &verification, sql, args...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return verification, entities.ErrPhoneVerificationNotFound
+		}
+
+		return verification, errors.Wrap(err, "failed to execute a query")
+	}
+
+	return verification, nil
+}
+
+func (r *PostgresRepository) DeletePhoneVerification(ctx context.Context, userID int64) error {
+	defer r.observeQuery("delete_phone_verification", time.Now())
+
+	stmt := sq.
+		Delete(phoneVerificationTableName).
+		Where(sq.Eq{"user_id": userID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build a query")
+	}
+
+	_, err = r.db.Exec(ctx, sql, args...)
+	if err != nil {
+		return errors.Wrap(err, "failed to execute a query")
+	}
+
+	return nil
+}
+
+// PurgePhoneVerifications deletes the codes that have expired without being entered.
+func (r *PostgresRepository) PurgePhoneVerifications(ctx context.Context) error {
+	defer r.observeQuery("purge_phone_verifications", time.Now())
+
+	stmt := sq.
+		Delete(phoneVerificationTableName).
+		Where(sq.LtOrEq{"expires_at": time.Now().UTC()}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build a query")
+	}
+
+	return r.slowStatements(ctx, r.db, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, sql, args...); err != nil {
+			return errors.Wrap(err, "failed to execute a query")
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/torwig/user-service/entities"
+)
+
+// VerifyPhone marks the phone number of the user as verified, unless the user doesn't have that number anymore,
+// in which case ErrPhoneChanged is returned. Verifying a verified number keeps the time of the first verification.
+func (r *InMemoryRepository) VerifyPhone(_ context.Context, id int64, phoneNumber string) (entities.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok || user.Deleted || user.PhoneNumber != phoneNumber {
+		return entities.User{}, entities.ErrPhoneChanged
+	}
+
+	now := r.timestamp()
+	if user.PhoneVerifiedAt == nil {
+		user.PhoneVerifiedAt = &now
+	}
+
+	user.UpdatedAt = now
+
+	r.users[id] = user
+
+	return copyUser(user), nil
+}
+
+// setPhoneNumber changes the phone number of the user, the verification is kept only if the number stays the same.
+func setPhoneNumber(user *entities.User, phoneNumber string) {
+	if user.PhoneNumber != phoneNumber {
+		user.PhoneVerifiedAt = nil
+	}
+
+	user.PhoneNumber = phoneNumber
+}
+
+// SavePhoneVerification stores the code sent to the user, replacing the previous one along with its attempts.
+func (r *InMemoryRepository) SavePhoneVerification(
+	_ context.Context,
+	params entities.PhoneVerification,
+) (entities.PhoneVerification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	verification := entities.PhoneVerification{
+		UserID:      params.UserID,
+		PhoneNumber: params.PhoneNumber,
+		CodeHash:    params.CodeHash,
+		ExpiresAt:   params.ExpiresAt.UTC().Truncate(time.Microsecond),
+		CreatedAt:   r.timestamp(),
+	}
+
+	r.phoneVerifications[verification.UserID] = verification
+
+	return verification, nil
+}
+
+func (r *InMemoryRepository) GetPhoneVerification(
+	_ context.Context,
+	userID int64,
+) (entities.PhoneVerification, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	verification, ok := r.phoneVerifications[userID]
+	if !ok {
+		return entities.PhoneVerification{}, entities.ErrPhoneVerificationNotFound
+	}
+
+	return verification, nil
+}
+
+// CountPhoneVerificationAttempt records an attempt to enter the code sent to the user
+// and returns the verification with the attempt counted.
+func (r *InMemoryRepository) CountPhoneVerificationAttempt(
+	_ context.Context,
+	userID int64,
+) (entities.PhoneVerification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	verification, ok := r.phoneVerifications[userID]
+	if !ok {
+		return entities.PhoneVerification{}, entities.ErrPhoneVerificationNotFound
+	}
+
+	verification.Attempts++
+
+	r.phoneVerifications[userID] = verification
+
+	return verification, nil
+}
+
+func (r *InMemoryRepository) DeletePhoneVerification(_ context.Context, userID int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.phoneVerifications, userID)
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/georgysavva/scany/v2/sqlscan"
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+)
+
+// VerifyPhone marks the phone number of the user as verified, unless the user doesn't have that number anymore,
+// in which case ErrPhoneChanged is returned. Verifying a verified number keeps the time of the first verification.
+func (r *SQLiteRepository) VerifyPhone(ctx context.Context, id int64, phoneNumber string) (entities.User, error) {
+	var user entities.User
+
+	now := r.timestamp()
+
+	stmt := sq.
+		Update(userTableName).
+		Set("phone_verified_at", sq.Expr("COALESCE(phone_verified_at, ?)", now)).
+		Set("updated_at", now).
+		Where(sq.Eq{"id": id, "phone_number": phoneNumber, "deleted": false}).
+		Suffix(returningUser)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return user, errors.Wrap(err, "failed to build a query")
+	}
+
+	user, err = getSQLiteUser(ctx, r.db, sql, args...)
+	if err != nil {
+		if sqlscan.NotFound(err) {
+			return user, entities.ErrPhoneChanged
+		}
+
+		return user, errors.Wrap(err, "failed to execute a query")
+	}
+
+	return user, loadSQLiteExternalIDs(ctx, r.db, &user)
+}
+
+// SavePhoneVerification stores the code sent to the user, replacing the previous one along with its attempts.
+func (r *SQLiteRepository) SavePhoneVerification(
+	ctx context.Context,
+	params entities.PhoneVerification,
+) (entities.PhoneVerification, error) {
+	var verification entities.PhoneVerification
+
+	stmt := sq.
+		Insert(phoneVerificationTableName).
+		Columns("user_id", "phone_number", "code_hash", "expires_at", "created_at").
+		Values(params.UserID, params.PhoneNumber, params.CodeHash, params.ExpiresAt.UTC(), r.timestamp()).
+		Suffix("ON CONFLICT (user_id) DO UPDATE SET " +
+			"phone_number = excluded.phone_number, code_hash = excluded.code_hash, attempts = 0, " +
+			"expires_at = excluded.expires_at, created_at = excluded.created_at").
+		Suffix(returningPhoneVerification)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return verification, errors.Wrap(err, "failed to build a query")
+	}
+
+	err = sqlscan.Get(ctx, r.db, &verification, sql, args...)
+	if err != nil {
+		return verification, errors.Wrap(err, "failed to execute a query")
+	}
+
+	return verification, nil
+}
+
+func (r *SQLiteRepository) GetPhoneVerification(
+	ctx context.Context,
+	userID int64,
+) (entities.PhoneVerification, error) {
+	var verification entities.PhoneVerification
+
+	stmt := sq.
+		Select(phoneVerificationColumns...).
+		From(phoneVerificationTableName).
+		Where(sq.Eq{"user_id": userID})
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return verification, errors.Wrap(err, "failed to build a query")
+	}
+
+	err = sqlscan.Get(ctx, r.db, &verification, sql, args...)
+	if err != nil {
+		if sqlscan.NotFound(err) {
+			return verification, entities.ErrPhoneVerificationNotFound
+		}
+
+		return verification, errors.Wrap(err, "failed to execute a query")
+	}
+
+	return verification, nil
+}
+
+// CountPhoneVerificationAttempt records an attempt to enter the code sent to the user
+// and returns the verification with the attempt counted.
+func (r *SQLiteRepository) CountPhoneVerificationAttempt(
+	ctx context.Context,
+	userID int64,
+) (entities.PhoneVerification, error) {
+	var verification entities.PhoneVerification
+
+	stmt := sq.
+		Update(phoneVerificationTableName).
+		Set("attempts", sq.Expr("attempts + 1")).
+		Where(sq.Eq{"user_id": userID}).
+		Suffix(returningPhoneVerification)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return verification, errors.Wrap(err, "failed to build a query")
+	}
+
+	err = sqlscan.Get(ctx, r.db, &verification, sql, args...)
+	if err != nil {
+		if sqlscan.NotFound(err) {
+			return verification, entities.ErrPhoneVerificationNotFound
+		}
+
+		return verification, errors.Wrap(err, "failed to execute a query")
+	}
+
+	return verification, nil
+}
+
+func (r *SQLiteRepository) DeletePhoneVerification(ctx context.Context, userID int64) error {
+	sql, args, err := sq.Delete(phoneVerificationTableName).Where(sq.Eq{"user_id": userID}).ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build a query")
+	}
+
+	_, err = r.db.ExecContext(ctx, sql, args...)
+	if err != nil {
+		return errors.Wrap(err, "failed to execute a query")
+	}
+
+	return nil
+}
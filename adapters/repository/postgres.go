@@ -2,6 +2,9 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
@@ -13,38 +16,142 @@ import (
 )
 
 const (
-	maxConnLifetime       = time.Hour
-	maxConnLifetimeJitter = time.Minute
-	maxConnIdleTime       = 30 * time.Minute
-	maxPoolConns          = 10
-	minPoolConns          = 2
-	connHealthCheckPeriod = time.Minute
-	databaseConnTimeout   = 5 * time.Second
-	userTableName         = "users"
+	DefaultMaxConnLifetime      = time.Hour
+	DefaultMaxConnIdleTime      = 30 * time.Minute
+	DefaultMaxConns             = 10
+	DefaultHealthCheckPeriod    = time.Minute
+	DefaultConnectTimeout       = 5 * time.Second
+	DefaultReadYourWritesWindow = 5 * time.Second
+	userTableName               = "users"
+)
+
+var (
+	userColumns = []string{
+		"id", "first_name", "last_name", "phone_number", "phone_verified_at", "address", "email", "email_verified_at",
+		"status", "attributes", "deleted", "created_at", "updated_at", "deleted_at",
+	}
+	returningUser = "RETURNING " + strings.Join(userColumns, ", ")
 )
 
 type Config struct {
-	DSN string
+	// Type is the name of the adapter users are kept in, e.g. postgres or memory.
+	Type string
+	DSN  string
+	// AutoMigrate applies the embedded migrations on start.
+	AutoMigrate bool
+
+	// The settings of the connection pool, zero values are replaced with the defaults
+	// except for MinConns and MaxConnLifetimeJitter, where zero is a valid setting.
+	MaxConns              int32
+	MinConns              int32
+	MaxConnLifetime       time.Duration
+	MaxConnLifetimeJitter time.Duration
+	MaxConnIdleTime       time.Duration
+	HealthCheckPeriod     time.Duration
+	ConnectTimeout        time.Duration
+	// StatementTimeout makes Postgres abort every statement that runs longer, zero disables it.
+	StatementTimeout time.Duration
+	// SlowStatementTimeout replaces StatementTimeout for the statements expected to be slow:
+	// listing users and purging expired records. Zero disables it.
+	SlowStatementTimeout time.Duration
+
+	// ReplicaDSN is an optional read replica users are read from.
+	ReplicaDSN string
+	// ReadYourWritesWindow is how long a caller reads users from the primary after their own change,
+	// it should exceed the replication lag.
+	ReadYourWritesWindow time.Duration
+}
+
+func (c Config) withDefaults() Config {
+	if c.MaxConns == 0 {
+		c.MaxConns = DefaultMaxConns
+	}
+	if c.MaxConnLifetime == 0 {
+		c.MaxConnLifetime = DefaultMaxConnLifetime
+	}
+	if c.MaxConnIdleTime == 0 {
+		c.MaxConnIdleTime = DefaultMaxConnIdleTime
+	}
+	if c.HealthCheckPeriod == 0 {
+		c.HealthCheckPeriod = DefaultHealthCheckPeriod
+	}
+	if c.ConnectTimeout == 0 {
+		c.ConnectTimeout = DefaultConnectTimeout
+	}
+	if c.ReadYourWritesWindow == 0 {
+		c.ReadYourWritesWindow = DefaultReadYourWritesWindow
+	}
+
+	return c
+}
+
+type QueryObserver interface {
+	ObserveQuery(operation string, duration time.Duration)
 }
 
 type PostgresRepository struct {
-	db *pgxpool.Pool
+	db                   *pgxpool.Pool
+	replica              *pgxpool.Pool
+	recentWrites         *recentWrites
+	observer             QueryObserver
+	slowStatementTimeout time.Duration
 }
 
-func NewPostgresRepository(repoCfg Config) (*PostgresRepository, error) {
-	cfg, err := pgxpool.ParseConfig(repoCfg.DSN)
+type PostgresOption func(r *PostgresRepository)
+
+// WithQueryObserver reports the latency of every repository operation to the observer.
+func WithQueryObserver(o QueryObserver) PostgresOption {
+	return func(r *PostgresRepository) {
+		r.observer = o
+	}
+}
+
+func NewPostgresRepository(repoCfg Config, options ...PostgresOption) (*PostgresRepository, error) {
+	repoCfg = repoCfg.withDefaults()
+
+	db, err := newPool(repoCfg.DSN, repoCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := &PostgresRepository{
+		db:                   db,
+		recentWrites:         newRecentWrites(repoCfg.ReadYourWritesWindow),
+		slowStatementTimeout: repoCfg.SlowStatementTimeout,
+	}
+
+	if repoCfg.ReplicaDSN != "" {
+		repo.replica, err = newPool(repoCfg.ReplicaDSN, repoCfg)
+		if err != nil {
+			db.Close()
+
+			return nil, errors.Wrap(err, "failed to connect to the read replica")
+		}
+	}
+
+	for _, o := range options {
+		o(repo)
+	}
+
+	return repo, nil
+}
+
+func newPool(dsn string, repoCfg Config) (*pgxpool.Pool, error) {
+	cfg, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse connection string")
 	}
 
-	cfg.MaxConnLifetime = maxConnLifetime
-	cfg.MaxConnLifetimeJitter = maxConnLifetimeJitter
-	cfg.MaxConnIdleTime = maxConnIdleTime
-	cfg.MaxConns = maxPoolConns
-	cfg.MinConns = minPoolConns
-	cfg.HealthCheckPeriod = connHealthCheckPeriod
+	cfg.MaxConnLifetime = repoCfg.MaxConnLifetime
+	cfg.MaxConnLifetimeJitter = repoCfg.MaxConnLifetimeJitter
+	cfg.MaxConnIdleTime = repoCfg.MaxConnIdleTime
+	cfg.MaxConns = repoCfg.MaxConns
+	cfg.MinConns = repoCfg.MinConns
+	cfg.HealthCheckPeriod = repoCfg.HealthCheckPeriod
+	cfg.ConnConfig.Tracer = queryTracer{}
+	cfg.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(repoCfg.StatementTimeout.Milliseconds(), 10)
 
-	ctx, cancel := context.WithTimeout(context.Background(), databaseConnTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), repoCfg.ConnectTimeout)
 	defer cancel()
 
 	conn, err := pgxpool.NewWithConfig(ctx, cfg)
@@ -54,24 +161,82 @@ func NewPostgresRepository(repoCfg Config) (*PostgresRepository, error) {
 
 	err = conn.Ping(ctx)
 	if err != nil {
+		conn.Close()
+
 		return nil, errors.Wrap(err, "failed to ping the database")
 	}
 
-	return &PostgresRepository{db: conn}, nil
+	return conn, nil
+}
+
+// slowStatements runs fn in a transaction whose statements are limited by the slow statement timeout
+// rather than the statement timeout of the pool.
+func (r *PostgresRepository) slowStatements(ctx context.Context, db *pgxpool.Pool, fn func(tx pgx.Tx) error) error {
+	return pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+		timeout := strconv.FormatInt(r.slowStatementTimeout.Milliseconds(), 10)
+
+		if _, err := tx.Exec(ctx, "SELECT set_config('statement_timeout', $1, true)", timeout); err != nil {
+			return errors.Wrap(err, "failed to set statement timeout")
+		}
+
+		return fn(tx)
+	})
 }
 
 func (r *PostgresRepository) Close() {
 	r.db.Close()
+
+	if r.replica != nil {
+		r.replica.Close()
+	}
+}
+
+// Stat returns statistics of the connection pool.
+func (r *PostgresRepository) Stat() *pgxpool.Stat {
+	return r.db.Stat()
+}
+
+func (r *PostgresRepository) observeQuery(operation string, start time.Time) {
+	if r.observer != nil {
+		r.observer.ObserveQuery(operation, time.Since(start))
+	}
 }
 
 func (r *PostgresRepository) Create(ctx context.Context, params entities.CreateUserParams) (entities.User, error) {
+	defer r.observeQuery("create_user", time.Now())
+
+	var user entities.User
+
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		var err error
+		user, err = insertUser(ctx, tx, params)
+
+		return err
+	})
+	if err != nil {
+		return user, err
+	}
+
+	r.recentWrites.record(ctx)
+
+	return user, nil
+}
+
+// insertUser creates the user along with its external IDs, the ID is given by the sequence.
+func insertUser(ctx context.Context, tx pgx.Tx, params entities.CreateUserParams) (entities.User, error) {
 	var user entities.User
 
+	attributes, err := encodeAttributes(params.Attributes)
+	if err != nil {
+		return user, err
+	}
+
 	stmt := sq.
 		Insert(userTableName).
-		Columns("first_name", "last_name", "phone_number", "address").
-		Values(params.FirstName, params.LastName, params.PhoneNumber, params.Address).
-		Suffix("RETURNING id, first_name, last_name, phone_number, address, deleted, created_at, deleted_at").
+		Columns("first_name", "last_name", "phone_number", "address", "email", "status", "attributes").
+		Values(params.FirstName, params.LastName, params.PhoneNumber, params.Address, params.Email,
+			initialStatus(params.Status), attributes).
+		Suffix(returningUser).
 		PlaceholderFormat(sq.Dollar)
 
 	sql, args, err := stmt.ToSql()
@@ -79,19 +244,24 @@ func (r *PostgresRepository) Create(ctx context.Context, params entities.CreateU
 		return user, errors.Wrap(err, "failed to build a query")
 	}
 
-	err = pgxscan.Get(ctx, r.db, &user, sql, args...)
-	if err != nil {
+	if err := pgxscan.Get(ctx, tx, &user, sql, args...); err != nil {
+		if isEmailTaken(err) {
+			return user, entities.ErrEmailTaken
+		}
+
 		return user, errors.Wrap(err, "failed to execute a query")
 	}
 
-	return user, nil
+	return user, setExternalIDs(ctx, tx, &user, params.ExternalIDs)
 }
 
 func (r *PostgresRepository) Get(ctx context.Context, id int64) (entities.User, error) {
+	defer r.observeQuery("get_user", time.Now())
+
 	var user entities.User
 
 	stmt := sq.
-		Select("id", "first_name", "last_name", "phone_number", "address", "deleted", "created_at", "deleted_at").
+		Select(userColumns...).
 		From(userTableName).
 		Where(sq.Eq{"id": id}).
 		PlaceholderFormat(sq.Dollar)
@@ -101,7 +271,9 @@ func (r *PostgresRepository) Get(ctx context.Context, id int64) (entities.User,
 		return user, errors.Wrap(err, "failed to build a query")
 	}
 
-	err = pgxscan.Get(ctx, r.db, &user, sql, args...)
+	reader := r.reader(ctx)
+
+	err = pgxscan.Get(ctx, reader, &user, sql, args...)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return user, entities.ErrUserNotFound
@@ -110,7 +282,7 @@ func (r *PostgresRepository) Get(ctx context.Context, id int64) (entities.User,
 		return user, errors.Wrap(err, "failed to execute a query")
 	}
 
-	return user, nil
+	return user, loadExternalIDs(ctx, reader, &user)
 }
 
 func (r *PostgresRepository) Update(
@@ -118,6 +290,8 @@ func (r *PostgresRepository) Update(
 	id int64,
 	params entities.UpdateUserParams,
 ) (entities.User, error) {
+	defer r.observeQuery("update_user", time.Now())
+
 	nothingToUpdate := params == entities.UpdateUserParams{}
 	if nothingToUpdate {
 		return r.Get(ctx, id)
@@ -125,24 +299,135 @@ func (r *PostgresRepository) Update(
 
 	var user entities.User
 
-	stmt := sq.
-		Update(userTableName)
-	if params.FirstName != nil {
-		stmt = stmt.Set("first_name", *params.FirstName)
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		stmt := sq.
+			Update(userTableName)
+		if params.FirstName != nil {
+			stmt = stmt.Set("first_name", *params.FirstName)
+		}
+		if params.LastName != nil {
+			stmt = stmt.Set("last_name", *params.LastName)
+		}
+		if params.PhoneNumber != nil {
+			stmt = stmt.
+				Set("phone_number", *params.PhoneNumber).
+				Set("phone_verified_at", keepPhoneVerification(*params.PhoneNumber))
+		}
+		if params.Address != nil {
+			stmt = stmt.Set("address", *params.Address)
+		}
+		if params.Email != nil {
+			stmt = stmt.
+				Set("email", *params.Email).
+				Set("email_verified_at", keepEmailVerification(*params.Email))
+		}
+		if params.Attributes != nil {
+			attributes, err := patchAttributes(ctx, tx, id, *params.Attributes)
+			if err != nil {
+				return err
+			}
+
+			stmt = stmt.Set("attributes", attributes)
+		}
+
+		stmt = stmt.
+			Set("updated_at", sq.Expr("NOW()")).
+			Where(sq.Eq{"id": id}).
+			Suffix(returningUser).
+			PlaceholderFormat(sq.Dollar)
+
+		sql, args, err := stmt.ToSql()
+		if err != nil {
+			return errors.Wrap(err, "failed to build a query")
+		}
+
+		if err := pgxscan.Get(ctx, tx, &user, sql, args...); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return entities.ErrUserNotFound
+			}
+
+			if isEmailTaken(err) {
+				return entities.ErrEmailTaken
+			}
+
+			return errors.Wrap(err, "failed to execute a query")
+		}
+
+		if params.ExternalIDs != nil {
+			return setExternalIDs(ctx, tx, &user, *params.ExternalIDs)
+		}
+
+		return loadExternalIDs(ctx, tx, &user)
+	})
+	if err != nil {
+		return user, err
 	}
-	if params.LastName != nil {
-		stmt = stmt.Set("last_name", *params.LastName)
+
+	r.recentWrites.record(ctx)
+
+	return user, nil
+}
+
+// patchAttributes returns the attributes of the user with the patch applied. The user is locked until
+// the end of the transaction, so that concurrent patches don't undo each other.
+func patchAttributes(ctx context.Context, tx pgx.Tx, id int64, patch entities.Attributes) (string, error) {
+	var attributes entities.Attributes
+
+	err := tx.QueryRow(ctx, "SELECT attributes FROM "+userTableName+" WHERE id = $1 FOR UPDATE", id).
+		Scan(&attributes)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", entities.ErrUserNotFound
+		}
+
+		return "", errors.Wrap(err, "failed to execute a query")
 	}
-	if params.PhoneNumber != nil {
-		stmt = stmt.Set("phone_number", *params.PhoneNumber)
+
+	return encodeAttributes(entities.MergeAttributes(attributes, patch))
+}
+
+// Replace sets all the mutable fields of the user.
+func (r *PostgresRepository) Replace(
+	ctx context.Context,
+	id int64,
+	params entities.ReplaceUserParams,
+) (entities.User, error) {
+	defer r.observeQuery("replace_user", time.Now())
+
+	var user entities.User
+
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		var err error
+		user, err = replaceUser(ctx, tx, id, params)
+
+		return err
+	})
+	if err != nil {
+		return user, err
 	}
-	if params.Address != nil {
-		stmt = stmt.Set("address", *params.Address)
+
+	r.recentWrites.record(ctx)
+
+	return user, nil
+}
+
+// replaceUser sets all the mutable fields of the user along with its external IDs.
+func replaceUser(ctx context.Context, tx pgx.Tx, id int64, params entities.ReplaceUserParams) (entities.User, error) {
+	var user entities.User
+
+	values, err := replaceUserValues(params)
+	if err != nil {
+		return user, err
 	}
 
-	stmt = stmt.
+	stmt := sq.
+		Update(userTableName).
+		SetMap(values).
+		Set("phone_verified_at", keepPhoneVerification(params.PhoneNumber)).
+		Set("email_verified_at", keepEmailVerification(params.Email)).
+		Set("updated_at", sq.Expr("NOW()")).
 		Where(sq.Eq{"id": id}).
-		Suffix("RETURNING id, first_name, last_name, phone_number, address, deleted, created_at, deleted_at").
+		Suffix(returningUser).
 		PlaceholderFormat(sq.Dollar)
 
 	sql, args, err := stmt.ToSql()
@@ -150,23 +435,164 @@ func (r *PostgresRepository) Update(
 		return user, errors.Wrap(err, "failed to build a query")
 	}
 
-	err = pgxscan.Get(ctx, r.db, &user, sql, args...)
-	if err != nil {
+	if err := pgxscan.Get(ctx, tx, &user, sql, args...); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return user, entities.ErrUserNotFound
 		}
 
+		if isEmailTaken(err) {
+			return user, entities.ErrEmailTaken
+		}
+
 		return user, errors.Wrap(err, "failed to execute a query")
 	}
 
-	return user, err
+	return user, setExternalIDs(ctx, tx, &user, params.ExternalIDs)
 }
 
-func (r *PostgresRepository) Delete(ctx context.Context, id int64) error {
+// Upsert replaces the user the external ID belongs to or creates a new user with it, the flag reports whether
+// the user was created. A deleted user isn't replaced, ErrUserDeleted is returned.
+func (r *PostgresRepository) Upsert(
+	ctx context.Context,
+	externalID entities.ExternalID,
+	params entities.ReplaceUserParams,
+) (entities.User, bool, error) {
+	defer r.observeQuery("upsert_user", time.Now())
+
+	var (
+		user    entities.User
+		created bool
+	)
+
+	params.ExternalIDs = entities.WithExternalID(params.ExternalIDs, externalID)
+
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		// concurrent upserts of the same external ID wait for each other, so that only one of them creates the user
+		_, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtextextended($1, 0))",
+			externalID.Source+"/"+externalID.ID)
+		if err != nil {
+			return errors.Wrap(err, "failed to execute a query")
+		}
+
+		var (
+			id      int64
+			deleted bool
+		)
+
+		err = tx.QueryRow(ctx, "SELECT u.id, u.deleted FROM "+userTableName+" u "+
+			"JOIN "+userExternalIDTableName+" e ON e.user_id = u.id WHERE e.source = $1 AND e.external_id = $2",
+			externalID.Source, externalID.ID).Scan(&id, &deleted)
+
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			created = true
+			user, err = insertUser(ctx, tx, params)
+		case err != nil:
+			return errors.Wrap(err, "failed to execute a query")
+		case deleted:
+			return entities.ErrUserDeleted
+		default:
+			user, err = replaceUser(ctx, tx, id, params)
+		}
+
+		return err
+	})
+	if err != nil {
+		return user, false, err
+	}
+
+	r.recentWrites.record(ctx)
+
+	return user, created, nil
+}
+
+func replaceUserValues(params entities.ReplaceUserParams) (map[string]any, error) {
+	attributes, err := encodeAttributes(params.Attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		"first_name":   params.FirstName,
+		"last_name":    params.LastName,
+		"phone_number": params.PhoneNumber,
+		"address":      params.Address,
+		"email":        params.Email,
+		"attributes":   attributes,
+	}, nil
+}
+
+// encodeAttributes returns the attributes as a JSON object, which is how both Postgres and SQLite take them.
+func encodeAttributes(attributes entities.Attributes) (string, error) {
+	if attributes == nil {
+		return "{}", nil
+	}
+
+	encoded, err := json.Marshal(attributes)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode attributes")
+	}
+
+	return string(encoded), nil
+}
+
+func (r *PostgresRepository) List(ctx context.Context, params entities.ListUsersParams) ([]entities.User, error) {
+	defer r.observeQuery("list_users", time.Now())
+
+	var users []entities.User
+
+	stmt := sq.
+		Select(userColumns...).
+		From(userTableName).
+		Where(sq.Gt{"id": params.AfterID}).
+		OrderBy("id").
+		Limit(params.Limit).
+		PlaceholderFormat(sq.Dollar)
+	if !params.IncludeDeleted {
+		stmt = stmt.Where(sq.Eq{"deleted": false})
+	}
+	if len(params.Attributes) > 0 {
+		filter, err := encodeAttributes(params.Attributes)
+		if err != nil {
+			return nil, err
+		}
+
+		// containment of scalar values is their equality, and it's served by the GIN index
+		stmt = stmt.Where("attributes @> ?", filter)
+	}
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build a query")
+	}
+
+	err = r.slowStatements(ctx, r.reader(ctx), func(tx pgx.Tx) error {
+		if err := pgxscan.Select(ctx, tx, &users, sql, args...); err != nil {
+			return errors.Wrap(err, "failed to execute a query")
+		}
+
+		return loadExternalIDs(ctx, tx, userPointers(users)...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (r *PostgresRepository) Delete(ctx context.Context, id int64, cause entities.StatusChangeCause) error {
+	defer r.observeQuery("delete_user", time.Now())
+
 	stmt := sq.
 		Update(userTableName).
 		Set("deleted", true).
-		Where(sq.Eq{"id": id}).
+		Set("status_before_delete", sq.Expr("status")).
+		Set("status", entities.UserStatusDeleted).
+		Set("deleted_at", sq.Expr("NOW()")).
+		Set("updated_at", sq.Expr("NOW()")).
+		// deleting already deleted user keeps the time of the deletion
+		Where(sq.Eq{"id": id, "deleted": false}).
+		Suffix("RETURNING status_before_delete").
 		PlaceholderFormat(sq.Dollar)
 
 	sql, args, err := stmt.ToSql()
@@ -174,10 +600,87 @@ func (r *PostgresRepository) Delete(ctx context.Context, id int64) error {
 		return errors.Wrap(err, "failed to build a query")
 	}
 
-	_, err = r.db.Exec(ctx, sql, args...)
+	err = pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		var from entities.UserStatus
+
+		if err := tx.QueryRow(ctx, sql, args...).Scan(&from); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil
+			}
+
+			return errors.Wrap(err, "failed to execute a query")
+		}
+
+		return insertStatusChange(ctx, tx, id, from, entities.UserStatusDeleted, cause)
+	})
 	if err != nil {
-		return errors.Wrap(err, "failed to execute a query")
+		return err
 	}
 
+	r.recentWrites.record(ctx)
+
 	return nil
 }
+
+func (r *PostgresRepository) Restore(
+	ctx context.Context,
+	id int64,
+	cause entities.StatusChangeCause,
+) (entities.User, error) {
+	defer r.observeQuery("restore_user", time.Now())
+
+	var user entities.User
+
+	stmt := sq.
+		Update(userTableName).
+		Set("deleted", false).
+		Set("status", restoredStatus()).
+		Set("status_before_delete", nil).
+		Set("deleted_at", nil).
+		Set("updated_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": id}).
+		Suffix(returningUser).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return user, errors.Wrap(err, "failed to build a query")
+	}
+
+	err = pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		var deleted bool
+
+		err := tx.QueryRow(ctx, "SELECT deleted FROM "+userTableName+" WHERE id = $1 FOR UPDATE", id).Scan(&deleted)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return entities.ErrUserNotFound
+			}
+
+			return errors.Wrap(err, "failed to execute a query")
+		}
+
+		if err := pgxscan.Get(ctx, tx, &user, sql, args...); err != nil {
+			// another user has taken the email since the deletion
+			if isEmailTaken(err) {
+				return entities.ErrEmailTaken
+			}
+
+			return errors.Wrap(err, "failed to execute a query")
+		}
+
+		if deleted {
+			if err := insertStatusChange(ctx, tx, id, entities.UserStatusDeleted, user.Status, cause); err != nil {
+				return err
+			}
+		}
+
+		return loadExternalIDs(ctx, tx, &user)
+	})
+	if err != nil {
+		return user, err
+	}
+
+	r.recentWrites.record(ctx)
+
+	return user, nil
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+)
+
+const rateLimitTableName = "rate_limit_buckets"
+
+// TakeToken implements a token bucket shared by all replicas of the service.
+// The row of the bucket is locked for the duration of the transaction, and the database clock
+// is used so that replicas with skewed clocks don't disagree about the refill.
+func (r *PostgresRepository) TakeToken(
+	ctx context.Context,
+	key string,
+	limit entities.RateLimit,
+) (entities.RateLimitDecision, error) {
+	defer r.observeQuery("take_rate_limit_token", time.Now())
+
+	var decision entities.RateLimitDecision
+
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		insertSQL, insertArgs, err := sq.
+			Insert(rateLimitTableName).
+			Columns("key", "tokens", "updated_at").
+			Values(key, limit.Capacity(), sq.Expr("LOCALTIMESTAMP")).
+			Suffix("ON CONFLICT (key) DO NOTHING").
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+		if err != nil {
+			return errors.Wrap(err, "failed to build a query")
+		}
+
+		if _, err = tx.Exec(ctx, insertSQL, insertArgs...); err != nil {
+			return errors.Wrap(err, "failed to create a bucket")
+		}
+
+		selectSQL, selectArgs, err := sq.
+			Select("tokens", "updated_at", "LOCALTIMESTAMP").
+			From(rateLimitTableName).
+			Where(sq.Eq{"key": key}).
+			Suffix("FOR UPDATE").
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+		if err != nil {
+			return errors.Wrap(err, "failed to build a query")
+		}
+
+		var (
+			tokens    float64
+			updatedAt time.Time
+			now       time.Time
+		)
+
+		if err = tx.QueryRow(ctx, selectSQL, selectArgs...).Scan(&tokens, &updatedAt, &now); err != nil {
+			return errors.Wrap(err, "failed to lock a bucket")
+		}
+
+		tokens, decision = limit.TakeToken(tokens, updatedAt, now)
+
+		updateSQL, updateArgs, err := sq.
+			Update(rateLimitTableName).
+			Set("tokens", tokens).
+			Set("updated_at", sq.Expr("LOCALTIMESTAMP")).
+			Where(sq.Eq{"key": key}).
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+		if err != nil {
+			return errors.Wrap(err, "failed to build a query")
+		}
+
+		if _, err = tx.Exec(ctx, updateSQL, updateArgs...); err != nil {
+			return errors.Wrap(err, "failed to update a bucket")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return decision, errors.Wrap(err, "failed to execute a transaction")
+	}
+
+	return decision, nil
+}
+
+// PurgeRateLimitBuckets removes buckets that haven't been used for the given time.
+func (r *PostgresRepository) PurgeRateLimitBuckets(ctx context.Context, unusedFor time.Duration) error {
+	defer r.observeQuery("purge_rate_limit_buckets", time.Now())
+
+	stmt := sq.
+		Delete(rateLimitTableName).
+		Where("updated_at < LOCALTIMESTAMP - ?::interval", unusedFor).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build a query")
+	}
+
+	return r.slowStatements(ctx, r.db, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, sql, args...); err != nil {
+			return errors.Wrap(err, "failed to execute a query")
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/torwig/user-service/entities"
+)
+
+const rateLimitBucketsSweepInterval = time.Minute
+
+type rateLimitBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	limit     entities.RateLimit
+}
+
+// InMemoryRateLimitStore keeps token buckets in the memory of a single process.
+type InMemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]rateLimitBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewInMemoryRateLimitStore() *InMemoryRateLimitStore {
+	return &InMemoryRateLimitStore{
+		buckets:   make(map[string]rateLimitBucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (s *InMemoryRateLimitStore) TakeToken(
+	_ context.Context,
+	key string,
+	limit entities.RateLimit,
+) (entities.RateLimitDecision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = rateLimitBucket{tokens: float64(limit.Capacity()), updatedAt: now}
+	}
+
+	tokens, decision := limit.TakeToken(bucket.tokens, bucket.updatedAt, now)
+	s.buckets[key] = rateLimitBucket{tokens: tokens, updatedAt: now, limit: limit}
+
+	if now.Sub(s.lastSweep) >= rateLimitBucketsSweepInterval {
+		s.sweep(now)
+	}
+
+	return decision, nil
+}
+
+// sweep forgets buckets that have been refilled completely since they were used last time.
+func (s *InMemoryRateLimitStore) sweep(now time.Time) {
+	for key, bucket := range s.buckets {
+		_, decision := bucket.limit.TakeToken(bucket.tokens, bucket.updatedAt, now)
+		if decision.Remaining+1 >= bucket.limit.Capacity() {
+			delete(s.buckets, key)
+		}
+	}
+
+	s.lastSweep = now
+}
@@ -0,0 +1,97 @@
+package repository_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torwig/user-service/entities"
+)
+
+func TestPostgresRepository_TakeToken(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Refill", func(t *testing.T) {
+		limit := entities.RateLimit{Requests: 2, Period: 200 * time.Millisecond}
+
+		for i := 0; i < 2; i++ {
+			decision, err := repo.TakeToken(ctx, "refill", limit)
+			require.NoError(t, err)
+			require.True(t, decision.Allowed)
+			assert.Equal(t, 2, decision.Limit)
+			assert.Equal(t, 1-i, decision.Remaining)
+		}
+
+		decision, err := repo.TakeToken(ctx, "refill", limit)
+		require.NoError(t, err)
+		require.False(t, decision.Allowed)
+		assert.Positive(t, decision.RetryAfter)
+		assert.LessOrEqual(t, decision.RetryAfter, 100*time.Millisecond)
+
+		time.Sleep(150 * time.Millisecond)
+
+		decision, err = repo.TakeToken(ctx, "refill", limit)
+		require.NoError(t, err)
+		assert.True(t, decision.Allowed)
+	})
+
+	t.Run("Capacity", func(t *testing.T) {
+		limit := entities.RateLimit{Requests: 2, Period: 100 * time.Millisecond, Burst: 3}
+
+		decision, err := repo.TakeToken(ctx, "capacity", limit)
+		require.NoError(t, err)
+		require.True(t, decision.Allowed)
+		assert.Equal(t, 3, decision.Limit)
+		assert.Equal(t, 2, decision.Remaining)
+
+		// the bucket is refilled up to the burst rather than by all the time that has passed
+		time.Sleep(500 * time.Millisecond)
+
+		decision, err = repo.TakeToken(ctx, "capacity", limit)
+		require.NoError(t, err)
+		require.True(t, decision.Allowed)
+		assert.Equal(t, 2, decision.Remaining)
+
+		// a lowered limit applies to the tokens left in the bucket
+		decision, err = repo.TakeToken(ctx, "capacity", entities.RateLimit{Requests: 1, Period: time.Hour})
+		require.NoError(t, err)
+		require.True(t, decision.Allowed)
+		assert.Equal(t, 1, decision.Limit)
+		assert.Equal(t, 0, decision.Remaining)
+	})
+
+	t.Run("Concurrent takes", func(t *testing.T) {
+		limit := entities.RateLimit{Requests: 10, Period: time.Hour}
+
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			allowed int
+		)
+
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				decision, err := repo.TakeToken(ctx, "concurrent", limit)
+				assert.NoError(t, err)
+
+				if decision.Allowed {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		// the row lock keeps concurrent takes from spending the same token
+		assert.Equal(t, 10, allowed)
+	})
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/torwig/user-service/entities"
+)
+
+// maxTrackedWriters bounds the number of callers remembered between sweeps of expired writes.
+const maxTrackedWriters = 10_000
+
+// recentWrites remembers when callers changed data, so that for a while after their own write
+// they read from the primary rather than from a replica that may lag behind.
+type recentWrites struct {
+	mu     sync.Mutex
+	window time.Duration
+	writes map[int64]time.Time
+}
+
+func newRecentWrites(window time.Duration) *recentWrites {
+	return &recentWrites{window: window, writes: make(map[int64]time.Time)}
+}
+
+func (w *recentWrites) record(ctx context.Context) {
+	caller, ok := entities.CallerFromContext(ctx)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.writes) >= maxTrackedWriters {
+		for id, at := range w.writes {
+			if now.Sub(at) >= w.window {
+				delete(w.writes, id)
+			}
+		}
+	}
+
+	w.writes[caller] = now
+}
+
+func (w *recentWrites) isRecentWriter(ctx context.Context) bool {
+	caller, ok := entities.CallerFromContext(ctx)
+	if !ok {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	at, ok := w.writes[caller]
+
+	return ok && time.Since(at) < w.window
+}
+
+// reader returns the pool reads of users go to: the replica if there is one,
+// unless the caller has written recently or the primary is asked for.
+func (r *PostgresRepository) reader(ctx context.Context) *pgxpool.Pool {
+	if r.replica == nil || entities.IsPrimaryRead(ctx) || r.recentWrites.isRecentWriter(ctx) {
+		return r.db
+	}
+
+	return r.replica
+}
@@ -0,0 +1,786 @@
+// Package repositorytest implements tests every user repository must pass, so that the adapters
+// can replace each other.
+package repositorytest
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torwig/user-service/entities"
+	"github.com/torwig/user-service/service"
+)
+
+const missingUserID = 999_999_999
+
+// TestUserRepository runs the conformance tests against repo. The repository may already contain users,
+// but nothing else may change it while the tests run.
+func TestUserRepository(t *testing.T, repo service.UserRepository) {
+	t.Run("Create", func(t *testing.T) { testCreate(t, repo) })
+	t.Run("Get", func(t *testing.T) { testGet(t, repo) })
+	t.Run("Update", func(t *testing.T) { testUpdate(t, repo) })
+	t.Run("Delete", func(t *testing.T) { testDelete(t, repo) })
+	t.Run("List", func(t *testing.T) { testList(t, repo) })
+	t.Run("Restore", func(t *testing.T) { testRestore(t, repo) })
+	t.Run("Replace", func(t *testing.T) { testReplace(t, repo) })
+	t.Run("Upsert", func(t *testing.T) { testUpsert(t, repo) })
+	t.Run("External IDs", func(t *testing.T) { testExternalIDs(t, repo) })
+	t.Run("Attributes", func(t *testing.T) { testAttributes(t, repo) })
+	t.Run("Email", func(t *testing.T) { testEmail(t, repo) })
+	t.Run("Phone", func(t *testing.T) { testPhone(t, repo) })
+	t.Run("Status", func(t *testing.T) { testStatus(t, repo) })
+	t.Run("Concurrent creates", func(t *testing.T) { testConcurrentCreates(t, repo) })
+}
+
+func testCreate(t *testing.T, repo service.UserRepository) {
+	userParams := entities.CreateUserParams{
+		FirstName:   "John",
+		LastName:    "Wick",
+		PhoneNumber: "+1234567890",
+		Address:     "New York, 123 Lincoln Square",
+	}
+
+	createdUser, err := repo.Create(context.Background(), userParams)
+	require.NoError(t, err)
+	assert.Greater(t, createdUser.ID, int64(0))
+
+	assert.False(t, createdUser.IsDeleted())
+	assert.Equal(t, userParams.FirstName, createdUser.FirstName)
+	assert.Equal(t, userParams.LastName, createdUser.LastName)
+	assert.Equal(t, userParams.PhoneNumber, createdUser.PhoneNumber)
+	assert.Equal(t, userParams.Address, createdUser.Address)
+	assert.False(t, createdUser.CreatedAt.IsZero())
+	assert.True(t, createdUser.CreatedAt.Equal(createdUser.UpdatedAt), "new user must be updated when created")
+	assert.Nil(t, createdUser.DeletedAt)
+}
+
+func testGet(t *testing.T, repo service.UserRepository) {
+	t.Run("Get non-existing user", func(t *testing.T) {
+		_, err := repo.Get(context.Background(), missingUserID)
+		require.ErrorIs(t, err, entities.ErrUserNotFound)
+	})
+
+	t.Run("Get existing user", func(t *testing.T) {
+		userParams := entities.CreateUserParams{
+			FirstName:   "Mike",
+			LastName:    "Brown",
+			PhoneNumber: "+0987654321",
+			Address:     "Los Angeles, 17 Beach Road",
+		}
+
+		createdUser, err := repo.Create(context.Background(), userParams)
+		require.NoError(t, err)
+
+		foundUser, err := repo.Get(context.Background(), createdUser.ID)
+		require.NoError(t, err)
+		assert.False(t, foundUser.IsDeleted())
+		assert.Equal(t, userParams.FirstName, foundUser.FirstName)
+		assert.Equal(t, userParams.LastName, foundUser.LastName)
+		assert.Equal(t, userParams.PhoneNumber, foundUser.PhoneNumber)
+		assert.Equal(t, userParams.Address, foundUser.Address)
+		assert.True(t, createdUser.CreatedAt.Equal(foundUser.CreatedAt))
+	})
+}
+
+func testUpdate(t *testing.T, repo service.UserRepository) {
+	t.Run("Update non-existing user", func(t *testing.T) {
+		updateParams := entities.UpdateUserParams{
+			Address: stringPtr("Some address"),
+		}
+
+		_, err := repo.Update(context.Background(), missingUserID, updateParams)
+		require.ErrorIs(t, err, entities.ErrUserNotFound)
+	})
+
+	t.Run("Update with no fields set", func(t *testing.T) {
+		userParams := entities.CreateUserParams{
+			FirstName:   "Amy",
+			LastName:    "Pink",
+			PhoneNumber: "+4785692130",
+			Address:     "Seattle, 555 Park Square",
+		}
+
+		createdUser, err := repo.Create(context.Background(), userParams)
+		require.NoError(t, err)
+
+		updatedUser, err := repo.Update(context.Background(), createdUser.ID, entities.UpdateUserParams{})
+		require.NoError(t, err)
+		assert.Equal(t, userParams.FirstName, updatedUser.FirstName)
+		assert.Equal(t, userParams.LastName, updatedUser.LastName)
+		assert.Equal(t, userParams.PhoneNumber, updatedUser.PhoneNumber)
+		assert.Equal(t, userParams.Address, updatedUser.Address)
+	})
+
+	t.Run("Update existing user", func(t *testing.T) {
+		userParams := entities.CreateUserParams{
+			FirstName:   "Jackie",
+			LastName:    "Black",
+			PhoneNumber: "+123987456",
+			Address:     "Washington, 321 Central Street",
+		}
+
+		createdUser, err := repo.Create(context.Background(), userParams)
+		require.NoError(t, err)
+
+		updateParams := entities.UpdateUserParams{
+			PhoneNumber: stringPtr("Different phone"),
+			Address:     stringPtr("Different address"),
+		}
+
+		updatedUser, err := repo.Update(context.Background(), createdUser.ID, updateParams)
+		require.NoError(t, err)
+		assert.Equal(t, userParams.FirstName, updatedUser.FirstName)
+		assert.Equal(t, userParams.LastName, updatedUser.LastName)
+		assert.Equal(t, *updateParams.PhoneNumber, updatedUser.PhoneNumber)
+		assert.Equal(t, *updateParams.Address, updatedUser.Address)
+		assert.False(t, updatedUser.UpdatedAt.Before(createdUser.UpdatedAt))
+
+		foundUser, err := repo.Get(context.Background(), createdUser.ID)
+		require.NoError(t, err)
+		assert.Equal(t, updatedUser, foundUser)
+	})
+}
+
+func testDelete(t *testing.T, repo service.UserRepository) {
+	t.Run("Delete non-existing user", func(t *testing.T) {
+		err := repo.Delete(context.Background(), missingUserID, entities.StatusChangeCause{})
+		require.NoError(t, err)
+	})
+
+	t.Run("Delete existing user", func(t *testing.T) {
+		userParams := entities.CreateUserParams{
+			FirstName:   "Robert",
+			LastName:    "Speed",
+			PhoneNumber: "+3621478950",
+			Address:     "Miami, 777 Star Avenue",
+		}
+
+		createdUser, err := repo.Create(context.Background(), userParams)
+		require.NoError(t, err)
+
+		err = repo.Delete(context.Background(), createdUser.ID, entities.StatusChangeCause{})
+		require.NoError(t, err)
+
+		// users are deleted softly, so they can still be read
+		deletedUser, err := repo.Get(context.Background(), createdUser.ID)
+		require.NoError(t, err)
+		assert.True(t, deletedUser.IsDeleted())
+		require.NotNil(t, deletedUser.DeletedAt)
+		assert.True(t, deletedUser.DeletedAt.Equal(deletedUser.UpdatedAt))
+
+		// deleting already deleted user returns no error and keeps the time of the deletion
+		err = repo.Delete(context.Background(), createdUser.ID, entities.StatusChangeCause{})
+		require.NoError(t, err)
+
+		deletedAgainUser, err := repo.Get(context.Background(), createdUser.ID)
+		require.NoError(t, err)
+		require.NotNil(t, deletedAgainUser.DeletedAt)
+		assert.True(t, deletedUser.DeletedAt.Equal(*deletedAgainUser.DeletedAt))
+		assert.True(t, deletedUser.UpdatedAt.Equal(deletedAgainUser.UpdatedAt))
+	})
+}
+
+func testList(t *testing.T, repo service.UserRepository) {
+	ctx := context.Background()
+
+	first, err := repo.Create(ctx, entities.CreateUserParams{
+		FirstName: "Ann", LastName: "Lee", PhoneNumber: "+1000000001", Address: "Boston",
+	})
+	require.NoError(t, err)
+
+	second, err := repo.Create(ctx, entities.CreateUserParams{
+		FirstName: "Bob", LastName: "Lee", PhoneNumber: "+1000000002", Address: "Boston",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, repo.Delete(ctx, second.ID, entities.StatusChangeCause{}))
+
+	users, err := repo.List(ctx, entities.ListUsersParams{AfterID: first.ID - 1, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, first.ID, users[0].ID)
+
+	users, err = repo.List(ctx, entities.ListUsersParams{AfterID: first.ID - 1, Limit: 10, IncludeDeleted: true})
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	assert.Equal(t, second.ID, users[1].ID)
+
+	users, err = repo.List(ctx, entities.ListUsersParams{AfterID: first.ID, Limit: 10, IncludeDeleted: true})
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, second.ID, users[0].ID)
+
+	users, err = repo.List(ctx, entities.ListUsersParams{AfterID: first.ID - 1, Limit: 1, IncludeDeleted: true})
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, first.ID, users[0].ID)
+
+	users, err = repo.List(ctx, entities.ListUsersParams{AfterID: second.ID, Limit: 10, IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.Empty(t, users)
+}
+
+func testRestore(t *testing.T, repo service.UserRepository) {
+	t.Run("Restore non-existing user", func(t *testing.T) {
+		_, err := repo.Restore(context.Background(), missingUserID, entities.StatusChangeCause{})
+		require.ErrorIs(t, err, entities.ErrUserNotFound)
+	})
+
+	t.Run("Restore deleted user", func(t *testing.T) {
+		createdUser, err := repo.Create(context.Background(), entities.CreateUserParams{
+			FirstName: "Carl", LastName: "Gray", PhoneNumber: "+1000000003", Address: "Denver",
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, repo.Delete(context.Background(), createdUser.ID, entities.StatusChangeCause{}))
+
+		restoredUser, err := repo.Restore(context.Background(), createdUser.ID, entities.StatusChangeCause{})
+		require.NoError(t, err)
+		assert.False(t, restoredUser.IsDeleted())
+		assert.Nil(t, restoredUser.DeletedAt)
+		assert.Equal(t, createdUser.FirstName, restoredUser.FirstName)
+	})
+}
+
+func testReplace(t *testing.T, repo service.UserRepository) {
+	ctx := context.Background()
+	params := entities.ReplaceUserParams{
+		FirstName: "Eve", LastName: "Stone", PhoneNumber: "+1000000005", Address: "Chicago",
+	}
+
+	t.Run("Replace non-existing user", func(t *testing.T) {
+		_, err := repo.Replace(ctx, missingUserID, params)
+		require.ErrorIs(t, err, entities.ErrUserNotFound)
+	})
+
+	t.Run("Replace existing user", func(t *testing.T) {
+		createdUser, err := repo.Create(ctx, entities.CreateUserParams{
+			FirstName: "Fay", LastName: "Moss", PhoneNumber: "+1000000006", Address: "Dallas",
+		})
+		require.NoError(t, err)
+
+		replacedUser, err := repo.Replace(ctx, createdUser.ID, params)
+		require.NoError(t, err)
+		assert.Equal(t, createdUser.ID, replacedUser.ID)
+		assert.Equal(t, params.FirstName, replacedUser.FirstName)
+		assert.Equal(t, params.LastName, replacedUser.LastName)
+		assert.Equal(t, params.PhoneNumber, replacedUser.PhoneNumber)
+		assert.Equal(t, params.Address, replacedUser.Address)
+		assert.True(t, createdUser.CreatedAt.Equal(replacedUser.CreatedAt))
+		assert.False(t, replacedUser.UpdatedAt.Before(createdUser.UpdatedAt))
+
+		foundUser, err := repo.Get(ctx, createdUser.ID)
+		require.NoError(t, err)
+		assert.Equal(t, replacedUser, foundUser)
+	})
+}
+
+func testUpsert(t *testing.T, repo service.UserRepository) {
+	ctx := context.Background()
+	externalID := entities.ExternalID{Source: "crm", ID: "C-7"}
+	params := entities.ReplaceUserParams{
+		FirstName: "Gus", LastName: "Hale", PhoneNumber: "+1000000007", Address: "Houston",
+		ExternalIDs: []entities.ExternalID{{Source: "hr", ID: "E-7"}},
+	}
+
+	createdUser, created, err := repo.Upsert(ctx, externalID, params)
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, params.FirstName, createdUser.FirstName)
+	assert.Equal(t, []entities.ExternalID{externalID, params.ExternalIDs[0]}, createdUser.ExternalIDs,
+		"the external ID of the upsert is kept")
+	assert.True(t, createdUser.CreatedAt.Equal(createdUser.UpdatedAt))
+
+	params.Address = "Austin"
+	params.ExternalIDs = nil
+
+	replacedUser, created, err := repo.Upsert(ctx, externalID, params)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, createdUser.ID, replacedUser.ID)
+	assert.Equal(t, params.Address, replacedUser.Address)
+	assert.Equal(t, []entities.ExternalID{externalID}, replacedUser.ExternalIDs)
+	assert.True(t, createdUser.CreatedAt.Equal(replacedUser.CreatedAt))
+
+	newUser, err := repo.Create(ctx, entities.CreateUserParams{
+		FirstName: "Jim", LastName: "Hale", PhoneNumber: "+1000000009", Address: "Houston",
+	})
+	require.NoError(t, err)
+	assert.Greater(t, newUser.ID, createdUser.ID)
+
+	require.NoError(t, repo.Delete(ctx, createdUser.ID, entities.StatusChangeCause{}))
+
+	_, _, err = repo.Upsert(ctx, externalID, params)
+	require.ErrorIs(t, err, entities.ErrUserDeleted)
+}
+
+func testExternalIDs(t *testing.T, repo service.UserRepository) {
+	ctx := context.Background()
+
+	lastUser, err := repo.Create(ctx, entities.CreateUserParams{
+		FirstName: "Kim", LastName: "Ode", PhoneNumber: "+1000000010", Address: "Boston",
+	})
+	require.NoError(t, err)
+
+	// IDs that no other user has, even if the repository isn't empty
+	hrID := entities.ExternalID{Source: "hr", ID: "E-" + strconv.FormatInt(lastUser.ID, 10)}
+	crmID := entities.ExternalID{Source: "crm", ID: hrID.ID}
+
+	createdUser, err := repo.Create(ctx, entities.CreateUserParams{
+		FirstName:   "Lea",
+		LastName:    "Ode",
+		PhoneNumber: "+1000000011",
+		Address:     "Boston",
+		ExternalIDs: []entities.ExternalID{hrID, crmID},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []entities.ExternalID{crmID, hrID}, createdUser.ExternalIDs, "IDs must be sorted by source")
+
+	foundUser, err := repo.Get(ctx, createdUser.ID)
+	require.NoError(t, err)
+	assert.Equal(t, createdUser.ExternalIDs, foundUser.ExternalIDs)
+
+	foundUser, err = repo.GetByExternalID(ctx, hrID.Source, hrID.ID)
+	require.NoError(t, err)
+	assert.Equal(t, createdUser.ID, foundUser.ID)
+	assert.Equal(t, createdUser.ExternalIDs, foundUser.ExternalIDs)
+
+	_, err = repo.GetByExternalID(ctx, "erp", hrID.ID)
+	require.ErrorIs(t, err, entities.ErrUserNotFound)
+
+	t.Run("ID of another user", func(t *testing.T) {
+		_, err := repo.Create(ctx, entities.CreateUserParams{
+			FirstName: "Max", LastName: "Ode", PhoneNumber: "+1000000012", Address: "Boston",
+			ExternalIDs: []entities.ExternalID{hrID},
+		})
+		require.ErrorIs(t, err, entities.ErrExternalIDTaken)
+
+		_, err = repo.Update(ctx, lastUser.ID, entities.UpdateUserParams{ExternalIDs: &[]entities.ExternalID{crmID}})
+		require.ErrorIs(t, err, entities.ErrExternalIDTaken)
+
+		unchangedUser, err := repo.Get(ctx, lastUser.ID)
+		require.NoError(t, err)
+		assert.Empty(t, unchangedUser.ExternalIDs)
+	})
+
+	t.Run("Update keeps or replaces IDs", func(t *testing.T) {
+		firstName := "Lee"
+
+		updatedUser, err := repo.Update(ctx, createdUser.ID, entities.UpdateUserParams{FirstName: &firstName})
+		require.NoError(t, err)
+		assert.Equal(t, createdUser.ExternalIDs, updatedUser.ExternalIDs)
+
+		updatedUser, err = repo.Update(ctx, createdUser.ID, entities.UpdateUserParams{
+			ExternalIDs: &[]entities.ExternalID{hrID},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []entities.ExternalID{hrID}, updatedUser.ExternalIDs)
+
+		_, err = repo.GetByExternalID(ctx, crmID.Source, crmID.ID)
+		require.ErrorIs(t, err, entities.ErrUserNotFound, "removed ID must not be found")
+	})
+
+	t.Run("Replace clears IDs", func(t *testing.T) {
+		replacedUser, err := repo.Replace(ctx, createdUser.ID, entities.ReplaceUserParams{
+			FirstName: "Lea", LastName: "Ode", PhoneNumber: "+1000000011", Address: "Boston",
+		})
+		require.NoError(t, err)
+		assert.Empty(t, replacedUser.ExternalIDs)
+
+		// the released ID can be given to another user
+		updatedUser, err := repo.Update(ctx, lastUser.ID, entities.UpdateUserParams{
+			ExternalIDs: &[]entities.ExternalID{hrID},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []entities.ExternalID{hrID}, updatedUser.ExternalIDs)
+
+		users, err := repo.List(ctx, entities.ListUsersParams{AfterID: lastUser.ID - 1, Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, users, 1)
+		assert.Equal(t, []entities.ExternalID{hrID}, users[0].ExternalIDs)
+	})
+}
+
+func testAttributes(t *testing.T, repo service.UserRepository) {
+	ctx := context.Background()
+
+	createdUser, err := repo.Create(ctx, entities.CreateUserParams{
+		FirstName:   "Ida",
+		LastName:    "Roe",
+		PhoneNumber: "+1000000020",
+		Address:     "Denver",
+		Attributes:  entities.Attributes{"team": "core", "level": 2, "vip": true, "tags": []any{"a", "b"}},
+	})
+	require.NoError(t, err)
+
+	expected := entities.Attributes{"team": "core", "level": 2.0, "vip": true, "tags": []any{"a", "b"}}
+	assert.Equal(t, expected, createdUser.Attributes)
+
+	foundUser, err := repo.Get(ctx, createdUser.ID)
+	require.NoError(t, err)
+	assert.Equal(t, expected, foundUser.Attributes)
+
+	otherUser, err := repo.Create(ctx, entities.CreateUserParams{
+		FirstName: "Jon", LastName: "Roe", PhoneNumber: "+1000000021", Address: "Denver",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, entities.Attributes{}, otherUser.Attributes)
+
+	t.Run("List filters by attributes", func(t *testing.T) {
+		params := entities.ListUsersParams{
+			AfterID:    createdUser.ID - 1,
+			Limit:      10,
+			Attributes: entities.Attributes{"team": "core", "level": 2.0},
+		}
+
+		users, err := repo.List(ctx, params)
+		require.NoError(t, err)
+		require.Len(t, users, 1)
+		assert.Equal(t, createdUser.ID, users[0].ID)
+
+		params.Attributes = entities.Attributes{"vip": false}
+
+		users, err = repo.List(ctx, params)
+		require.NoError(t, err)
+		assert.Empty(t, users)
+
+		params.Attributes = entities.Attributes{"level": "2"}
+
+		users, err = repo.List(ctx, params)
+		require.NoError(t, err)
+		assert.Empty(t, users, "values of different types must not match")
+	})
+
+	t.Run("Update merges attributes", func(t *testing.T) {
+		updatedUser, err := repo.Update(ctx, createdUser.ID, entities.UpdateUserParams{
+			Attributes: &entities.Attributes{"level": 3, "vip": nil, "manager": map[string]any{"id": 7}},
+		})
+		require.NoError(t, err)
+
+		expected := entities.Attributes{
+			"team": "core", "level": 3.0, "tags": []any{"a", "b"}, "manager": map[string]any{"id": 7.0},
+		}
+		assert.Equal(t, expected, updatedUser.Attributes)
+
+		foundUser, err := repo.Get(ctx, createdUser.ID)
+		require.NoError(t, err)
+		assert.Equal(t, expected, foundUser.Attributes)
+	})
+
+	t.Run("Replace sets all attributes", func(t *testing.T) {
+		replacedUser, err := repo.Replace(ctx, createdUser.ID, entities.ReplaceUserParams{
+			FirstName: "Ida", LastName: "Roe", PhoneNumber: "+1000000020", Address: "Denver",
+			Attributes: entities.Attributes{"team": "edge"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, entities.Attributes{"team": "edge"}, replacedUser.Attributes)
+	})
+}
+
+func testEmail(t *testing.T, repo service.UserRepository) {
+	ctx := context.Background()
+
+	createdUser, err := repo.Create(ctx, entities.CreateUserParams{
+		FirstName: "Kim", LastName: "Lowe", PhoneNumber: "+1000000022", Address: "Seattle",
+	})
+	require.NoError(t, err)
+	assert.Empty(t, createdUser.Email)
+	assert.Nil(t, createdUser.EmailVerifiedAt)
+
+	// the IDs make the emails unique when the repository already contains users
+	email := "kim." + strconv.FormatInt(createdUser.ID, 10) + "@example.com"
+	otherEmail := "lowe." + strconv.FormatInt(createdUser.ID, 10) + "@example.com"
+
+	user, err := repo.Update(ctx, createdUser.ID, entities.UpdateUserParams{Email: &email})
+	require.NoError(t, err)
+	assert.Equal(t, email, user.Email)
+	assert.False(t, user.IsEmailVerified())
+
+	t.Run("Email of another user", func(t *testing.T) {
+		_, err := repo.Create(ctx, entities.CreateUserParams{
+			FirstName: "Lea", LastName: "Lowe", PhoneNumber: "+1000000023", Address: "Seattle", Email: email,
+		})
+		require.ErrorIs(t, err, entities.ErrEmailTaken)
+	})
+
+	t.Run("Verify changed email", func(t *testing.T) {
+		_, err := repo.VerifyEmail(ctx, createdUser.ID, otherEmail)
+		require.ErrorIs(t, err, entities.ErrEmailChanged)
+	})
+
+	t.Run("Verify non-existing user", func(t *testing.T) {
+		_, err := repo.VerifyEmail(ctx, missingUserID, email)
+		require.ErrorIs(t, err, entities.ErrEmailChanged)
+	})
+
+	verifiedUser, err := repo.VerifyEmail(ctx, createdUser.ID, email)
+	require.NoError(t, err)
+	require.True(t, verifiedUser.IsEmailVerified())
+
+	t.Run("Update keeps verification of same email", func(t *testing.T) {
+		user, err := repo.Update(ctx, createdUser.ID, entities.UpdateUserParams{Email: &email})
+		require.NoError(t, err)
+		require.True(t, user.IsEmailVerified())
+		assert.True(t, verifiedUser.EmailVerifiedAt.Equal(*user.EmailVerifiedAt))
+
+		foundUser, err := repo.Get(ctx, createdUser.ID)
+		require.NoError(t, err)
+		assert.Equal(t, user, foundUser)
+	})
+
+	t.Run("Replace resets verification of changed email", func(t *testing.T) {
+		user, err := repo.Replace(ctx, createdUser.ID, entities.ReplaceUserParams{
+			FirstName: "Kim", LastName: "Lowe", PhoneNumber: "+1000000022", Address: "Seattle", Email: otherEmail,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, otherEmail, user.Email)
+		assert.False(t, user.IsEmailVerified())
+	})
+
+	t.Run("Email of deleted user is free", func(t *testing.T) {
+		require.NoError(t, repo.Delete(ctx, createdUser.ID, entities.StatusChangeCause{}))
+
+		user, err := repo.Create(ctx, entities.CreateUserParams{
+			FirstName: "Lea", LastName: "Lowe", PhoneNumber: "+1000000023", Address: "Seattle", Email: otherEmail,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, otherEmail, user.Email)
+
+		_, err = repo.Restore(ctx, createdUser.ID, entities.StatusChangeCause{})
+		require.ErrorIs(t, err, entities.ErrEmailTaken)
+	})
+}
+
+func testPhone(t *testing.T, repo service.UserRepository) {
+	ctx := context.Background()
+	params := entities.ReplaceUserParams{
+		FirstName: "Max", LastName: "Reed", PhoneNumber: "+1000000024", Address: "Miami",
+	}
+
+	createdUser, err := repo.Create(ctx, params)
+	require.NoError(t, err)
+	assert.False(t, createdUser.IsPhoneVerified())
+
+	t.Run("Verify changed phone number", func(t *testing.T) {
+		_, err := repo.VerifyPhone(ctx, createdUser.ID, "+1000000025")
+		require.ErrorIs(t, err, entities.ErrPhoneChanged)
+	})
+
+	verifiedUser, err := repo.VerifyPhone(ctx, createdUser.ID, params.PhoneNumber)
+	require.NoError(t, err)
+	require.True(t, verifiedUser.IsPhoneVerified())
+
+	foundUser, err := repo.Get(ctx, createdUser.ID)
+	require.NoError(t, err)
+	assert.Equal(t, verifiedUser, foundUser)
+
+	t.Run("Replace keeps verification of same phone number", func(t *testing.T) {
+		params.Address = "Orlando"
+
+		user, err := repo.Replace(ctx, createdUser.ID, params)
+		require.NoError(t, err)
+		require.True(t, user.IsPhoneVerified())
+		assert.True(t, verifiedUser.PhoneVerifiedAt.Equal(*user.PhoneVerifiedAt))
+	})
+
+	t.Run("Update resets verification of changed phone number", func(t *testing.T) {
+		phoneNumber := "+1000000025"
+
+		user, err := repo.Update(ctx, createdUser.ID, entities.UpdateUserParams{PhoneNumber: &phoneNumber})
+		require.NoError(t, err)
+		assert.False(t, user.IsPhoneVerified())
+	})
+}
+
+func testStatus(t *testing.T, repo service.UserRepository) {
+	ctx := context.Background()
+
+	activeUser, err := repo.Create(ctx, entities.CreateUserParams{
+		FirstName: "Ona", LastName: "Reed", PhoneNumber: "+1000000027", Address: "Miami",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, entities.UserStatusActive, activeUser.Status)
+
+	createdUser, err := repo.Create(ctx, entities.CreateUserParams{
+		FirstName: "Pia", LastName: "Reed", PhoneNumber: "+1000000028", Address: "Miami",
+		Status: entities.UserStatusPending,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, entities.UserStatusPending, createdUser.Status)
+
+	t.Run("Change from another status", func(t *testing.T) {
+		_, err := repo.ChangeStatus(ctx, createdUser.ID, entities.UserStatusActive,
+			entities.ChangeUserStatusParams{Status: entities.UserStatusSuspended})
+		require.ErrorIs(t, err, entities.ErrStatusChanged)
+	})
+
+	activatedUser, err := repo.ChangeStatus(ctx, createdUser.ID, entities.UserStatusPending,
+		entities.ChangeUserStatusParams{Status: entities.UserStatusActive, Actor: "operator:alice"})
+	require.NoError(t, err)
+	assert.Equal(t, entities.UserStatusActive, activatedUser.Status)
+
+	suspendedUser, err := repo.ChangeStatus(ctx, createdUser.ID, entities.UserStatusActive,
+		entities.ChangeUserStatusParams{Status: entities.UserStatusSuspended, Reason: "spam", Actor: "user:1"})
+	require.NoError(t, err)
+	assert.Equal(t, entities.UserStatusSuspended, suspendedUser.Status)
+
+	foundUser, err := repo.Get(ctx, createdUser.ID)
+	require.NoError(t, err)
+	assert.Equal(t, suspendedUser, foundUser)
+
+	changes, err := repo.ListStatusChanges(ctx, createdUser.ID)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, entities.UserStatusPending, changes[0].FromStatus)
+	assert.Equal(t, entities.UserStatusActive, changes[0].ToStatus)
+	assert.Equal(t, "operator:alice", changes[0].Actor)
+	assert.Equal(t, entities.UserStatusActive, changes[1].FromStatus)
+	assert.Equal(t, entities.UserStatusSuspended, changes[1].ToStatus)
+	assert.Equal(t, "spam", changes[1].Reason)
+	assert.Equal(t, "user:1", changes[1].Actor)
+
+	t.Run("Delete and restore", func(t *testing.T) {
+		require.NoError(t, repo.Delete(ctx, activeUser.ID, entities.StatusChangeCause{}))
+
+		_, err := repo.ChangeStatus(ctx, activeUser.ID, entities.UserStatusActive,
+			entities.ChangeUserStatusParams{Status: entities.UserStatusSuspended})
+		require.ErrorIs(t, err, entities.ErrStatusChanged)
+
+		deletedUser, err := repo.Get(ctx, activeUser.ID)
+		require.NoError(t, err)
+		assert.Equal(t, entities.UserStatusDeleted, deletedUser.Status)
+
+		restoredUser, err := repo.Restore(ctx, activeUser.ID, entities.StatusChangeCause{})
+		require.NoError(t, err)
+		assert.Equal(t, entities.UserStatusActive, restoredUser.Status)
+	})
+
+	t.Run("Restore keeps status from before delete", func(t *testing.T) {
+		require.NoError(t, repo.Delete(ctx, createdUser.ID,
+			entities.StatusChangeCause{Reason: "duplicate", Actor: "operator:alice"}))
+
+		// deleting a deleted user changes nothing
+		require.NoError(t, repo.Delete(ctx, createdUser.ID, entities.StatusChangeCause{Actor: "operator:bob"}))
+
+		restoredUser, err := repo.Restore(ctx, createdUser.ID, entities.StatusChangeCause{Actor: "operator:carol"})
+		require.NoError(t, err)
+		assert.Equal(t, entities.UserStatusSuspended, restoredUser.Status)
+
+		// restoring a user that isn't deleted changes nothing
+		_, err = repo.Restore(ctx, createdUser.ID, entities.StatusChangeCause{Actor: "operator:dave"})
+		require.NoError(t, err)
+
+		changes, err := repo.ListStatusChanges(ctx, createdUser.ID)
+		require.NoError(t, err)
+		require.Len(t, changes, 4)
+		assert.Equal(t, entities.UserStatusSuspended, changes[2].FromStatus)
+		assert.Equal(t, entities.UserStatusDeleted, changes[2].ToStatus)
+		assert.Equal(t, "duplicate", changes[2].Reason)
+		assert.Equal(t, "operator:alice", changes[2].Actor)
+		assert.Equal(t, entities.UserStatusDeleted, changes[3].FromStatus)
+		assert.Equal(t, entities.UserStatusSuspended, changes[3].ToStatus)
+		assert.Equal(t, "operator:carol", changes[3].Actor)
+	})
+}
+
+// TestPhoneVerificationRepository runs the conformance tests of the codes sent to verify phone numbers,
+// users is where the users the codes are sent to are kept.
+func TestPhoneVerificationRepository(
+	t *testing.T,
+	repo service.PhoneVerificationRepository,
+	users service.UserRepository,
+) {
+	ctx := context.Background()
+
+	user, err := users.Create(ctx, entities.CreateUserParams{
+		FirstName: "Ned", LastName: "Reed", PhoneNumber: "+1000000026", Address: "Miami",
+	})
+	require.NoError(t, err)
+
+	_, err = repo.GetPhoneVerification(ctx, user.ID)
+	require.ErrorIs(t, err, entities.ErrPhoneVerificationNotFound)
+
+	_, err = repo.CountPhoneVerificationAttempt(ctx, user.ID)
+	require.ErrorIs(t, err, entities.ErrPhoneVerificationNotFound)
+
+	params := entities.PhoneVerification{
+		UserID:      user.ID,
+		PhoneNumber: user.PhoneNumber,
+		CodeHash:    "first",
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+
+	savedVerification, err := repo.SavePhoneVerification(ctx, params)
+	require.NoError(t, err)
+	assert.Equal(t, params.PhoneNumber, savedVerification.PhoneNumber)
+	assert.Equal(t, params.CodeHash, savedVerification.CodeHash)
+	assert.Zero(t, savedVerification.Attempts)
+	assert.WithinDuration(t, params.ExpiresAt, savedVerification.ExpiresAt, time.Millisecond)
+	assert.False(t, savedVerification.CreatedAt.IsZero())
+
+	for attempts := 1; attempts <= 2; attempts++ {
+		verification, err := repo.CountPhoneVerificationAttempt(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, attempts, verification.Attempts)
+	}
+
+	t.Run("New code resets attempts", func(t *testing.T) {
+		params.CodeHash = "second"
+
+		_, err := repo.SavePhoneVerification(ctx, params)
+		require.NoError(t, err)
+
+		verification, err := repo.GetPhoneVerification(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, params.CodeHash, verification.CodeHash)
+		assert.Zero(t, verification.Attempts)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		require.NoError(t, repo.DeletePhoneVerification(ctx, user.ID))
+
+		_, err := repo.GetPhoneVerification(ctx, user.ID)
+		require.ErrorIs(t, err, entities.ErrPhoneVerificationNotFound)
+	})
+}
+
+func testConcurrentCreates(t *testing.T, repo service.UserRepository) {
+	const creates = 20
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		ids = make(map[int64]struct{}, creates)
+	)
+
+	for i := 0; i < creates; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			user, err := repo.Create(context.Background(), entities.CreateUserParams{
+				FirstName: "Dan", LastName: "Fox", PhoneNumber: "+1000000004", Address: "Austin",
+			})
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			mu.Lock()
+			ids[user.ID] = struct{}{}
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Len(t, ids, creates, "every user must get a unique ID")
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
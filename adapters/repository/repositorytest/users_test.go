@@ -0,0 +1,27 @@
+package repositorytest_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/torwig/user-service/adapters/repository"
+	"github.com/torwig/user-service/adapters/repository/repositorytest"
+)
+
+func TestInMemoryRepository(t *testing.T) {
+	repo := repository.NewInMemoryRepository()
+
+	repositorytest.TestUserRepository(t, repo)
+	repositorytest.TestPhoneVerificationRepository(t, repo, repo)
+}
+
+func TestSQLiteRepository(t *testing.T) {
+	repo, err := repository.NewSQLiteRepository("sqlite://" + filepath.Join(t.TempDir(), "users.db"))
+	require.NoError(t, err)
+
+	t.Cleanup(repo.Close)
+
+	repositorytest.TestUserRepository(t, repo)
+	repositorytest.TestPhoneVerificationRepository(t, repo, repo)
+}
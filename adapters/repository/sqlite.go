@@ -0,0 +1,632 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/georgysavva/scany/v2/sqlscan"
+	"github.com/golang-migrate/migrate/v4"
+	migrateSQLite "github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/db"
+	"github.com/torwig/user-service/entities"
+	_ "modernc.org/sqlite" // registers the pure-Go "sqlite" database/sql driver
+)
+
+const (
+	sqliteScheme = "sqlite:"
+
+	// sqliteBusyTimeout is how long a statement waits for the lock held by another process.
+	sqliteBusyTimeout = "5000"
+)
+
+var ErrInvalidSQLiteDSN = errors.New("SQLite connection string must be sqlite://<path to the database file>")
+
+// IsSQLiteDSN reports whether the connection string points to a SQLite database file
+// rather than a Postgres server, e.g. sqlite:///var/lib/users/users.db.
+func IsSQLiteDSN(dsn string) bool {
+	return strings.HasPrefix(dsn, sqliteScheme)
+}
+
+// SQLiteRepository keeps users and API keys in a SQLite database file,
+// for single-node deployments that can't run Postgres.
+type SQLiteRepository struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// NewSQLiteRepository opens the database file and brings its schema up to date.
+func NewSQLiteRepository(dsn string) (*SQLiteRepository, error) {
+	source, err := sqliteDataSource(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migrateSQLiteSchema(source); err != nil {
+		return nil, err
+	}
+
+	conn, err := sql.Open("sqlite", source)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open the database")
+	}
+
+	// SQLite allows only one writer at a time, a single connection serializes them without "database is locked" errors
+	conn.SetMaxOpenConns(1)
+
+	if err := conn.Ping(); err != nil {
+		_ = conn.Close()
+
+		return nil, errors.Wrap(err, "failed to ping the database")
+	}
+
+	return &SQLiteRepository{db: conn, now: time.Now}, nil
+}
+
+// sqliteDataSource converts the connection string to the data source name of the driver.
+// Query parameters are passed to the driver as they are, e.g. to set more pragmas.
+func sqliteDataSource(dsn string) (string, error) {
+	path := strings.TrimPrefix(strings.TrimPrefix(dsn, sqliteScheme), "//")
+
+	path, rawQuery, _ := strings.Cut(path, "?")
+	if path == "" {
+		return "", ErrInvalidSQLiteDSN
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", errors.Wrap(ErrInvalidSQLiteDSN, err.Error())
+	}
+
+	query.Add("_pragma", "busy_timeout("+sqliteBusyTimeout+")")
+	query.Add("_pragma", "journal_mode(WAL)")
+	query.Add("_pragma", "foreign_keys(1)")
+
+	return "file:" + path + "?" + query.Encode(), nil
+}
+
+func migrateSQLiteSchema(source string) error {
+	migrations, err := iofs.New(db.SQLiteMigrations, "sqlite")
+	if err != nil {
+		return errors.Wrap(err, "failed to read embedded migrations")
+	}
+
+	conn, err := sql.Open("sqlite", source)
+	if err != nil {
+		return errors.Wrap(err, "failed to open the database")
+	}
+
+	driver, err := migrateSQLite.WithInstance(conn, &migrateSQLite.Config{})
+	if err != nil {
+		_ = conn.Close()
+
+		return errors.Wrap(err, "failed to create database driver for the migration process")
+	}
+
+	m, err := migrate.NewWithInstance("iofs", migrations, "sqlite", driver)
+	if err != nil {
+		_ = conn.Close()
+
+		return errors.Wrap(err, "failed to init migrations")
+	}
+
+	// closing the migrations closes the connection too
+	defer func() {
+		_, _ = m.Close()
+	}()
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return errors.Wrap(err, "failed to apply migrations")
+	}
+
+	return nil
+}
+
+func (r *SQLiteRepository) Close() {
+	_ = r.db.Close()
+}
+
+// Ping checks that the database file can still be read.
+func (r *SQLiteRepository) Ping(ctx context.Context) error {
+	if err := r.db.PingContext(ctx); err != nil {
+		return errors.Wrap(err, "failed to ping the database")
+	}
+
+	return nil
+}
+
+// timestamp returns the current time the way Postgres stores it in a column of the "timestamp" type.
+func (r *SQLiteRepository) timestamp() time.Time {
+	return r.now().UTC().Truncate(time.Microsecond)
+}
+
+// sqliteUser is a row of the users table, where attributes are kept as a JSON object.
+type sqliteUser struct {
+	entities.User
+	Attributes string
+}
+
+func (u sqliteUser) toEntity() (entities.User, error) {
+	user := u.User
+
+	if err := json.Unmarshal([]byte(u.Attributes), &user.Attributes); err != nil {
+		return user, errors.Wrap(err, "failed to decode attributes")
+	}
+
+	return user, nil
+}
+
+// getSQLiteUser scans the only row of the query, the error of sqlscan is returned as it is.
+func getSQLiteUser(ctx context.Context, q sqlscan.Querier, query string, args ...any) (entities.User, error) {
+	var row sqliteUser
+
+	if err := sqlscan.Get(ctx, q, &row, query, args...); err != nil {
+		return row.User, err
+	}
+
+	return row.toEntity()
+}
+
+func selectSQLiteUsers(ctx context.Context, q sqlscan.Querier, query string, args ...any) ([]entities.User, error) {
+	var rows []sqliteUser
+
+	if err := sqlscan.Select(ctx, q, &rows, query, args...); err != nil {
+		return nil, err
+	}
+
+	users := make([]entities.User, 0, len(rows))
+
+	for _, row := range rows {
+		user, err := row.toEntity()
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+// sqliteAttributeFilter selects the users having all the attributes of the filter, which are compared
+// along with their JSON types so that e.g. "1" doesn't match 1.
+func sqliteAttributeFilter(filter entities.Attributes) sq.And {
+	keys := make([]string, 0, len(filter))
+	for key := range filter {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	conditions := make(sq.And, 0, len(keys))
+
+	for _, key := range keys {
+		path := `$."` + key + `"`
+
+		switch v := filter[key].(type) {
+		case bool:
+			conditions = append(conditions, sq.Expr("json_type(attributes, ?) = ?", path, strconv.FormatBool(v)))
+		case float64:
+			conditions = append(conditions, sq.Expr(
+				"json_type(attributes, ?) IN ('integer', 'real') AND json_extract(attributes, ?) = ?", path, path, v))
+		default:
+			conditions = append(conditions, sq.Expr(
+				"json_type(attributes, ?) = 'text' AND json_extract(attributes, ?) = ?", path, path, v))
+		}
+	}
+
+	return conditions
+}
+
+func (r *SQLiteRepository) Create(ctx context.Context, params entities.CreateUserParams) (entities.User, error) {
+	var user entities.User
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		user, err = r.insert(ctx, tx, params)
+
+		return err
+	})
+
+	return user, err
+}
+
+// insert creates the user along with its external IDs.
+func (r *SQLiteRepository) insert(
+	ctx context.Context,
+	tx *sql.Tx,
+	params entities.CreateUserParams,
+) (entities.User, error) {
+	var user entities.User
+
+	attributes, err := encodeAttributes(params.Attributes)
+	if err != nil {
+		return user, err
+	}
+
+	now := r.timestamp()
+
+	stmt := sq.
+		Insert(userTableName).
+		Columns("first_name", "last_name", "phone_number", "address", "email", "status", "attributes", "created_at",
+			"updated_at").
+		Values(params.FirstName, params.LastName, params.PhoneNumber, params.Address, params.Email,
+			initialStatus(params.Status), attributes, now, now).
+		Suffix(returningUser)
+
+	query, args, err := stmt.ToSql()
+	if err != nil {
+		return user, errors.Wrap(err, "failed to build a query")
+	}
+
+	user, err = getSQLiteUser(ctx, tx, query, args...)
+	if err != nil {
+		if isSQLiteEmailTaken(err) {
+			return user, entities.ErrEmailTaken
+		}
+
+		return user, errors.Wrap(err, "failed to execute a query")
+	}
+
+	return user, setSQLiteExternalIDs(ctx, tx, &user, params.ExternalIDs)
+}
+
+func (r *SQLiteRepository) Get(ctx context.Context, id int64) (entities.User, error) {
+	var user entities.User
+
+	stmt := sq.
+		Select(userColumns...).
+		From(userTableName).
+		Where(sq.Eq{"id": id})
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return user, errors.Wrap(err, "failed to build a query")
+	}
+
+	user, err = getSQLiteUser(ctx, r.db, sql, args...)
+	if err != nil {
+		if sqlscan.NotFound(err) {
+			return user, entities.ErrUserNotFound
+		}
+
+		return user, errors.Wrap(err, "failed to execute a query")
+	}
+
+	return user, loadSQLiteExternalIDs(ctx, r.db, &user)
+}
+
+func (r *SQLiteRepository) Update(
+	ctx context.Context,
+	id int64,
+	params entities.UpdateUserParams,
+) (entities.User, error) {
+	nothingToUpdate := params == entities.UpdateUserParams{}
+	if nothingToUpdate {
+		return r.Get(ctx, id)
+	}
+
+	var user entities.User
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		stmt := sq.
+			Update(userTableName)
+		if params.FirstName != nil {
+			stmt = stmt.Set("first_name", *params.FirstName)
+		}
+		if params.LastName != nil {
+			stmt = stmt.Set("last_name", *params.LastName)
+		}
+		if params.PhoneNumber != nil {
+			stmt = stmt.
+				Set("phone_number", *params.PhoneNumber).
+				Set("phone_verified_at", keepPhoneVerification(*params.PhoneNumber))
+		}
+		if params.Address != nil {
+			stmt = stmt.Set("address", *params.Address)
+		}
+		if params.Email != nil {
+			stmt = stmt.
+				Set("email", *params.Email).
+				Set("email_verified_at", keepEmailVerification(*params.Email))
+		}
+		if params.Attributes != nil {
+			attributes, err := patchSQLiteAttributes(ctx, tx, id, *params.Attributes)
+			if err != nil {
+				return err
+			}
+
+			stmt = stmt.Set("attributes", attributes)
+		}
+
+		stmt = stmt.
+			Set("updated_at", r.timestamp()).
+			Where(sq.Eq{"id": id}).
+			Suffix(returningUser)
+
+		query, args, err := stmt.ToSql()
+		if err != nil {
+			return errors.Wrap(err, "failed to build a query")
+		}
+
+		if user, err = getSQLiteUser(ctx, tx, query, args...); err != nil {
+			if sqlscan.NotFound(err) {
+				return entities.ErrUserNotFound
+			}
+
+			if isSQLiteEmailTaken(err) {
+				return entities.ErrEmailTaken
+			}
+
+			return errors.Wrap(err, "failed to execute a query")
+		}
+
+		if params.ExternalIDs != nil {
+			return setSQLiteExternalIDs(ctx, tx, &user, *params.ExternalIDs)
+		}
+
+		return loadSQLiteExternalIDs(ctx, tx, &user)
+	})
+
+	return user, err
+}
+
+// patchSQLiteAttributes returns the attributes of the user with the patch applied.
+func patchSQLiteAttributes(ctx context.Context, tx *sql.Tx, id int64, patch entities.Attributes) (string, error) {
+	var encoded string
+
+	err := tx.QueryRowContext(ctx, "SELECT attributes FROM "+userTableName+" WHERE id = ?", id).Scan(&encoded)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", entities.ErrUserNotFound
+		}
+
+		return "", errors.Wrap(err, "failed to execute a query")
+	}
+
+	var attributes entities.Attributes
+	if err := json.Unmarshal([]byte(encoded), &attributes); err != nil {
+		return "", errors.Wrap(err, "failed to decode attributes")
+	}
+
+	return encodeAttributes(entities.MergeAttributes(attributes, patch))
+}
+
+// Replace sets all the mutable fields of the user.
+func (r *SQLiteRepository) Replace(
+	ctx context.Context,
+	id int64,
+	params entities.ReplaceUserParams,
+) (entities.User, error) {
+	var user entities.User
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		user, err = r.replace(ctx, tx, id, params)
+
+		return err
+	})
+
+	return user, err
+}
+
+// replace sets all the mutable fields of the user along with its external IDs.
+func (r *SQLiteRepository) replace(
+	ctx context.Context,
+	tx *sql.Tx,
+	id int64,
+	params entities.ReplaceUserParams,
+) (entities.User, error) {
+	var user entities.User
+
+	values, err := replaceUserValues(params)
+	if err != nil {
+		return user, err
+	}
+
+	stmt := sq.
+		Update(userTableName).
+		SetMap(values).
+		Set("phone_verified_at", keepPhoneVerification(params.PhoneNumber)).
+		Set("email_verified_at", keepEmailVerification(params.Email)).
+		Set("updated_at", r.timestamp()).
+		Where(sq.Eq{"id": id}).
+		Suffix(returningUser)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return user, errors.Wrap(err, "failed to build a query")
+	}
+
+	user, err = getSQLiteUser(ctx, tx, sql, args...)
+	if err != nil {
+		if sqlscan.NotFound(err) {
+			return user, entities.ErrUserNotFound
+		}
+
+		if isSQLiteEmailTaken(err) {
+			return user, entities.ErrEmailTaken
+		}
+
+		return user, errors.Wrap(err, "failed to execute a query")
+	}
+
+	return user, setSQLiteExternalIDs(ctx, tx, &user, params.ExternalIDs)
+}
+
+// Upsert replaces the user the external ID belongs to or creates a new user with it, the flag reports whether
+// the user was created. A deleted user isn't replaced, ErrUserDeleted is returned.
+func (r *SQLiteRepository) Upsert(
+	ctx context.Context,
+	externalID entities.ExternalID,
+	params entities.ReplaceUserParams,
+) (entities.User, bool, error) {
+	var (
+		user    entities.User
+		created bool
+	)
+
+	params.ExternalIDs = entities.WithExternalID(params.ExternalIDs, externalID)
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		var (
+			id      int64
+			deleted bool
+		)
+
+		err := tx.QueryRowContext(ctx, "SELECT u.id, u.deleted FROM "+userTableName+" u "+
+			"JOIN "+userExternalIDTableName+" e ON e.user_id = u.id WHERE e.source = ? AND e.external_id = ?",
+			externalID.Source, externalID.ID).Scan(&id, &deleted)
+
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			created = true
+			user, err = r.insert(ctx, tx, params)
+		case err != nil:
+			return errors.Wrap(err, "failed to execute a query")
+		case deleted:
+			return entities.ErrUserDeleted
+		default:
+			user, err = r.replace(ctx, tx, id, params)
+		}
+
+		return err
+	})
+	if err != nil {
+		return user, false, err
+	}
+
+	return user, created, nil
+}
+
+func (r *SQLiteRepository) List(ctx context.Context, params entities.ListUsersParams) ([]entities.User, error) {
+	stmt := sq.
+		Select(userColumns...).
+		From(userTableName).
+		Where(sq.Gt{"id": params.AfterID}).
+		OrderBy("id").
+		Limit(params.Limit)
+	if !params.IncludeDeleted {
+		stmt = stmt.Where(sq.Eq{"deleted": false})
+	}
+	if len(params.Attributes) > 0 {
+		stmt = stmt.Where(sqliteAttributeFilter(params.Attributes))
+	}
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build a query")
+	}
+
+	users, err := selectSQLiteUsers(ctx, r.db, sql, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to execute a query")
+	}
+
+	if err := loadSQLiteExternalIDs(ctx, r.db, userPointers(users)...); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (r *SQLiteRepository) Delete(ctx context.Context, id int64, cause entities.StatusChangeCause) error {
+	now := r.timestamp()
+
+	stmt := sq.
+		Update(userTableName).
+		Set("deleted", true).
+		Set("status_before_delete", sq.Expr("status")).
+		Set("status", entities.UserStatusDeleted).
+		Set("deleted_at", now).
+		Set("updated_at", now).
+		// deleting already deleted user keeps the time of the deletion
+		Where(sq.Eq{"id": id, "deleted": false}).
+		Suffix("RETURNING status_before_delete")
+
+	query, args, err := stmt.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build a query")
+	}
+
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		var from entities.UserStatus
+
+		if err := tx.QueryRowContext(ctx, query, args...).Scan(&from); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+
+			return errors.Wrap(err, "failed to execute a query")
+		}
+
+		return insertSQLiteStatusChange(ctx, tx, id, from, entities.UserStatusDeleted, cause, now)
+	})
+}
+
+func (r *SQLiteRepository) Restore(
+	ctx context.Context,
+	id int64,
+	cause entities.StatusChangeCause,
+) (entities.User, error) {
+	var user entities.User
+
+	now := r.timestamp()
+
+	stmt := sq.
+		Update(userTableName).
+		Set("deleted", false).
+		Set("status", restoredStatus()).
+		Set("status_before_delete", nil).
+		Set("deleted_at", nil).
+		Set("updated_at", now).
+		Where(sq.Eq{"id": id}).
+		Suffix(returningUser)
+
+	query, args, err := stmt.ToSql()
+	if err != nil {
+		return user, errors.Wrap(err, "failed to build a query")
+	}
+
+	err = r.inTx(ctx, func(tx *sql.Tx) error {
+		var deleted bool
+
+		err := tx.QueryRowContext(ctx, "SELECT deleted FROM "+userTableName+" WHERE id = ?", id).Scan(&deleted)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return entities.ErrUserNotFound
+			}
+
+			return errors.Wrap(err, "failed to execute a query")
+		}
+
+		user, err = getSQLiteUser(ctx, tx, query, args...)
+		if err != nil {
+			// another user has taken the email since the deletion
+			if isSQLiteEmailTaken(err) {
+				return entities.ErrEmailTaken
+			}
+
+			return errors.Wrap(err, "failed to execute a query")
+		}
+
+		if deleted {
+			err = insertSQLiteStatusChange(ctx, tx, id, entities.UserStatusDeleted, user.Status, cause, now)
+			if err != nil {
+				return err
+			}
+		}
+
+		return loadSQLiteExternalIDs(ctx, tx, &user)
+	})
+
+	return user, err
+}
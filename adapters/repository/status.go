@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+)
+
+const userStatusChangeTableName = "user_status_changes"
+
+var userStatusChangeColumns = []string{"id", "user_id", "from_status", "to_status", "reason", "actor", "created_at"}
+
+// initialStatus is the status a user is created with, active unless another one is given.
+func initialStatus(status entities.UserStatus) entities.UserStatus {
+	if status == "" {
+		return entities.UserStatusActive
+	}
+
+	return status
+}
+
+// restoredStatus gives a deleted user back the status it had before the deletion, so that e.g. a suspended user
+// doesn't come back active. Users deleted before the status was introduced are active.
+func restoredStatus() sq.Sqlizer {
+	return sq.Expr("CASE WHEN deleted THEN COALESCE(status_before_delete, ?) ELSE status END", entities.UserStatusActive)
+}
+
+// ChangeStatus changes the status of the user from the given one and records the change. If the user doesn't have
+// that status anymore, e.g. because it was changed or the user was deleted concurrently, ErrStatusChanged is returned.
+func (r *PostgresRepository) ChangeStatus(
+	ctx context.Context,
+	id int64,
+	from entities.UserStatus,
+	params entities.ChangeUserStatusParams,
+) (entities.User, error) {
+	defer r.observeQuery("change_user_status", time.Now())
+
+	var user entities.User
+
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		stmt := sq.
+			Update(userTableName).
+			Set("status", params.Status).
+			Set("updated_at", sq.Expr("NOW()")).
+			Where(sq.Eq{"id": id, "status": from, "deleted": false}).
+			Suffix(returningUser).
+			PlaceholderFormat(sq.Dollar)
+
+		sql, args, err := stmt.ToSql()
+		if err != nil {
+			return errors.Wrap(err, "failed to build a query")
+		}
+
+		if err := pgxscan.Get(ctx, tx, &user, sql, args...); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return entities.ErrStatusChanged
+			}
+
+			return errors.Wrap(err, "failed to execute a query")
+		}
+
+		cause := entities.StatusChangeCause{Reason: params.Reason, Actor: params.Actor}
+		if err := insertStatusChange(ctx, tx, id, from, params.Status, cause); err != nil {
+			return err
+		}
+
+		return loadExternalIDs(ctx, tx, &user)
+	})
+	if err != nil {
+		return user, err
+	}
+
+	r.recentWrites.record(ctx)
+
+	return user, nil
+}
+
+// insertStatusChange records the change of the status of the user made in the transaction.
+func insertStatusChange(
+	ctx context.Context,
+	tx pgx.Tx,
+	userID int64,
+	from, to entities.UserStatus,
+	cause entities.StatusChangeCause,
+) error {
+	sql, args, err := sq.
+		Insert(userStatusChangeTableName).
+		Columns("user_id", "from_status", "to_status", "reason", "actor").
+		Values(userID, from, to, cause.Reason, cause.Actor).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build a query")
+	}
+
+	if _, err := tx.Exec(ctx, sql, args...); err != nil {
+		return errors.Wrap(err, "failed to execute a query")
+	}
+
+	return nil
+}
+
+// ListStatusChanges returns the recorded changes of the status of the user, the oldest first.
+func (r *PostgresRepository) ListStatusChanges(ctx context.Context, userID int64) ([]entities.UserStatusChange, error) {
+	defer r.observeQuery("list_user_status_changes", time.Now())
+
+	stmt := sq.
+		Select(userStatusChangeColumns...).
+		From(userStatusChangeTableName).
+		Where(sq.Eq{"user_id": userID}).
+		OrderBy("id").
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build a query")
+	}
+
+	var changes []entities.UserStatusChange
+
+	err = pgxscan.Select(ctx, r.db, &changes, sql, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to execute a query")
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/torwig/user-service/entities"
+)
+
+// ChangeStatus changes the status of the user from the given one and records the change. If the user doesn't have
+// that status anymore, e.g. because it was changed or the user was deleted concurrently, ErrStatusChanged is returned.
+func (r *InMemoryRepository) ChangeStatus(
+	_ context.Context,
+	id int64,
+	from entities.UserStatus,
+	params entities.ChangeUserStatusParams,
+) (entities.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok || user.Deleted || user.Status != from {
+		return entities.User{}, entities.ErrStatusChanged
+	}
+
+	now := r.timestamp()
+	user.Status = params.Status
+	user.UpdatedAt = now
+
+	r.users[id] = user
+
+	cause := entities.StatusChangeCause{Reason: params.Reason, Actor: params.Actor}
+	r.recordStatusChange(id, from, params.Status, cause, now)
+
+	return copyUser(user), nil
+}
+
+// recordStatusChange has to be called with the lock held.
+func (r *InMemoryRepository) recordStatusChange(
+	userID int64,
+	from, to entities.UserStatus,
+	cause entities.StatusChangeCause,
+	now time.Time,
+) {
+	r.lastStatusChangeID++
+	r.statusChanges = append(r.statusChanges, entities.UserStatusChange{
+		ID:         r.lastStatusChangeID,
+		UserID:     userID,
+		FromStatus: from,
+		ToStatus:   to,
+		Reason:     cause.Reason,
+		Actor:      cause.Actor,
+		CreatedAt:  now,
+	})
+}
+
+// ListStatusChanges returns the recorded changes of the status of the user, the oldest first.
+func (r *InMemoryRepository) ListStatusChanges(_ context.Context, userID int64) ([]entities.UserStatusChange, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var changes []entities.UserStatusChange
+
+	for _, change := range r.statusChanges {
+		if change.UserID == userID {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/georgysavva/scany/v2/sqlscan"
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+)
+
+// ChangeStatus changes the status of the user from the given one and records the change. If the user doesn't have
+// that status anymore, e.g. because it was changed or the user was deleted concurrently, ErrStatusChanged is returned.
+func (r *SQLiteRepository) ChangeStatus(
+	ctx context.Context,
+	id int64,
+	from entities.UserStatus,
+	params entities.ChangeUserStatusParams,
+) (entities.User, error) {
+	var user entities.User
+
+	now := r.timestamp()
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		query, args, err := sq.
+			Update(userTableName).
+			Set("status", params.Status).
+			Set("updated_at", now).
+			Where(sq.Eq{"id": id, "status": from, "deleted": false}).
+			Suffix(returningUser).
+			ToSql()
+		if err != nil {
+			return errors.Wrap(err, "failed to build a query")
+		}
+
+		user, err = getSQLiteUser(ctx, tx, query, args...)
+		if err != nil {
+			if sqlscan.NotFound(err) {
+				return entities.ErrStatusChanged
+			}
+
+			return errors.Wrap(err, "failed to execute a query")
+		}
+
+		cause := entities.StatusChangeCause{Reason: params.Reason, Actor: params.Actor}
+		if err := insertSQLiteStatusChange(ctx, tx, id, from, params.Status, cause, now); err != nil {
+			return err
+		}
+
+		return loadSQLiteExternalIDs(ctx, tx, &user)
+	})
+
+	return user, err
+}
+
+// insertSQLiteStatusChange records the change of the status of the user made in the transaction.
+func insertSQLiteStatusChange(
+	ctx context.Context,
+	tx *sql.Tx,
+	userID int64,
+	from, to entities.UserStatus,
+	cause entities.StatusChangeCause,
+	now time.Time,
+) error {
+	query, args, err := sq.
+		Insert(userStatusChangeTableName).
+		Columns("user_id", "from_status", "to_status", "reason", "actor", "created_at").
+		Values(userID, from, to, cause.Reason, cause.Actor, now).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build a query")
+	}
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return errors.Wrap(err, "failed to execute a query")
+	}
+
+	return nil
+}
+
+// ListStatusChanges returns the recorded changes of the status of the user, the oldest first.
+func (r *SQLiteRepository) ListStatusChanges(ctx context.Context, userID int64) ([]entities.UserStatusChange, error) {
+	query, args, err := sq.
+		Select(userStatusChangeColumns...).
+		From(userStatusChangeTableName).
+		Where(sq.Eq{"user_id": userID}).
+		OrderBy("id").
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build a query")
+	}
+
+	var changes []entities.UserStatusChange
+
+	err = sqlscan.Select(ctx, r.db, &changes, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to execute a query")
+	}
+
+	return changes, nil
+}
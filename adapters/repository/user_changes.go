@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+	"github.com/torwig/user-service/log"
+)
+
+const (
+	userChangeTableName = "user_changes"
+	// userChangeChannel is notified with the ID of every change by a trigger on the users table.
+	userChangeChannel = "user_changes"
+)
+
+type userChange struct {
+	ChangeID  int64
+	Type      entities.UserChangeType
+	ChangedAt time.Time
+	entities.User
+}
+
+// UserChangesAfter returns up to limit changes following the given one, along with the users right after
+// the changes. The changes recorded before users were snapshotted come with the current state of the users.
+func (r *PostgresRepository) UserChangesAfter(
+	ctx context.Context,
+	afterID int64,
+	limit uint64,
+) ([]entities.UserChange, error) {
+	defer r.observeQuery("list_user_changes", time.Now())
+
+	var rows []userChange
+
+	// users are deleted softly, so there is a user for every change; the fields of the snapshot
+	// replace the current ones of the user
+	stmt := sq.
+		Select("c.id AS change_id", "c.type", "c.created_at AS changed_at",
+			"s.id", "s.first_name", "s.last_name", "s.phone_number", "s.phone_verified_at", "s.address", "s.email",
+			"s.email_verified_at", "s.status", "s.attributes", "s.deleted", "s.created_at", "s.updated_at", "s.deleted_at").
+		From(userChangeTableName + " c").
+		Join(userTableName + " u ON u.id = c.user_id").
+		JoinClause("CROSS JOIN LATERAL jsonb_populate_record(u, c.snapshot) s").
+		Where(sq.Gt{"c.id": afterID}).
+		OrderBy("c.id").
+		Limit(limit).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build a query")
+	}
+
+	err = pgxscan.Select(ctx, r.db, &rows, sql, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to execute a query")
+	}
+
+	users := make([]*entities.User, 0, len(rows))
+	for i := range rows {
+		users = append(users, &rows[i].User)
+	}
+
+	if err := loadExternalIDs(ctx, r.db, users...); err != nil {
+		return nil, err
+	}
+
+	changes := make([]entities.UserChange, 0, len(rows))
+	for _, row := range rows {
+		changes = append(changes, entities.UserChange{
+			ID:        row.ChangeID,
+			Type:      row.Type,
+			User:      row.User,
+			CreatedAt: row.ChangedAt,
+		})
+	}
+
+	return changes, nil
+}
+
+// LatestUserChangeID returns the ID of the last change, 0 if there are none.
+func (r *PostgresRepository) LatestUserChangeID(ctx context.Context) (int64, error) {
+	defer r.observeQuery("get_latest_user_change", time.Now())
+
+	var id int64
+
+	err := r.db.QueryRow(ctx, "SELECT COALESCE(MAX(id), 0) FROM "+userChangeTableName).Scan(&id)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to execute a query")
+	}
+
+	return id, nil
+}
+
+// PurgeUserChanges removes the changes older than ttl, streams can't be resumed from them anymore.
+func (r *PostgresRepository) PurgeUserChanges(ctx context.Context, ttl time.Duration) error {
+	defer r.observeQuery("purge_user_changes", time.Now())
+
+	stmt := sq.
+		Delete(userChangeTableName).
+		Where(sq.Lt{"created_at": time.Now().UTC().Add(-ttl)}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := stmt.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build a query")
+	}
+
+	return r.slowStatements(ctx, r.db, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, sql, args...); err != nil {
+			return errors.Wrap(err, "failed to execute a query")
+		}
+
+		return nil
+	})
+}
+
+// ListenUserChanges calls notify with the ID of every change of a user until ctx is done or the connection breaks.
+// It listens on a dedicated connection rather than one of the pool. Notify is called once with 0 as soon as
+// listening has started, so that the changes made while nobody was listening can be caught up.
+func (r *PostgresRepository) ListenUserChanges(ctx context.Context, notify func(changeID int64)) error {
+	conn, err := pgx.ConnectConfig(ctx, r.db.Config().ConnConfig)
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to the database")
+	}
+
+	defer func() {
+		_ = conn.Close(context.Background())
+	}()
+
+	_, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{userChangeChannel}.Sanitize())
+	if err != nil {
+		return errors.Wrap(err, "failed to listen")
+	}
+
+	notify(0)
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to wait for notification")
+		}
+
+		changeID, err := strconv.ParseInt(notification.Payload, 10, 64)
+		if err != nil {
+			log.FromContext(ctx).Warnf("invalid user change %q", notification.Payload)
+
+			continue
+		}
+
+		notify(changeID)
+	}
+}
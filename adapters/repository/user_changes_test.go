@@ -0,0 +1,148 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torwig/user-service/entities"
+)
+
+func TestPostgresRepository_UserChangesAfter(t *testing.T) {
+	ctx := context.Background()
+
+	latestID, err := repo.LatestUserChangeID(ctx)
+	require.NoError(t, err)
+
+	user, err := repo.Create(ctx, entities.CreateUserParams{
+		FirstName: "Quinn", LastName: "Hale", PhoneNumber: "+1000000101", Address: "Miami",
+	})
+	require.NoError(t, err)
+
+	address := "Boston"
+	_, err = repo.Update(ctx, user.ID, entities.UpdateUserParams{Address: &address})
+	require.NoError(t, err)
+
+	require.NoError(t, repo.Delete(ctx, user.ID, entities.StatusChangeCause{}))
+
+	changes, err := repo.UserChangesAfter(ctx, latestID, 10)
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+
+	// every change comes with the user as it was right after the change
+	assert.Equal(t, entities.UserCreated, changes[0].Type)
+	assert.Equal(t, user.ID, changes[0].User.ID)
+	assert.Equal(t, "Miami", changes[0].User.Address)
+	assert.False(t, changes[0].User.Deleted)
+
+	assert.Equal(t, entities.UserUpdated, changes[1].Type)
+	assert.Equal(t, "Boston", changes[1].User.Address)
+	assert.False(t, changes[1].User.Deleted)
+
+	assert.Equal(t, entities.UserDeleted, changes[2].Type)
+	assert.Equal(t, "Boston", changes[2].User.Address)
+	assert.True(t, changes[2].User.Deleted)
+	assert.Equal(t, entities.UserStatusDeleted, changes[2].User.Status)
+
+	assert.Less(t, changes[0].ID, changes[1].ID)
+	assert.Less(t, changes[1].ID, changes[2].ID)
+
+	latestID, err = repo.LatestUserChangeID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, changes[2].ID, latestID)
+
+	t.Run("Limit", func(t *testing.T) {
+		limited, err := repo.UserChangesAfter(ctx, changes[0].ID-1, 2)
+		require.NoError(t, err)
+		require.Len(t, limited, 2)
+		assert.Equal(t, changes[0].ID, limited[0].ID)
+		assert.Equal(t, changes[1].ID, limited[1].ID)
+	})
+
+	t.Run("Change committed after a later one", func(t *testing.T) {
+		afterID, err := repo.LatestUserChangeID(ctx)
+		require.NoError(t, err)
+
+		conn, err := pgx.Connect(ctx, postgresDSN)
+		require.NoError(t, err)
+
+		defer func() {
+			_ = conn.Close(context.Background())
+		}()
+
+		// the change of the user created in the transaction takes the lower ID but isn't visible yet
+		tx, err := conn.Begin(ctx)
+		require.NoError(t, err)
+
+		defer func() {
+			_ = tx.Rollback(context.Background())
+		}()
+
+		_, err = tx.Exec(ctx, `INSERT INTO users (first_name, last_name, phone_number, address, updated_at)
+			VALUES ('Rhea', 'Hale', '+1000000102', 'Miami', NOW())`)
+		require.NoError(t, err)
+
+		committedUser, err := repo.Create(ctx, entities.CreateUserParams{
+			FirstName: "Sage", LastName: "Hale", PhoneNumber: "+1000000103", Address: "Miami",
+		})
+		require.NoError(t, err)
+
+		changes, err := repo.UserChangesAfter(ctx, afterID, 10)
+		require.NoError(t, err)
+		require.Len(t, changes, 1)
+		assert.Equal(t, committedUser.ID, changes[0].User.ID)
+
+		require.NoError(t, tx.Commit(ctx))
+
+		// reading again from the same position finds the change behind the one read already
+		changes, err = repo.UserChangesAfter(ctx, afterID, 10)
+		require.NoError(t, err)
+		require.Len(t, changes, 2)
+		assert.Equal(t, "Rhea", changes[0].User.FirstName)
+		assert.Equal(t, committedUser.ID, changes[1].User.ID)
+		assert.Less(t, changes[0].ID, changes[1].ID)
+	})
+}
+
+func TestPostgresRepository_ListenUserChanges(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changeIDs := make(chan int64, 10)
+
+	done := make(chan error)
+	go func() {
+		done <- repo.ListenUserChanges(ctx, func(changeID int64) {
+			changeIDs <- changeID
+		})
+	}()
+
+	// 0 tells that listening has started
+	select {
+	case changeID := <-changeIDs:
+		require.Zero(t, changeID)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "listening hasn't started")
+	}
+
+	_, err := repo.Create(ctx, entities.CreateUserParams{
+		FirstName: "Tate", LastName: "Hale", PhoneNumber: "+1000000104", Address: "Miami",
+	})
+	require.NoError(t, err)
+
+	latestID, err := repo.LatestUserChangeID(ctx)
+	require.NoError(t, err)
+
+	select {
+	case changeID := <-changeIDs:
+		assert.Equal(t, latestID, changeID)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "change hasn't been notified")
+	}
+
+	cancel()
+	require.Error(t, <-done)
+}
@@ -0,0 +1,90 @@
+// Package schema validates the custom attributes of users against a JSON Schema.
+package schema
+
+import (
+	"bytes"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/torwig/user-service/entities"
+)
+
+// attributesSchemaURL identifies the schema within the compiler, it's never fetched.
+const attributesSchemaURL = "attributes.schema.json"
+
+type Config struct {
+	// AttributesFile is the JSON Schema every user's attributes must match, any are accepted if it's empty.
+	AttributesFile string
+}
+
+func (c Config) Enabled() bool {
+	return c.AttributesFile != ""
+}
+
+// AttributesSchema is the global schema of the attributes, shared by all the users.
+type AttributesSchema struct {
+	schema *jsonschema.Schema
+}
+
+// LoadAttributesSchema reads and compiles the schema from the file.
+func LoadAttributesSchema(path string) (*AttributesSchema, error) {
+	doc, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read attributes schema")
+	}
+
+	return NewAttributesSchema(doc)
+}
+
+// NewAttributesSchema compiles the JSON Schema document, the draft is given by its $schema (2020-12 by default).
+func NewAttributesSchema(doc []byte) (*AttributesSchema, error) {
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+
+	if err := compiler.AddResource(attributesSchemaURL, bytes.NewReader(doc)); err != nil {
+		return nil, errors.Wrap(err, "failed to parse attributes schema")
+	}
+
+	schema, err := compiler.Compile(attributesSchemaURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to compile attributes schema")
+	}
+
+	return &AttributesSchema{schema: schema}, nil
+}
+
+// ValidateAttributes returns an error wrapping entities.ErrInvalidAttributes that describes the first violation.
+func (s *AttributesSchema) ValidateAttributes(attributes entities.Attributes) error {
+	// the validator understands only the types JSON decodes to
+	normalized, err := attributes.Normalize()
+	if err != nil {
+		return err
+	}
+
+	err = s.schema.Validate(map[string]any(normalized))
+	if err != nil {
+		var validationErr *jsonschema.ValidationError
+		if errors.As(err, &validationErr) {
+			return errors.Wrap(entities.ErrInvalidAttributes, describeViolation(validationErr))
+		}
+
+		return errors.Wrap(err, "failed to validate attributes")
+	}
+
+	return nil
+}
+
+// describeViolation returns the most specific cause, e.g. "/level: expected integer, but got string".
+func describeViolation(err *jsonschema.ValidationError) string {
+	for len(err.Causes) > 0 {
+		err = err.Causes[0]
+	}
+
+	location := err.InstanceLocation
+	if location == "" {
+		location = "/"
+	}
+
+	return location + ": " + err.Message
+}
@@ -0,0 +1,52 @@
+package schema_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torwig/user-service/adapters/schema"
+	"github.com/torwig/user-service/entities"
+)
+
+func TestAttributesSchema_ValidateAttributes(t *testing.T) {
+	attributesSchema, err := schema.NewAttributesSchema([]byte(`{
+		"type": "object",
+		"properties": {
+			"team": {"type": "string"},
+			"level": {"type": "integer", "minimum": 1}
+		},
+		"additionalProperties": false
+	}`))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		attributes entities.Attributes
+		violation  string
+	}{
+		{name: "valid", attributes: entities.Attributes{"team": "payments", "level": 2}},
+		{name: "no attributes"},
+		{name: "wrong type", attributes: entities.Attributes{"level": "senior"}, violation: "/level"},
+		{name: "below minimum", attributes: entities.Attributes{"level": 0}, violation: "/level"},
+		{name: "unknown attribute", attributes: entities.Attributes{"nickname": "Johnny"}, violation: "nickname"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := attributesSchema.ValidateAttributes(tt.attributes)
+			if tt.violation == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			require.ErrorIs(t, err, entities.ErrInvalidAttributes)
+			assert.Contains(t, err.Error(), tt.violation)
+		})
+	}
+}
+
+func TestNewAttributesSchema_Invalid(t *testing.T) {
+	_, err := schema.NewAttributesSchema([]byte(`{"type": "no such type"}`))
+	assert.Error(t, err)
+}
@@ -1,67 +1,119 @@
 package main
 
 import (
-	"context"
+	"flag"
 	"fmt"
-	"os/signal"
-	"syscall"
+	"os"
 
 	"github.com/pkg/errors"
-	"github.com/torwig/user-service/adapters/repository"
 	"github.com/torwig/user-service/config"
-	"github.com/torwig/user-service/log"
-	"github.com/torwig/user-service/ports/http"
-	"github.com/torwig/user-service/ports/http/jwt"
-	"github.com/torwig/user-service/service"
-	"go.uber.org/zap"
-	"golang.org/x/sync/errgroup"
 )
 
 var version = "Undefined"
 
+const usage = `Usage: users [flags] [command]
+
+Commands:
+  serve                    run the HTTP API (default)
+  migrate up               apply all pending migrations
+  migrate down [-all] [N]  roll back the last N (1 by default) or all migrations
+  migrate status           print the current and the latest schema version
+  migrate force V          set the schema version to V and clear the dirty flag
+  user get ID              print a user
+  user list                print a page of users (-after ID, -limit N, -deleted)
+  user create              create a user (-first-name, -last-name, -phone-number, -address, -status)
+  user update ID           change the given fields of a user
+  user delete ID           delete a user
+  user restore ID          undo the deletion of a user
+  user suspend|lock ID     bar a user from using their credentials (-reason)
+  user activate ID         activate a pending, suspended or locked user (-reason)
+  user export              write all users as JSON lines (-deleted)
+  token issue              mint an access token (-user-id ID, -ttl, -create, -view, -update, -delete, -all)
+  token inspect TOKEN|-    decode an access token and explain whether it's accepted
+
+  config print             print the effective configuration with secrets redacted
+
+User commands require the operator name (-operator or USERS_OPERATOR), which is recorded
+in the audit log, and print a table or JSON (-output table|json).
+`
+
 func main() {
-	cfg := config.CreateFromEnv()
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+}
 
-	logger := log.NewZapLogger(cfg.Log)
-	defer func(logger *zap.SugaredLogger) {
-		_ = logger.Sync()
-	}(logger)
+func run(args []string) error {
+	cfg, args, err := config.Load(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			printUsage()
+			return nil
+		}
 
-	logger.Infof("starting service, version=%s", version)
+		return errors.Wrap(err, "failed to load config")
+	}
 
-	repo, err := repository.NewPostgresRepository(cfg.Repository)
-	if err != nil {
-		panic(fmt.Sprintf("failed to create repository: %s", err))
+	command := "serve"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
 	}
 
-	logger.Info("successfully connected to user repository")
+	switch command {
+	case "help":
+		printUsage()
+		return nil
+	case "config":
+		return runConfig(cfg, args)
+	case "token":
+		if err := cfg.ValidateToken(); err != nil {
+			return err
+		}
+
+		return runToken(cfg, args)
+	}
 
-	svc := service.New(repo)
-	authenticator := jwt.NewAuthenticator(cfg.JWT)
-	handler := http.NewHandler(svc, authenticator, logger)
-	srv := http.NewServer(cfg.HTTP)
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
 
-	signalCtx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer cancel()
+	switch command {
+	case "serve":
+		return serve(cfg)
+	case "migrate", "user":
+		// users kept in memory live only as long as the serving process
+		if cfg.Repository.Type != config.RepositoryPostgres {
+			return errors.Errorf("%s command requires the postgres repository", command)
+		}
 
-	errGroup, errCtx := errgroup.WithContext(signalCtx)
+		if command == "migrate" {
+			return runMigrate(cfg, args)
+		}
 
-	errGroup.Go(func() error {
-		logger.Infof("starting HTTP-server on %s", cfg.HTTP.BindAddress)
+		return runUser(cfg, args)
+	default:
+		fmt.Fprint(os.Stderr, usage)
 
-		return srv.Run(handler.Router())
-	})
+		return errors.Errorf("unknown command %q", command)
+	}
+}
 
-	errGroup.Go(func() error {
-		<-errCtx.Done()
+func printUsage() {
+	fmt.Print(usage)
+	fmt.Println("\nFlags (override the environment variables, which override the config file):")
+	config.Usage(os.Stdout)
+}
 
-		return srv.Shutdown()
-	})
+// runConfig prints the configuration even if it's invalid, so that the problems can be tracked down.
+func runConfig(cfg *config.Config, args []string) error {
+	if len(args) != 1 || args[0] != "print" {
+		return errors.New("usage: users config print")
+	}
 
-	err = errGroup.Wait()
-	if err != nil && errors.Is(err, context.Canceled) {
-		logger.Errorf("service error: %s", err)
+	if err := cfg.Print(os.Stdout); err != nil {
+		return err
 	}
 
-	logger.Infof("service is about to exit")
+	return cfg.Validate()
 }
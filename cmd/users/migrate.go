@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/adapters/repository"
+	"github.com/torwig/user-service/config"
+	"go.uber.org/zap"
+)
+
+var errMigrateUsage = errors.New("usage: users migrate up|down [-all] [N]|status|force V")
+
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errMigrateUsage
+	}
+
+	migrator, err := repository.NewMigrator(cfg.Repository.DSN)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = migrator.Close()
+	}()
+
+	switch args[0] {
+	case "up":
+		err = migrator.Up()
+	case "down":
+		err = migrateDown(migrator, args[1:])
+	case "force":
+		if len(args) != 2 {
+			return errMigrateUsage
+		}
+
+		version, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			return errors.Wrapf(convErr, "invalid version %q", args[1])
+		}
+
+		err = migrator.Force(version)
+	case "status":
+	default:
+		return errMigrateUsage
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return printMigrationStatus(migrator)
+}
+
+func migrateDown(migrator *repository.Migrator, args []string) error {
+	flags := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+	all := flags.Bool("all", false, "roll back all migrations")
+
+	if err := flags.Parse(args); err != nil {
+		return errMigrateUsage
+	}
+
+	if *all {
+		return migrator.DownAll()
+	}
+
+	steps := 1
+
+	if flags.NArg() > 0 {
+		n, err := strconv.Atoi(flags.Arg(0))
+		if err != nil || n <= 0 {
+			return errors.Errorf("invalid number of migrations %q", flags.Arg(0))
+		}
+
+		steps = n
+	}
+
+	return migrator.Down(steps)
+}
+
+func printMigrationStatus(migrator *repository.Migrator) error {
+	status, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("version: %d\nlatest: %d\ndirty: %t\n", status.Version, status.Latest, status.Dirty)
+
+	return nil
+}
+
+// migrateUp applies pending migrations before the service starts.
+func migrateUp(repoCfg repository.Config, logger *zap.SugaredLogger) error {
+	migrator, err := repository.NewMigrator(repoCfg.DSN)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = migrator.Close()
+	}()
+
+	if err := migrator.Up(); err != nil {
+		return err
+	}
+
+	status, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+
+	logger.Infof("database schema is at version %d", status.Version)
+
+	return nil
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/adapters/cache"
+	"github.com/torwig/user-service/adapters/notify"
+	"github.com/torwig/user-service/adapters/repository"
+	"github.com/torwig/user-service/adapters/schema"
+	"github.com/torwig/user-service/config"
+	"github.com/torwig/user-service/db"
+	"github.com/torwig/user-service/log"
+	"github.com/torwig/user-service/metrics"
+	"github.com/torwig/user-service/ports/http"
+	"github.com/torwig/user-service/ports/http/jwt"
+	"github.com/torwig/user-service/service"
+	"github.com/torwig/user-service/tracing"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	purgeInterval      = 10 * time.Minute
+	rateLimitBucketTTL = 24 * time.Hour
+	// userChangeRetention is how long a stream of user changes can be resumed.
+	userChangeRetention = 24 * time.Hour
+
+	tracingShutdownTimeout = 5 * time.Second
+)
+
+// serve runs the HTTP API until SIGINT or SIGTERM is received.
+func serve(cfg *config.Config) error {
+	logger := log.NewZapLogger(cfg.Log)
+	defer func(logger *zap.SugaredLogger) {
+		_ = logger.Sync()
+	}(logger)
+
+	logger.Infof("starting service, version=%s", version)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, version)
+	if err != nil {
+		return errors.Wrap(err, "failed to set up tracing")
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Errorf("failed to flush traces: %s", err)
+		}
+	}()
+
+	prom := metrics.NewPrometheus()
+
+	store, err := openStorage(cfg.Repository, logger, prom)
+	if err != nil {
+		return err
+	}
+
+	defer store.close()
+
+	var users service.UserRepository = store.users
+
+	var userCache *cache.UserRepository
+	if cfg.Cache.Enabled() {
+		var cacheOptions []cache.Option
+		if cfg.Cache.ListenNotify {
+			cacheOptions = append(cacheOptions, cache.WithInvalidationPublisher(store.postgres))
+		}
+
+		cacheCfg := cfg.Cache
+		cacheCfg.PrimaryReadWindow = cfg.Repository.ReadYourWritesWindow
+
+		userCache = cache.NewUserRepository(store.users, cacheCfg, cacheOptions...)
+		users = userCache
+	}
+
+	serviceOptions, err := newServiceOptions(cfg, store.phoneVerifications, logger)
+	if err != nil {
+		return err
+	}
+
+	svc := service.New(users, append(serviceOptions, service.WithObserver(prom))...)
+	keySvc := service.NewAPIKeyService(store.apiKeys, users)
+	authenticator := jwt.NewAuthenticator(cfg.JWT)
+
+	readiness := http.NewReadiness(store.readinessChecks...)
+
+	handlerOptions := []http.HandlerOption{
+		http.WithRequestObserver(prom),
+		http.WithReadiness(readiness),
+		http.WithTracing(otel.GetTracerProvider(), otel.GetTextMapPropagator()),
+		http.WithAccessLogSampleRatio(cfg.HTTP.AccessLogSampleRatio),
+		http.WithTrustedProxies(cfg.HTTP.TrustedProxies),
+	}
+
+	if cfg.HTTP.TLS.ClientAuthEnabled() {
+		certAuthenticator, certErr := http.NewClientCertAuthenticator(cfg.HTTP.TLS.ClientCertUsers)
+		if certErr != nil {
+			return errors.Wrap(certErr, "failed to create client certificate authenticator")
+		}
+
+		handlerOptions = append(handlerOptions, http.WithClientCertAuthenticator(certAuthenticator))
+	}
+
+	if cfg.HTTP.RateLimit.Enabled() {
+		var rateLimitStore http.RateLimitStore = repository.NewInMemoryRateLimitStore()
+		if cfg.HTTP.RateLimit.Store == config.RateLimitStorePostgres {
+			rateLimitStore = store.postgres
+		}
+
+		handlerOptions = append(handlerOptions,
+			http.WithRateLimiter(http.NewRateLimiter(cfg.HTTP.RateLimit, rateLimitStore)))
+	}
+
+	var userChanges *service.UserChangeFeed
+
+	if store.postgres != nil {
+		userChanges = service.NewUserChangeFeed(store.postgres)
+
+		handlerOptions = append(handlerOptions,
+			http.WithIdempotencyStore(store.postgres, cfg.HTTP.IdempotencyKeyTTL),
+			http.WithUserChangeFeed(userChanges))
+	} else {
+		logger.Warn("Idempotency-Key headers are ignored without the postgres repository")
+	}
+
+	handler := http.NewHandler(svc, keySvc, authenticator, logger, handlerOptions...)
+	srv := http.NewServer(cfg.HTTP, logger, http.WithDrainedReadiness(readiness))
+	adminSrv := http.NewServer(cfg.Admin, logger)
+
+	signalCtx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	errGroup, errCtx := errgroup.WithContext(signalCtx)
+
+	errGroup.Go(func() error {
+		logger.Infof("starting HTTP-server on %s", cfg.HTTP.BindAddress)
+
+		return srv.Run(handler.Router())
+	})
+
+	errGroup.Go(func() error {
+		logger.Infof("starting admin HTTP-server on %s", cfg.Admin.BindAddress)
+
+		return adminSrv.Run(http.NewAdminHandler(prom.Handler()).Router())
+	})
+
+	errGroup.Go(func() error {
+		<-errCtx.Done()
+
+		return srv.Shutdown()
+	})
+
+	errGroup.Go(func() error {
+		<-errCtx.Done()
+
+		return adminSrv.Shutdown()
+	})
+
+	if userCache != nil && cfg.Cache.ListenNotify {
+		errGroup.Go(func() error {
+			store.postgres.ListenUserInvalidations(log.NewContext(errCtx, logger), userCache)
+
+			return nil
+		})
+	}
+
+	if userChanges != nil {
+		errGroup.Go(func() error {
+			userChanges.Run(log.NewContext(errCtx, logger))
+
+			return nil
+		})
+	}
+
+	if store.postgres != nil {
+		errGroup.Go(func() error {
+			purgeExpiredRecords(errCtx, store.postgres, logger)
+
+			return nil
+		})
+	}
+
+	err = errGroup.Wait()
+	if err != nil && errors.Is(err, context.Canceled) {
+		logger.Errorf("service error: %s", err)
+	}
+
+	logger.Infof("service is about to exit")
+
+	return nil
+}
+
+// storage holds the repositories the service keeps its data in.
+type storage struct {
+	users   service.UserRepository
+	apiKeys service.APIKeyRepository
+	// phoneVerifications keeps the codes sent to verify phone numbers.
+	phoneVerifications service.PhoneVerificationRepository
+	readinessChecks    []http.ReadinessCheck
+	// postgres is set only if the data is kept in Postgres.
+	postgres *repository.PostgresRepository
+	closer   func()
+}
+
+func openStorage(repoCfg repository.Config, logger *zap.SugaredLogger, prom *metrics.Prometheus) (*storage, error) {
+	switch repoCfg.Type {
+	case config.RepositoryMemory:
+		logger.Warn("users are kept in memory and will be lost on exit")
+
+		repo := repository.NewInMemoryRepository()
+
+		return &storage{users: repo, apiKeys: repo, phoneVerifications: repo}, nil
+	case config.RepositorySQLite:
+		repo, err := repository.NewSQLiteRepository(repoCfg.DSN)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create repository")
+		}
+
+		logger.Info("successfully opened SQLite user repository")
+
+		return &storage{
+			users:              repo,
+			apiKeys:            repo,
+			phoneVerifications: repo,
+			readinessChecks:    []http.ReadinessCheck{{Name: "sqlite", Check: repo.Ping}},
+			closer:             repo.Close,
+		}, nil
+	}
+
+	if repoCfg.AutoMigrate {
+		if err := migrateUp(repoCfg, logger); err != nil {
+			return nil, err
+		}
+	}
+
+	latestSchemaVersion, err := db.LatestVersion()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get latest schema version")
+	}
+
+	repo, err := repository.NewPostgresRepository(repoCfg, repository.WithQueryObserver(prom))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create repository")
+	}
+
+	logger.Info("successfully connected to user repository")
+
+	prom.RegisterPool(repo.Stat)
+
+	return &storage{
+		users:              repo,
+		apiKeys:            repo,
+		phoneVerifications: repo,
+		readinessChecks: []http.ReadinessCheck{
+			{Name: "postgres", Check: repo.Ping},
+			{Name: "migrations", Check: func(ctx context.Context) error {
+				return repo.CheckSchemaVersion(ctx, latestSchemaVersion)
+			}},
+		},
+		postgres: repo,
+		closer:   repo.Close,
+	}, nil
+}
+
+// newServiceOptions validates and verifies the users the same way wherever they are changed, by the API or the CLI.
+func newServiceOptions(
+	cfg *config.Config,
+	phoneVerifications service.PhoneVerificationRepository,
+	logger *zap.SugaredLogger,
+) ([]service.Option, error) {
+	var options []service.Option
+
+	if cfg.Schema.Enabled() {
+		attributesSchema, err := schema.LoadAttributesSchema(cfg.Schema.AttributesFile)
+		if err != nil {
+			return nil, err
+		}
+
+		options = append(options, service.WithAttributesValidator(attributesSchema))
+	}
+
+	if cfg.EmailVerification.Enabled() || cfg.PhoneVerification.Enabled() {
+		sender, err := notify.New(cfg.Notify, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		if cfg.EmailVerification.Enabled() {
+			options = append(options, service.WithEmailVerification(cfg.EmailVerification, sender))
+		}
+
+		if cfg.PhoneVerification.Enabled() {
+			options = append(options, service.WithPhoneVerification(cfg.PhoneVerification, phoneVerifications, sender))
+		}
+	}
+
+	return options, nil
+}
+
+func (s *storage) close() {
+	if s.closer != nil {
+		s.closer()
+	}
+}
+
+// purgeExpiredRecords removes stale rate limit buckets, expired idempotency keys and old user changes
+// until ctx is done.
+func purgeExpiredRecords(ctx context.Context, repo *repository.PostgresRepository, logger *zap.SugaredLogger) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := repo.PurgeRateLimitBuckets(ctx, rateLimitBucketTTL); err != nil {
+				logger.Errorf("failed to purge rate limit buckets: %s", err)
+			}
+
+			if err := repo.PurgeIdempotencyKeys(ctx); err != nil {
+				logger.Errorf("failed to purge idempotency keys: %s", err)
+			}
+
+			if err := repo.PurgeUserChanges(ctx, userChangeRetention); err != nil {
+				logger.Errorf("failed to purge user changes: %s", err)
+			}
+
+			if err := repo.PurgePhoneVerifications(ctx); err != nil {
+				logger.Errorf("failed to purge phone verifications: %s", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/config"
+	"github.com/torwig/user-service/ports/http/jwt"
+)
+
+const defaultTokenTTL = time.Hour
+
+var (
+	errTokenUsage    = errors.New("usage: users token issue -user-id ID [flags] | users token inspect TOKEN|-")
+	errTokenRejected = errors.New("access token is rejected")
+)
+
+func runToken(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errTokenUsage
+	}
+
+	authenticator := jwt.NewAuthenticator(cfg.JWT)
+
+	switch args[0] {
+	case "issue":
+		return issueToken(authenticator, args[1:])
+	case "inspect":
+		return inspectToken(authenticator, args[1:])
+	default:
+		return errTokenUsage
+	}
+}
+
+func issueToken(authenticator *jwt.Authenticator, args []string) error {
+	var permissions jwt.Permissions
+
+	flags := flag.NewFlagSet("token issue", flag.ContinueOnError)
+	userID := flags.Int64("user-id", 0, "ID of the user the token is issued to")
+	ttl := flags.Duration("ttl", defaultTokenTTL, "lifetime of the token")
+	all := flags.Bool("all", false, "grant all the permissions")
+	flags.BoolVar(&permissions.CreateUsers, "create", false, "grant permission to create users")
+	flags.BoolVar(&permissions.ViewUsers, "view", false, "grant permission to view other users")
+	flags.BoolVar(&permissions.UpdateUsers, "update", false, "grant permission to update other users")
+	flags.BoolVar(&permissions.DeleteUsers, "delete", false, "grant permission to delete other users")
+
+	if err := flags.Parse(args); err != nil {
+		return errTokenUsage
+	}
+
+	if *userID <= 0 {
+		return errors.New("-user-id must be a positive number")
+	}
+
+	if *ttl <= 0 {
+		return errors.New("-ttl must be positive")
+	}
+
+	if *all {
+		permissions = jwt.Permissions{CreateUsers: true, ViewUsers: true, UpdateUsers: true, DeleteUsers: true}
+	}
+
+	token, err := authenticator.IssueAccessToken(*userID, permissions, *ttl)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(token)
+
+	return nil
+}
+
+// inspectToken prints the decoded token and whether the service accepts it. The token is read
+// from stdin if it's given as "-", so that it doesn't end up in the shell history.
+func inspectToken(authenticator *jwt.Authenticator, args []string) error {
+	if len(args) != 1 {
+		return errTokenUsage
+	}
+
+	token := args[0]
+
+	if token == "-" {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return errors.Wrap(err, "failed to read access token")
+		}
+
+		token = line
+	}
+
+	token = strings.TrimPrefix(strings.TrimSpace(token), "Bearer ")
+
+	inspection, err := authenticator.InspectAccessToken(token)
+	if err != nil {
+		return err
+	}
+
+	for _, part := range []struct {
+		name  string
+		value map[string]interface{}
+	}{{"header", inspection.Header}, {"claims", inspection.Claims}} {
+		encoded, err := json.MarshalIndent(part.value, "", "  ")
+		if err != nil {
+			return errors.Wrapf(err, "failed to encode token %s", part.name)
+		}
+
+		fmt.Printf("%s: %s\n", part.name, encoded)
+	}
+
+	for _, name := range []string{"iat", "exp"} {
+		if ts, ok := inspection.Claims[name].(float64); ok {
+			fmt.Printf("%s: %s\n", name, time.Unix(int64(ts), 0).UTC().Format(time.RFC3339))
+		}
+	}
+
+	if inspection.Rejection != nil {
+		fmt.Printf("rejected: %s\n", inspection.Rejection)
+
+		return errTokenRejected
+	}
+
+	fmt.Printf("valid: authenticates user %d\n", inspection.User.ID())
+
+	return nil
+}
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/adapters/cache"
+	"github.com/torwig/user-service/adapters/repository"
+	"github.com/torwig/user-service/config"
+	"github.com/torwig/user-service/entities"
+	"github.com/torwig/user-service/log"
+	"github.com/torwig/user-service/service"
+)
+
+const (
+	envKeyOperator = "USERS_OPERATOR"
+
+	outputTable = "table"
+	outputJSON  = "json"
+
+	defaultListLimit = 50
+	exportPageSize   = 500
+	userCommandTime  = time.Minute
+)
+
+var (
+	errUserUsage = errors.New(
+		"usage: users user get|list|create|update|delete|restore|suspend|lock|activate|export [flags] [id]")
+	errOperatorRequired = errors.New("operator identity is required: set -operator or " + envKeyOperator)
+	errUnknownOutput    = errors.New("output must be either table or json")
+)
+
+// userCommand runs a single user subcommand and records it in the audit log under the operator's name.
+type userCommand struct {
+	svc      *service.Service
+	repo     *repository.PostgresRepository
+	operator string
+	output   string
+	out      io.Writer
+}
+
+// userJSON is the JSON representation of a user printed by the CLI, including fields hidden from the API.
+type userJSON struct {
+	ID              int64            `json:"id"`
+	FirstName       string           `json:"first_name"`
+	LastName        string           `json:"last_name"`
+	PhoneNumber     string           `json:"phone_number"`
+	PhoneVerifiedAt *time.Time       `json:"phone_verified_at,omitempty"`
+	Address         string           `json:"address"`
+	Email           string           `json:"email,omitempty"`
+	EmailVerifiedAt *time.Time       `json:"email_verified_at,omitempty"`
+	Status          string           `json:"status"`
+	Deleted         bool             `json:"deleted"`
+	CreatedAt       time.Time        `json:"created_at"`
+	UpdatedAt       time.Time        `json:"updated_at"`
+	DeletedAt       *time.Time       `json:"deleted_at,omitempty"`
+	ExternalIDs     []externalIDJSON `json:"external_ids,omitempty"`
+	Attributes      map[string]any   `json:"attributes,omitempty"`
+}
+
+type externalIDJSON struct {
+	Source string `json:"source"`
+	ID     string `json:"id"`
+}
+
+func runUser(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errUserUsage
+	}
+
+	subcommand, args := args[0], args[1:]
+
+	flags := flag.NewFlagSet("user "+subcommand, flag.ContinueOnError)
+	operator := flags.String("operator", os.Getenv(envKeyOperator), "name of the operator, recorded in the audit log")
+	output := flags.String("output", outputTable, "output format: table or json")
+
+	var run func(ctx context.Context, c *userCommand, flags *flag.FlagSet) error
+
+	switch subcommand {
+	case "get":
+		run = getUserCommand
+	case "list":
+		run = listUsersCommand(flags)
+	case "create":
+		run = createUserCommand(flags)
+	case "update":
+		run = updateUserCommand(flags)
+	case "delete":
+		run = deleteUserCommand(flags)
+	case "restore":
+		run = restoreUserCommand(flags)
+	case "suspend":
+		run = changeUserStatusCommand(flags, entities.UserStatusSuspended)
+	case "lock":
+		run = changeUserStatusCommand(flags, entities.UserStatusLocked)
+	case "activate":
+		run = changeUserStatusCommand(flags, entities.UserStatusActive)
+	case "export":
+		run = exportUsersCommand(flags)
+	default:
+		return errUserUsage
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return errUserUsage
+	}
+
+	if *operator == "" {
+		return errOperatorRequired
+	}
+
+	if *output != outputTable && *output != outputJSON {
+		return errUnknownOutput
+	}
+
+	logger := log.NewZapLoggerTo(cfg.Log, "stderr")
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	// a command is limited by userCommandTime as a whole rather than statement by statement
+	repoCfg := cfg.Repository
+	repoCfg.StatementTimeout = 0
+	repoCfg.SlowStatementTimeout = 0
+
+	repo, err := repository.NewPostgresRepository(repoCfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to create repository")
+	}
+
+	defer repo.Close()
+
+	ctx, cancel := context.WithTimeout(log.NewContext(context.Background(), logger), userCommandTime)
+	defer cancel()
+
+	var users service.UserRepository = repo
+
+	// the changes must reach the caches of the serving replicas the same way their own changes do
+	if cfg.Cache.Enabled() && cfg.Cache.ListenNotify {
+		users = cache.NewUserRepository(repo, cfg.Cache, cache.WithInvalidationPublisher(repo))
+	}
+
+	serviceOptions, err := newServiceOptions(cfg, repo, logger)
+	if err != nil {
+		return err
+	}
+
+	// the CLI exposes no metrics, so it has no observer of the changes
+	c := &userCommand{
+		svc:      service.New(users, serviceOptions...),
+		repo:     repo,
+		operator: *operator,
+		output:   *output,
+		out:      os.Stdout,
+	}
+
+	return run(ctx, c, flags)
+}
+
+// audit records the outcome of the action. The audit record must not be lost silently,
+// so failing to store it fails the command even if the action itself succeeded.
+func (c *userCommand) audit(ctx context.Context, action string, userID *int64, details map[string]any, err error) error {
+	record := entities.AuditRecord{
+		Operator: c.operator,
+		Action:   action,
+		UserID:   userID,
+		Details:  details,
+	}
+
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	if auditErr := c.repo.RecordAudit(ctx, record); auditErr != nil {
+		if err != nil {
+			return errors.Wrapf(err, "failed to record audit (%s)", auditErr)
+		}
+
+		return errors.Wrap(auditErr, "action succeeded but failed to record audit")
+	}
+
+	return err
+}
+
+func getUserCommand(ctx context.Context, c *userCommand, flags *flag.FlagSet) error {
+	id, err := userIDArgument(flags)
+	if err != nil {
+		return err
+	}
+
+	user, err := c.svc.GetUser(ctx, id)
+	if err = c.audit(ctx, "user.get", &id, nil, err); err != nil {
+		return err
+	}
+
+	return c.printUsers(user)
+}
+
+func listUsersCommand(flags *flag.FlagSet) func(ctx context.Context, c *userCommand, flags *flag.FlagSet) error {
+	afterID := flags.Int64("after", 0, "list users with IDs greater than this one")
+	limit := flags.Uint64("limit", defaultListLimit, "maximum number of users to list")
+	deleted := flags.Bool("deleted", false, "include deleted users")
+	attributes := attributeFilter{}
+	flags.Var(attributes, "attribute", "list users with the attribute, key=value, can be repeated")
+
+	return func(ctx context.Context, c *userCommand, _ *flag.FlagSet) error {
+		params := entities.ListUsersParams{
+			AfterID:        *afterID,
+			Limit:          *limit,
+			IncludeDeleted: *deleted,
+			Attributes:     entities.Attributes(attributes),
+		}
+
+		users, err := c.svc.ListUsers(ctx, params)
+		details := map[string]any{"after": *afterID, "limit": *limit, "deleted": *deleted}
+		if len(attributes) > 0 {
+			details["attributes"] = map[string]any(attributes)
+		}
+
+		if err = c.audit(ctx, "user.list", nil, details, err); err != nil {
+			return err
+		}
+
+		return c.printUsers(users...)
+	}
+}
+
+func createUserCommand(flags *flag.FlagSet) func(ctx context.Context, c *userCommand, flags *flag.FlagSet) error {
+	var params entities.CreateUserParams
+
+	flags.StringVar(&params.FirstName, "first-name", "", "first name")
+	flags.StringVar(&params.LastName, "last-name", "", "last name")
+	flags.StringVar(&params.PhoneNumber, "phone-number", "", "phone number")
+	flags.StringVar(&params.Address, "address", "", "address")
+	flags.StringVar(&params.Email, "email", "", "email, optional")
+	flags.Func("status", "pending or active (default)", func(s string) error {
+		params.Status = entities.UserStatus(s)
+		return nil
+	})
+
+	return func(ctx context.Context, c *userCommand, _ *flag.FlagSet) error {
+		if params.FirstName == "" || params.LastName == "" || params.PhoneNumber == "" || params.Address == "" {
+			return errors.New("-first-name, -last-name, -phone-number and -address are required")
+		}
+
+		user, err := c.svc.CreateUser(ctx, params)
+
+		var userID *int64
+		if err == nil {
+			userID = &user.ID
+		}
+
+		if err = c.audit(ctx, "user.create", userID, nil, err); err != nil {
+			return err
+		}
+
+		return c.printUsers(user)
+	}
+}
+
+func updateUserCommand(flags *flag.FlagSet) func(ctx context.Context, c *userCommand, flags *flag.FlagSet) error {
+	firstName := flags.String("first-name", "", "new first name")
+	lastName := flags.String("last-name", "", "new last name")
+	phoneNumber := flags.String("phone-number", "", "new phone number")
+	address := flags.String("address", "", "new address")
+	email := flags.String("email", "", "new email, which has to be verified again, empty removes it")
+
+	return func(ctx context.Context, c *userCommand, flags *flag.FlagSet) error {
+		id, err := userIDArgument(flags)
+		if err != nil {
+			return err
+		}
+
+		var (
+			params  entities.UpdateUserParams
+			changed []string
+		)
+
+		// only the flags given explicitly are changed, so that a field can't be cleared by accident
+		flags.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "first-name":
+				params.FirstName = firstName
+			case "last-name":
+				params.LastName = lastName
+			case "phone-number":
+				params.PhoneNumber = phoneNumber
+			case "address":
+				params.Address = address
+			case "email":
+				params.Email = email
+			default:
+				return
+			}
+
+			changed = append(changed, f.Name)
+		})
+
+		if len(changed) == 0 {
+			return errors.New("nothing to update")
+		}
+
+		user, err := c.svc.UpdateUser(ctx, id, params)
+		if err = c.audit(ctx, "user.update", &id, map[string]any{"fields": changed}, err); err != nil {
+			return err
+		}
+
+		return c.printUsers(user)
+	}
+}
+
+// deleteUserCommand deletes a user, the operator is recorded as the actor of the change of its status.
+func deleteUserCommand(flags *flag.FlagSet) func(ctx context.Context, c *userCommand, flags *flag.FlagSet) error {
+	reason := flags.String("reason", "", "why the user is deleted")
+
+	return func(ctx context.Context, c *userCommand, flags *flag.FlagSet) error {
+		id, err := userIDArgument(flags)
+		if err != nil {
+			return err
+		}
+
+		err = c.svc.DeleteUser(ctx, id, entities.StatusChangeCause{Reason: *reason, Actor: "operator:" + c.operator})
+		if err = c.audit(ctx, "user.delete", &id, reasonDetails(*reason), err); err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(c.out, "user %d was deleted\n", id)
+
+		return err
+	}
+}
+
+// restoreUserCommand restores a user, the operator is recorded as the actor of the change of its status.
+func restoreUserCommand(flags *flag.FlagSet) func(ctx context.Context, c *userCommand, flags *flag.FlagSet) error {
+	reason := flags.String("reason", "", "why the user is restored")
+
+	return func(ctx context.Context, c *userCommand, flags *flag.FlagSet) error {
+		id, err := userIDArgument(flags)
+		if err != nil {
+			return err
+		}
+
+		user, err := c.svc.RestoreUser(ctx, id, entities.StatusChangeCause{Reason: *reason, Actor: "operator:" + c.operator})
+		if err = c.audit(ctx, "user.restore", &id, reasonDetails(*reason), err); err != nil {
+			return err
+		}
+
+		return c.printUsers(user)
+	}
+}
+
+// reasonDetails records the reason in the audit log if one is given.
+func reasonDetails(reason string) map[string]any {
+	if reason == "" {
+		return nil
+	}
+
+	return map[string]any{"reason": reason}
+}
+
+// changeUserStatusCommand changes the status of a user, the operator is recorded as the actor of the change.
+func changeUserStatusCommand(
+	flags *flag.FlagSet,
+	status entities.UserStatus,
+) func(ctx context.Context, c *userCommand, flags *flag.FlagSet) error {
+	reason := flags.String("reason", "", "why the status is changed")
+
+	return func(ctx context.Context, c *userCommand, flags *flag.FlagSet) error {
+		id, err := userIDArgument(flags)
+		if err != nil {
+			return err
+		}
+
+		user, err := c.svc.ChangeUserStatus(ctx, id, entities.ChangeUserStatusParams{
+			Status: status,
+			Reason: *reason,
+			Actor:  "operator:" + c.operator,
+		})
+		if err = c.audit(ctx, "user.status", &id, map[string]any{"status": status, "reason": *reason}, err); err != nil {
+			return err
+		}
+
+		return c.printUsers(user)
+	}
+}
+
+// exportUsersCommand writes all the users as JSON lines, page by page.
+func exportUsersCommand(flags *flag.FlagSet) func(ctx context.Context, c *userCommand, flags *flag.FlagSet) error {
+	deleted := flags.Bool("deleted", false, "include deleted users")
+
+	return func(ctx context.Context, c *userCommand, _ *flag.FlagSet) error {
+		encoder := json.NewEncoder(c.out)
+		params := entities.ListUsersParams{Limit: exportPageSize, IncludeDeleted: *deleted}
+		exported := 0
+
+		err := func() error {
+			for {
+				users, err := c.svc.ListUsers(ctx, params)
+				if err != nil {
+					return err
+				}
+
+				for _, u := range users {
+					if err := encoder.Encode(userToJSON(u)); err != nil {
+						return errors.Wrap(err, "failed to write user")
+					}
+				}
+
+				exported += len(users)
+
+				if uint64(len(users)) < params.Limit {
+					return nil
+				}
+
+				params.AfterID = users[len(users)-1].ID
+			}
+		}()
+
+		return c.audit(ctx, "user.export", nil, map[string]any{"deleted": *deleted, "exported": exported}, err)
+	}
+}
+
+func (c *userCommand) printUsers(users ...entities.User) error {
+	if c.output == outputJSON {
+		encoder := json.NewEncoder(c.out)
+		encoder.SetIndent("", "  ")
+
+		for _, u := range users {
+			if err := encoder.Encode(userToJSON(u)); err != nil {
+				return errors.Wrap(err, "failed to write user")
+			}
+		}
+
+		return nil
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tFIRST NAME\tLAST NAME\tPHONE NUMBER\tADDRESS\tSTATUS\tCREATED AT")
+
+	for _, u := range users {
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", u.ID, u.FirstName, u.LastName, u.PhoneNumber,
+			u.Address, u.Status, u.CreatedAt.Format(time.RFC3339))
+	}
+
+	return errors.Wrap(w.Flush(), "failed to write users")
+}
+
+func userToJSON(u entities.User) userJSON {
+	user := userJSON{
+		ID:              u.ID,
+		FirstName:       u.FirstName,
+		LastName:        u.LastName,
+		PhoneNumber:     u.PhoneNumber,
+		PhoneVerifiedAt: u.PhoneVerifiedAt,
+		Address:         u.Address,
+		Email:           u.Email,
+		EmailVerifiedAt: u.EmailVerifiedAt,
+		Status:          string(u.Status),
+		Deleted:         u.Deleted,
+		CreatedAt:       u.CreatedAt,
+		UpdatedAt:       u.UpdatedAt,
+		DeletedAt:       u.DeletedAt,
+		Attributes:      u.Attributes,
+	}
+
+	for _, id := range u.ExternalIDs {
+		user.ExternalIDs = append(user.ExternalIDs, externalIDJSON{Source: id.Source, ID: id.ID})
+	}
+
+	return user
+}
+
+// attributeFilter collects key=value flags, see entities.ParseAttributeCondition.
+type attributeFilter map[string]any
+
+func (f attributeFilter) String() string {
+	pairs := make([]string, 0, len(f))
+	for k, v := range f {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, v))
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func (f attributeFilter) Set(value string) error {
+	key, v, err := entities.ParseAttributeCondition(value)
+	if err != nil {
+		return err
+	}
+
+	f[key] = v
+
+	return nil
+}
+
+func userIDArgument(flags *flag.FlagSet) (int64, error) {
+	if flags.NArg() != 1 {
+		return 0, errors.New("exactly one user ID is expected")
+	}
+
+	id, err := strconv.ParseInt(flags.Arg(0), 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid user ID %q", flags.Arg(0))
+	}
+
+	return id, nil
+}
@@ -1,67 +1,632 @@
 package config
 
 import (
+	"bytes"
+	"io"
+	"net/netip"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/adapters/cache"
+	"github.com/torwig/user-service/adapters/notify"
 	"github.com/torwig/user-service/adapters/repository"
+	"github.com/torwig/user-service/adapters/schema"
+	"github.com/torwig/user-service/entities"
 	"github.com/torwig/user-service/log"
 	"github.com/torwig/user-service/ports/http"
 	"github.com/torwig/user-service/ports/http/jwt"
+	"github.com/torwig/user-service/service"
+	"github.com/torwig/user-service/tracing"
+	"go.uber.org/zap/zapcore"
 )
 
 const (
-	defaultLogLevel       = "info"
-	defaultBindAddress    = ":8080"
-	envKeyLogLevel        = "USERS_LOG_LEVEL"
-	envKeyRepositoryURI   = "USERS_REPOSITORY_URI"
-	envKeyJWTSecret       = "USERS_JWT_SECRET" // #nosec G101
-	envKeyJWTIssuer       = "USERS_JWT_ISSUER"
-	envKeyHTTPBindAddress = "USERS_HTTP_BIND_ADDRESS"
+	clientCertUsersSeparator    = ";"
+	clientCertUserPartSeparator = "|"
+	clientCertUserParts         = 3
+	scopesSeparator             = ","
+	rateLimitRulesSeparator     = ";"
+
+	RateLimitStoreMemory   = "memory"
+	RateLimitStorePostgres = "postgres"
+
+	RepositoryPostgres = "postgres"
+	RepositoryMemory   = "memory"
+	// RepositorySQLite isn't given explicitly, it's chosen by a repository.uri starting with "sqlite:".
+	RepositorySQLite = "sqlite"
+)
+
+var (
+	ErrInvalidClientCertUser = errors.New("client certificate user must be in <subject>|<user id>|<scopes> format")
+	ErrClientCAWithoutTLS    = errors.New("client certificates can't be verified without TLS certificate and key")
+	ErrInvalidRateLimit      = errors.New("rate limit must be in <requests>/<period>[:<burst>] format")
+	ErrInvalidRateLimitRule  = errors.New("rate limit rule must be in [<route>][@<tier>]=<limit> format")
+	ErrUnknownRateLimitTier  = errors.New("unknown rate limit tier")
+	ErrUnknownRateLimitStore = errors.New("unknown rate limit store")
+	ErrUnknownRepository     = errors.New("unknown repository")
+	ErrInvalidTrustedProxy   = errors.New("trusted proxy must be an IP address or a CIDR range")
 )
 
 type Config struct {
 	Log        log.Config
 	Repository repository.Config
-	JWT        jwt.Config
-	HTTP       http.Config
+	Cache      cache.Config
+	Schema     schema.Config
+	// EmailVerification signs the tokens users verify their emails with, which are sent by the notifier.
+	EmailVerification service.EmailVerificationConfig
+	// PhoneVerification keys the hashes of the codes users verify their phone numbers with.
+	PhoneVerification service.PhoneVerificationConfig
+	Notify            notify.Config
+	JWT               jwt.Config
+	HTTP              http.Config
+	// Admin is the listener for operational endpoints such as metrics.
+	Admin   http.Config
+	Tracing tracing.Config
+
+	values values
+}
+
+// Load reads the configuration from, in the order of precedence, the command-line flags in args,
+// the environment variables, the config file (given by the -config flag or USERS_CONFIG_FILE)
+// and the defaults. It returns the arguments left after the flags, i.e. the command to run.
+func Load(args []string) (*Config, []string, error) {
+	flagValues := make(map[string]string)
+	flags := newFlagSet(flagValues)
+	flags.SetOutput(io.Discard)
+
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to parse flags")
+	}
+
+	v := make(values, len(settings))
+	for _, s := range settings {
+		v[s.key] = value{raw: s.def, source: sourceDefault}
+	}
+
+	configFile := flags.Lookup(flagConfigFile).Value.String()
+	if configFile == "" {
+		configFile = os.Getenv(envKeyConfigFile)
+	}
+
+	if configFile != "" {
+		fileValues, err := readConfigFile(configFile)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for key, raw := range fileValues {
+			v[key] = value{raw: raw, source: sourceFile}
+		}
+	}
+
+	for _, s := range settings {
+		if raw, ok := os.LookupEnv(s.env); ok && raw != "" {
+			v[s.key] = value{raw: raw, source: sourceEnv}
+		}
+	}
+
+	for key, raw := range flagValues {
+		v[key] = value{raw: raw, source: sourceFlag}
+	}
+
+	cfg, err := create(v)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, flags.Args(), nil
+}
+
+// Usage writes the description of the flags (and environment variables) to w.
+func Usage(w io.Writer) {
+	flags := newFlagSet(make(map[string]string))
+	flags.SetOutput(w)
+	flags.PrintDefaults()
 }
 
-func CreateFromEnv() *Config {
+func create(v values) (*Config, error) {
+	repoCfg, err := createRepositoryConfig(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create repository config")
+	}
+
+	cacheCfg, err := createCacheConfig(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create cache config")
+	}
+
+	httpCfg, err := createHTTPConfig(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create HTTP config")
+	}
+
+	tracingCfg, err := createTracingConfig(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create tracing config")
+	}
+
+	emailVerificationTTL, err := v.duration("email.verification_ttl")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create email verification config")
+	}
+
+	phoneVerificationCfg, err := createPhoneVerificationConfig(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create phone verification config")
+	}
+
+	notifyCfg := notify.Config{Notifier: v.get("notify.notifier"), File: v.get("notify.file")}
+
+	switch notifyCfg.Notifier {
+	case notify.NotifierLog, notify.NotifierFile:
+	default:
+		return nil, v.invalid("notify.notifier", notify.ErrUnknownNotifier)
+	}
+
 	cfg := &Config{
-		Log:        createLogConfig(),
-		Repository: createRepositoryConfig(),
-		JWT:        createJWTConfig(),
-		HTTP:       createHTTPConfig(),
+		Log:        log.Config{Level: v.get("log.level")},
+		Repository: repoCfg,
+		Cache:      cacheCfg,
+		Schema:     schema.Config{AttributesFile: v.get("schema.attributes_file")},
+		EmailVerification: service.EmailVerificationConfig{
+			Secret: []byte(v.get("email.verification_secret")),
+			TTL:    emailVerificationTTL,
+		},
+		PhoneVerification: phoneVerificationCfg,
+		Notify:            notifyCfg,
+		JWT:               jwt.Config{SecretKey: []byte(v.get("jwt.secret")), Issuer: v.get("jwt.issuer")},
+		HTTP:              httpCfg,
+		Admin:             http.Config{BindAddress: v.get("admin.bind_address")},
+		Tracing:           tracingCfg,
+		values:            v,
+	}
+
+	return cfg, nil
+}
+
+// Validate checks the settings every command opening the store needs and reports all the problems at once.
+func (c *Config) Validate() error {
+	problems := c.tokenProblems()
+
+	if c.Repository.Type == RepositoryPostgres && c.Repository.DSN == "" {
+		problems = append(problems, c.values.missing("repository.uri").Error())
+	}
+
+	if c.Repository.Type != RepositoryPostgres && c.Repository.ReplicaDSN != "" {
+		problems = append(problems, c.values.invalid("repository.replica_uri",
+			errors.New("requires the postgres repository")).Error())
+	}
+
+	if c.Repository.Type != RepositoryPostgres && c.Cache.ListenNotify {
+		problems = append(problems, c.values.invalid("cache.listen_notify",
+			errors.New("requires the postgres repository")).Error())
+	}
+
+	if c.Repository.Type != RepositoryPostgres && c.HTTP.RateLimit.Store == RateLimitStorePostgres {
+		problems = append(problems, c.values.invalid("http.rate_limit.store",
+			errors.New("requires the postgres repository")).Error())
+	}
+
+	if c.EmailVerification.Enabled() && bytes.Equal(c.EmailVerification.Secret, c.JWT.SecretKey) {
+		problems = append(problems, c.values.invalid("email.verification_secret",
+			errors.New("must differ from jwt.secret")).Error())
+	}
+
+	if c.Notify.Notifier == notify.NotifierFile && c.Notify.File == "" {
+		problems = append(problems, c.values.missing("notify.file").Error())
+	}
+
+	if c.Repository.MaxConns <= 0 {
+		problems = append(problems, c.values.invalid("repository.max_conns", errors.New("must be positive")).Error())
 	}
 
-	return cfg
+	if c.Repository.MinConns < 0 || c.Repository.MinConns > c.Repository.MaxConns {
+		problems = append(problems, c.values.invalid("repository.min_conns",
+			errors.New("must be between 0 and repository.max_conns")).Error())
+	}
+
+	if c.HTTP.BindAddress == c.Admin.BindAddress {
+		problems = append(problems, c.values.invalid("admin.bind_address",
+			errors.New("must differ from http.bind_address")).Error())
+	}
+
+	return problemsError(problems)
+}
+
+// ValidateToken checks only the settings the token command needs, as it doesn't open the store.
+func (c *Config) ValidateToken() error {
+	return problemsError(c.tokenProblems())
 }
 
-func createLogConfig() log.Config {
-	level := os.Getenv(envKeyLogLevel)
-	if level == "" {
-		level = defaultLogLevel
+func (c *Config) tokenProblems() []string {
+	var problems []string
+
+	if _, err := zapcore.ParseLevel(c.Log.Level); err != nil {
+		problems = append(problems, c.values.invalid("log.level", err).Error())
+	}
+
+	if len(c.JWT.SecretKey) == 0 {
+		problems = append(problems, c.values.missing("jwt.secret").Error())
+	}
+
+	return problems
+}
+
+func problemsError(problems []string) error {
+	if len(problems) == 0 {
+		return nil
 	}
 
-	return log.Config{Level: level}
+	return errors.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
 }
 
-func createRepositoryConfig() repository.Config {
-	return repository.Config{DSN: os.Getenv(envKeyRepositoryURI)}
+func createPhoneVerificationConfig(v values) (service.PhoneVerificationConfig, error) {
+	var err error
+
+	cfg := service.PhoneVerificationConfig{Secret: []byte(v.get("phone.verification_secret"))}
+
+	if cfg.TTL, err = v.duration("phone.verification_code_ttl"); err != nil {
+		return cfg, err
+	}
+
+	if cfg.TTL <= 0 {
+		return cfg, v.invalid("phone.verification_code_ttl", errors.New("must be positive"))
+	}
+
+	if cfg.MaxAttempts, err = v.int("phone.verification_max_attempts"); err != nil {
+		return cfg, err
+	}
+
+	if cfg.MaxAttempts <= 0 {
+		return cfg, v.invalid("phone.verification_max_attempts", errors.New("must be positive"))
+	}
+
+	if cfg.ResendInterval, err = v.duration("phone.verification_resend_interval"); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
 }
 
-func createJWTConfig() jwt.Config {
-	return jwt.Config{
-		SecretKey: []byte(os.Getenv(envKeyJWTSecret)),
-		Issuer:    os.Getenv(envKeyJWTIssuer),
+func createRepositoryConfig(v values) (repository.Config, error) {
+	var err error
+
+	cfg := repository.Config{
+		Type:       v.get("repository.type"),
+		DSN:        v.get("repository.uri"),
+		ReplicaDSN: v.get("repository.replica_uri"),
+	}
+
+	switch cfg.Type {
+	case RepositoryPostgres, RepositoryMemory:
+	default:
+		return cfg, v.invalid("repository.type", ErrUnknownRepository)
 	}
+
+	if cfg.Type == RepositoryPostgres && repository.IsSQLiteDSN(cfg.DSN) {
+		cfg.Type = RepositorySQLite
+	}
+
+	if cfg.AutoMigrate, err = v.bool("repository.auto_migrate"); err != nil {
+		return cfg, err
+	}
+
+	if cfg.MaxConns, err = v.int32("repository.max_conns"); err != nil {
+		return cfg, err
+	}
+
+	if cfg.MinConns, err = v.int32("repository.min_conns"); err != nil {
+		return cfg, err
+	}
+
+	for key, d := range map[string]*time.Duration{
+		"repository.max_conn_lifetime":        &cfg.MaxConnLifetime,
+		"repository.max_conn_lifetime_jitter": &cfg.MaxConnLifetimeJitter,
+		"repository.max_conn_idle_time":       &cfg.MaxConnIdleTime,
+		"repository.health_check_period":      &cfg.HealthCheckPeriod,
+		"repository.connect_timeout":          &cfg.ConnectTimeout,
+		"repository.statement_timeout":        &cfg.StatementTimeout,
+		"repository.slow_statement_timeout":   &cfg.SlowStatementTimeout,
+		"repository.read_your_writes_window":  &cfg.ReadYourWritesWindow,
+	} {
+		if *d, err = v.duration(key); err != nil {
+			return cfg, err
+		}
+	}
+
+	return cfg, nil
 }
 
-func createHTTPConfig() http.Config {
-	bindAddress := os.Getenv(envKeyHTTPBindAddress)
-	if bindAddress == "" {
-		bindAddress = defaultBindAddress
+func createHTTPConfig(v values) (http.Config, error) {
+	var err error
+
+	cfg := http.Config{BindAddress: v.get("http.bind_address")}
+
+	if cfg.TLS, err = createTLSConfig(v); err != nil {
+		return cfg, err
+	}
+
+	if cfg.RateLimit, err = createRateLimitConfig(v); err != nil {
+		return cfg, err
+	}
+
+	if cfg.TrustedProxies, err = parseTrustedProxies(v.get("http.trusted_proxies")); err != nil {
+		return cfg, v.invalid("http.trusted_proxies", err)
+	}
+
+	if cfg.AccessLogSampleRatio, err = v.ratio("http.access_log_sample_ratio"); err != nil {
+		return cfg, err
+	}
+
+	for key, d := range map[string]*time.Duration{
+		"http.idempotency_key_ttl":  &cfg.IdempotencyKeyTTL,
+		"http.shutdown_drain_delay": &cfg.ShutdownDrainDelay,
+		"http.read_timeout":         &cfg.ReadTimeout,
+		"http.read_header_timeout":  &cfg.ReadHeaderTimeout,
+		"http.write_timeout":        &cfg.WriteTimeout,
+		"http.idle_timeout":         &cfg.IdleTimeout,
+		"http.shutdown_timeout":     &cfg.ShutdownTimeout,
+	} {
+		if *d, err = v.duration(key); err != nil {
+			return cfg, err
+		}
+	}
+
+	if cfg.IdempotencyKeyTTL == 0 {
+		return cfg, v.invalid("http.idempotency_key_ttl", errors.New("must be positive"))
+	}
+
+	return cfg, nil
+}
+
+func createCacheConfig(v values) (cache.Config, error) {
+	var (
+		cfg cache.Config
+		err error
+	)
+
+	if cfg.Size, err = v.int("cache.size"); err != nil {
+		return cfg, err
+	}
+
+	if cfg.TTL, err = v.duration("cache.ttl"); err != nil {
+		return cfg, err
+	}
+
+	if cfg.NegativeTTL, err = v.duration("cache.negative_ttl"); err != nil {
+		return cfg, err
+	}
+
+	if cfg.ListenNotify, err = v.bool("cache.listen_notify"); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+func createTracingConfig(v values) (tracing.Config, error) {
+	var err error
+
+	cfg := tracing.Config{
+		Exporter:     v.get("tracing.exporter"),
+		OTLPEndpoint: v.get("tracing.otlp_endpoint"),
+	}
+
+	switch cfg.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	default:
+		return cfg, v.invalid("tracing.exporter", tracing.ErrUnknownExporter)
+	}
+
+	if cfg.OTLPInsecure, err = v.bool("tracing.otlp_insecure"); err != nil {
+		return cfg, err
+	}
+
+	if cfg.SampleRatio, err = v.ratio("tracing.sample_ratio"); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+func createTLSConfig(v values) (http.TLSConfig, error) {
+	reloadInterval, err := v.duration("http.tls.reload_interval")
+	if err != nil {
+		return http.TLSConfig{}, err
+	}
+
+	if reloadInterval == 0 {
+		return http.TLSConfig{}, v.invalid("http.tls.reload_interval", errors.New("must be positive"))
+	}
+
+	users, err := parseClientCertUsers(v.get("http.tls.client_cert_users"))
+	if err != nil {
+		return http.TLSConfig{}, v.invalid("http.tls.client_cert_users", err)
+	}
+
+	tlsCfg := http.TLSConfig{
+		CertFile:        v.get("http.tls.cert_file"),
+		KeyFile:         v.get("http.tls.key_file"),
+		ClientCAFile:    v.get("http.tls.client_ca_file"),
+		ClientCertUsers: users,
+		ReloadInterval:  reloadInterval,
+	}
+
+	if tlsCfg.ClientAuthEnabled() && !tlsCfg.Enabled() {
+		return http.TLSConfig{}, ErrClientCAWithoutTLS
+	}
+
+	return tlsCfg, nil
+}
+
+// parseClientCertUsers parses the list of "<subject>|<user id>|<scope>,<scope>" entries separated by ";",
+// e.g. "CN=billing|1001|users:view;CN=crm,O=Acme|1002|users:view,users:update".
+func parseClientCertUsers(value string) ([]http.ClientCertUser, error) {
+	var users []http.ClientCertUser
+
+	for _, entry := range strings.Split(value, clientCertUsersSeparator) {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, clientCertUserPartSeparator)
+		if len(parts) != clientCertUserParts || parts[0] == "" {
+			return nil, ErrInvalidClientCertUser
+		}
+
+		userID, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, ErrInvalidClientCertUser
+		}
+
+		var scopes []string
+		if parts[2] != "" {
+			scopes = strings.Split(parts[2], scopesSeparator)
+		}
+
+		users = append(users, http.ClientCertUser{Subject: parts[0], UserID: userID, Scopes: scopes})
+	}
+
+	return users, nil
+}
+
+// parseTrustedProxies parses the list of IP addresses and CIDR ranges separated by "," or ";",
+// e.g. "10.0.0.0/8,192.168.1.10".
+func parseTrustedProxies(value string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+
+	for _, entry := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' }) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, ErrInvalidTrustedProxy
+			}
+
+			proxies = append(proxies, prefix.Masked())
+
+			continue
+		}
+
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, ErrInvalidTrustedProxy
+		}
+
+		proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+
+	return proxies, nil
+}
+
+func createRateLimitConfig(v values) (http.RateLimitConfig, error) {
+	var cfg http.RateLimitConfig
+
+	cfg.Store = v.get("http.rate_limit.store")
+	switch cfg.Store {
+	case RateLimitStoreMemory, RateLimitStorePostgres:
+	default:
+		return cfg, v.invalid("http.rate_limit.store", ErrUnknownRateLimitStore)
+	}
+
+	if raw := v.get("http.rate_limit.default"); raw != "" {
+		limit, err := parseRateLimit(raw)
+		if err != nil {
+			return cfg, v.invalid("http.rate_limit.default", err)
+		}
+
+		cfg.Default = limit
+	}
+
+	if raw := v.get("http.rate_limit.failed_auth"); raw != "" {
+		limit, err := parseRateLimit(raw)
+		if err != nil {
+			return cfg, v.invalid("http.rate_limit.failed_auth", err)
+		}
+
+		cfg.FailedAuth = limit
+	}
+
+	for _, entry := range strings.Split(v.get("http.rate_limit.rules"), rateLimitRulesSeparator) {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		rule, err := parseRateLimitRule(entry)
+		if err != nil {
+			return cfg, v.invalid("http.rate_limit.rules", err)
+		}
+
+		cfg.Rules = append(cfg.Rules, rule)
+	}
+
+	return cfg, nil
+}
+
+// parseRateLimitRule parses a "[<route>][@<tier>]=<limit>" rule,
+// e.g. "POST /api/v1/users@user=10/1m" or "@privileged=1000/1m:100".
+func parseRateLimitRule(value string) (http.RateLimitRule, error) {
+	selector, limitValue, ok := strings.Cut(value, "=")
+	if !ok {
+		return http.RateLimitRule{}, ErrInvalidRateLimitRule
+	}
+
+	route, tier, _ := strings.Cut(selector, "@")
+	route = strings.TrimSpace(route)
+	tier = strings.TrimSpace(tier)
+
+	if route == "" && tier == "" {
+		return http.RateLimitRule{}, ErrInvalidRateLimitRule
+	}
+
+	switch tier {
+	case "", http.RateLimitTierAnonymous, http.RateLimitTierUser, http.RateLimitTierPrivileged:
+	default:
+		return http.RateLimitRule{}, errors.Wrap(ErrUnknownRateLimitTier, tier)
+	}
+
+	limit, err := parseRateLimit(limitValue)
+	if err != nil {
+		return http.RateLimitRule{}, err
+	}
+
+	return http.RateLimitRule{Route: route, Tier: tier, Limit: limit}, nil
+}
+
+// parseRateLimit parses a "<requests>/<period>[:<burst>]" limit, e.g. "100/1m" or "100/1m:20".
+func parseRateLimit(value string) (entities.RateLimit, error) {
+	value, burstValue, hasBurst := strings.Cut(strings.TrimSpace(value), ":")
+
+	requestsValue, periodValue, ok := strings.Cut(value, "/")
+	if !ok {
+		return entities.RateLimit{}, ErrInvalidRateLimit
+	}
+
+	requests, err := strconv.Atoi(requestsValue)
+	if err != nil || requests <= 0 {
+		return entities.RateLimit{}, ErrInvalidRateLimit
+	}
+
+	period, err := time.ParseDuration(periodValue)
+	if err != nil || period <= 0 {
+		return entities.RateLimit{}, ErrInvalidRateLimit
+	}
+
+	limit := entities.RateLimit{Requests: requests, Period: period}
+
+	if hasBurst {
+		limit.Burst, err = strconv.Atoi(burstValue)
+		if err != nil || limit.Burst <= 0 {
+			return entities.RateLimit{}, ErrInvalidRateLimit
+		}
 	}
 
-	return http.Config{BindAddress: bindAddress}
+	return limit, nil
 }
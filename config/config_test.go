@@ -0,0 +1,123 @@
+package config_test
+
+import (
+	"bytes"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torwig/user-service/config"
+)
+
+func TestLoad(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "users.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`
+repository:
+  uri: postgres://users:secret@db/users
+  max_conns: 20
+http:
+  bind_address: ":8000"
+  read_timeout: 10s
+  trusted_proxies:
+    - 10.0.0.0/8
+    - 192.168.1.10
+  rate_limit:
+    rules:
+      - "POST /api/v1/users@user=10/1m"
+      - "@privileged=1000/1m"
+jwt:
+  secret: from-file
+`), 0o600))
+
+	t.Setenv("USERS_HTTP_BIND_ADDRESS", ":8001")
+	t.Setenv("USERS_JWT_SECRET", "from-env")
+
+	cfg, args, err := config.Load([]string{"-config", configFile, "-http-bind-address", ":8002", "serve"})
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+
+	assert.Equal(t, []string{"serve"}, args)
+	assert.Equal(t, ":8002", cfg.HTTP.BindAddress)
+	assert.Equal(t, []byte("from-env"), cfg.JWT.SecretKey)
+	assert.Equal(t, int32(20), cfg.Repository.MaxConns)
+	assert.Equal(t, int32(2), cfg.Repository.MinConns)
+	assert.Equal(t, 10*time.Second, cfg.HTTP.ReadTimeout)
+	assert.Len(t, cfg.HTTP.RateLimit.Rules, 2)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("192.168.1.10/32")},
+		cfg.HTTP.TrustedProxies)
+	assert.Equal(t, 20, cfg.HTTP.RateLimit.FailedAuth.Requests)
+
+	var out bytes.Buffer
+	require.NoError(t, cfg.Print(&out))
+	assert.Contains(t, out.String(), `uri: "postgres://users:REDACTED@db/users" # file`)
+	assert.Contains(t, out.String(), `secret: "REDACTED" # env`)
+	assert.NotContains(t, out.String(), "from-env")
+}
+
+func TestConfig_Validate(t *testing.T) {
+	t.Setenv("USERS_LOG_LEVEL", "loud")
+
+	cfg, _, err := config.Load(nil)
+	require.NoError(t, err)
+
+	err = cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid log.level "loud" (from environment variable USERS_LOG_LEVEL)`)
+	assert.Contains(t, err.Error(), "repository.uri is required")
+	assert.Contains(t, err.Error(), "jwt.secret is required")
+
+	// the token command doesn't open the store
+	err = cfg.ValidateToken()
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "repository.uri")
+	assert.Contains(t, err.Error(), "jwt.secret is required")
+
+	t.Setenv("USERS_HTTP_READ_TIMEOUT", "soon")
+
+	_, _, err = config.Load(nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid http.read_timeout "soon"`)
+
+	t.Setenv("USERS_HTTP_READ_TIMEOUT", "10s")
+	t.Setenv("USERS_HTTP_TLS_RELOAD_INTERVAL", "0s")
+
+	_, _, err = config.Load(nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid http.tls.reload_interval "0s"`)
+
+	t.Setenv("USERS_HTTP_TLS_RELOAD_INTERVAL", "1m")
+	t.Setenv("USERS_HTTP_TRUSTED_PROXIES", "10.0.0.0/33")
+
+	_, _, err = config.Load(nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid http.trusted_proxies "10.0.0.0/33"`)
+}
+
+func TestLoad_Repository(t *testing.T) {
+	t.Setenv("USERS_JWT_SECRET", "secret")
+
+	cfg, _, err := config.Load([]string{"-repository-uri", "sqlite:///var/lib/users/users.db"})
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, config.RepositorySQLite, cfg.Repository.Type)
+
+	cfg, _, err = config.Load([]string{"--repository=memory"})
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, config.RepositoryMemory, cfg.Repository.Type)
+
+	t.Setenv("USERS_REPOSITORY_MAX_CONNS", "1")
+	t.Setenv("USERS_REPOSITORY_MIN_CONNS", "0")
+
+	cfg, _, err = config.Load([]string{"-repository-uri", "postgres://db/users"})
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, int32(0), cfg.Repository.MinConns)
+
+	_, _, err = config.Load([]string{"-repository", "mysql"})
+	require.ErrorIs(t, err, config.ErrUnknownRepository)
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "REDACTED"
+
+// dsnPassword matches the password of a connection string in the keyword/value format.
+var dsnPassword = regexp.MustCompile(`(password\s*=\s*)('[^']*'|\S+)`)
+
+// Print writes the effective configuration as a YAML config file, noting where every value came from.
+// Secrets are redacted.
+func (c *Config) Print(w io.Writer) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+
+	for _, s := range settings {
+		val := c.values[s.key]
+
+		raw := val.raw
+		if s.secret && raw != "" {
+			raw = redact(s.key, raw)
+		}
+
+		parent := root
+		parts := strings.Split(s.key, ".")
+
+		for _, part := range parts[:len(parts)-1] {
+			parent = mappingChild(parent, part)
+		}
+
+		parent.Content = append(parent.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: parts[len(parts)-1]},
+			&yaml.Node{Kind: yaml.ScalarNode, Value: raw, Style: yaml.DoubleQuotedStyle, LineComment: val.source},
+		)
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(root); err != nil {
+		return errors.Wrap(err, "failed to write configuration")
+	}
+
+	return errors.Wrap(encoder.Close(), "failed to write configuration")
+}
+
+func mappingChild(parent *yaml.Node, key string) *yaml.Node {
+	for i := 0; i < len(parent.Content); i += 2 {
+		if parent.Content[i].Value == key {
+			return parent.Content[i+1]
+		}
+	}
+
+	child := &yaml.Node{Kind: yaml.MappingNode}
+	parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, child)
+
+	return child
+}
+
+// redact hides the whole secret except for the connection string, where only the password is hidden
+// so that the host and the database can still be checked.
+func redact(key, raw string) string {
+	if key != "repository.uri" && key != "repository.replica_uri" {
+		return redacted
+	}
+
+	if u, err := url.Parse(raw); err == nil && u.Scheme != "" {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		}
+
+		return u.String()
+	}
+
+	return dsnPassword.ReplaceAllString(raw, "${1}"+redacted)
+}
@@ -0,0 +1,373 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/adapters/notify"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	sourceDefault = "default"
+	sourceFile    = "file"
+	sourceEnv     = "env"
+	sourceFlag    = "flag"
+
+	flagConfigFile   = "config"
+	envKeyConfigFile = "USERS_CONFIG_FILE"
+
+	listSeparator = ";"
+)
+
+var (
+	ErrUnknownSetting      = errors.New("unknown setting")
+	ErrUnknownConfigFormat = errors.New("config file must be either YAML (.yaml, .yml) or TOML (.toml)")
+)
+
+// setting is a single configuration value. It can be given in the config file under its key,
+// in its environment variable or in the command-line flag named after the key,
+// e.g. http.bind_address, USERS_HTTP_BIND_ADDRESS and -http-bind-address.
+type setting struct {
+	key string
+	env string
+	// flag overrides the name of the flag derived from the key.
+	flag    string
+	def     string
+	usage   string
+	secret  bool
+	boolean bool
+}
+
+func (s setting) flagName() string {
+	if s.flag != "" {
+		return s.flag
+	}
+
+	return strings.NewReplacer(".", "-", "_", "-").Replace(s.key)
+}
+
+var settings = []setting{
+	{key: "log.level", env: "USERS_LOG_LEVEL", def: "info",
+		usage: "log level: debug, info, warn, error, dpanic, panic or fatal"},
+
+	{key: "repository.type", env: "USERS_REPOSITORY_TYPE", flag: "repository", def: RepositoryPostgres,
+		usage: "where users are kept: postgres (or SQLite, see repository.uri) or memory (lost on exit, for demos)"},
+	{key: "repository.uri", env: "USERS_REPOSITORY_URI", secret: true,
+		usage: "Postgres connection string, or sqlite://<path> to keep users in a SQLite database file"},
+	{key: "repository.auto_migrate", env: "USERS_REPOSITORY_AUTO_MIGRATE", def: "false", boolean: true,
+		usage: "apply pending migrations on start"},
+	{key: "repository.max_conns", env: "USERS_REPOSITORY_MAX_CONNS", def: "10",
+		usage: "maximum number of connections in the pool"},
+	{key: "repository.min_conns", env: "USERS_REPOSITORY_MIN_CONNS", def: "2",
+		usage: "number of connections the pool keeps open"},
+	{key: "repository.max_conn_lifetime", env: "USERS_REPOSITORY_MAX_CONN_LIFETIME", def: "1h",
+		usage: "how long a connection is used before it's replaced"},
+	{key: "repository.max_conn_lifetime_jitter", env: "USERS_REPOSITORY_MAX_CONN_LIFETIME_JITTER", def: "1m",
+		usage: "random addition to the lifetime, so that connections aren't replaced all at once"},
+	{key: "repository.max_conn_idle_time", env: "USERS_REPOSITORY_MAX_CONN_IDLE_TIME", def: "30m",
+		usage: "how long an idle connection is kept open"},
+	{key: "repository.health_check_period", env: "USERS_REPOSITORY_HEALTH_CHECK_PERIOD", def: "1m",
+		usage: "how often idle connections are checked"},
+	{key: "repository.connect_timeout", env: "USERS_REPOSITORY_CONNECT_TIMEOUT", def: "5s",
+		usage: "how long to wait for the database on start"},
+	{key: "repository.statement_timeout", env: "USERS_REPOSITORY_STATEMENT_TIMEOUT", def: "5s",
+		usage: "longest time a statement may run before Postgres aborts it, 0 disables it"},
+	{key: "repository.slow_statement_timeout", env: "USERS_REPOSITORY_SLOW_STATEMENT_TIMEOUT", def: "1m",
+		usage: "statement timeout of listing users and purging expired records, 0 disables it"},
+	{key: "repository.replica_uri", env: "USERS_REPOSITORY_REPLICA_URI", secret: true,
+		usage: "connection string of a read replica users are read from"},
+	{key: "repository.read_your_writes_window", env: "USERS_REPOSITORY_READ_YOUR_WRITES_WINDOW", def: "5s",
+		usage: "how long a caller reads from the primary after their own change"},
+
+	{key: "cache.size", env: "USERS_CACHE_SIZE", def: "0",
+		usage: "maximum number of users cached in memory, 0 disables the cache"},
+	{key: "cache.ttl", env: "USERS_CACHE_TTL", def: "1m", usage: "how long a user is served from the cache"},
+	{key: "cache.negative_ttl", env: "USERS_CACHE_NEGATIVE_TTL", def: "5s",
+		usage: "how long a missing user is remembered"},
+	{key: "cache.listen_notify", env: "USERS_CACHE_LISTEN_NOTIFY", def: "false", boolean: true,
+		usage: "invalidate users changed by other replicas through Postgres LISTEN/NOTIFY"},
+
+	{key: "schema.attributes_file", env: "USERS_ATTRIBUTES_SCHEMA_FILE",
+		usage: "JSON Schema the custom attributes of users must match, any are accepted if empty"},
+
+	{key: "email.verification_secret", env: "USERS_EMAIL_VERIFICATION_SECRET", secret: true,
+		usage: "secret key email verification tokens are signed with, emails can't be verified if empty"},
+	{key: "email.verification_ttl", env: "USERS_EMAIL_VERIFICATION_TTL", def: "24h",
+		usage: "how long an email verification token can be used"},
+
+	{key: "phone.verification_secret", env: "USERS_PHONE_VERIFICATION_SECRET", secret: true,
+		usage: "secret key the hashes of phone verification codes are keyed with, numbers can't be verified if empty"},
+	{key: "phone.verification_code_ttl", env: "USERS_PHONE_VERIFICATION_CODE_TTL", def: "10m",
+		usage: "how long a phone verification code can be entered"},
+	{key: "phone.verification_max_attempts", env: "USERS_PHONE_VERIFICATION_MAX_ATTEMPTS", def: "5",
+		usage: "how many times a phone verification code can be entered"},
+	{key: "phone.verification_resend_interval", env: "USERS_PHONE_VERIFICATION_RESEND_INTERVAL", def: "1m",
+		usage: "how long a user waits before another code is sent to the same phone number"},
+
+	{key: "notify.notifier", env: "USERS_NOTIFIER", def: notify.NotifierLog,
+		usage: "how verification emails and text messages are delivered: log or file"},
+	{key: "notify.file", env: "USERS_NOTIFY_FILE", usage: "file the file notifier appends messages to"},
+
+	{key: "jwt.secret", env: "USERS_JWT_SECRET", secret: true, usage: "secret key access tokens are signed with"},
+	{key: "jwt.issuer", env: "USERS_JWT_ISSUER", usage: "expected issuer of access tokens, any if empty"},
+
+	{key: "http.bind_address", env: "USERS_HTTP_BIND_ADDRESS", def: ":8080", usage: "address of the API listener"},
+	{key: "http.read_timeout", env: "USERS_HTTP_READ_TIMEOUT", def: "5s", usage: "time to read a whole request"},
+	{key: "http.read_header_timeout", env: "USERS_HTTP_READ_HEADER_TIMEOUT", def: "2s",
+		usage: "time to read request headers"},
+	{key: "http.write_timeout", env: "USERS_HTTP_WRITE_TIMEOUT", def: "5s", usage: "time to write a response"},
+	{key: "http.idle_timeout", env: "USERS_HTTP_IDLE_TIMEOUT", def: "30s",
+		usage: "how long an idle keep-alive connection is kept open"},
+	{key: "http.shutdown_timeout", env: "USERS_HTTP_SHUTDOWN_TIMEOUT", def: "5s",
+		usage: "time to finish in-flight requests on shutdown"},
+	{key: "http.shutdown_drain_delay", env: "USERS_HTTP_SHUTDOWN_DRAIN_DELAY", def: "5s",
+		usage: "how long requests are still served after reporting not ready on shutdown"},
+	{key: "http.access_log_sample_ratio", env: "USERS_ACCESS_LOG_SAMPLE_RATIO", def: "1",
+		usage: "fraction of successful requests written to the access log"},
+	{key: "http.idempotency_key_ttl", env: "USERS_IDEMPOTENCY_KEY_TTL", def: "24h",
+		usage: "how long responses to requests with an Idempotency-Key are kept"},
+	{key: "http.tls.cert_file", env: "USERS_HTTP_TLS_CERT_FILE", usage: "TLS certificate, enables TLS"},
+	{key: "http.tls.key_file", env: "USERS_HTTP_TLS_KEY_FILE", usage: "TLS private key"},
+	{key: "http.tls.client_ca_file", env: "USERS_HTTP_TLS_CLIENT_CA_FILE",
+		usage: "CA certificates client certificates are verified with"},
+	{key: "http.tls.client_cert_users", env: "USERS_HTTP_TLS_CLIENT_CERT_USERS",
+		usage: "users authenticated by client certificates: <subject>|<user id>|<scopes>;..."},
+	{key: "http.tls.reload_interval", env: "USERS_HTTP_TLS_RELOAD_INTERVAL", def: "10s",
+		usage: "how often certificate files are checked for changes"},
+	{key: "http.rate_limit.default", env: "USERS_RATE_LIMIT_DEFAULT",
+		usage: "default rate limit: <requests>/<period>[:<burst>]"},
+	{key: "http.rate_limit.rules", env: "USERS_RATE_LIMIT_RULES",
+		usage: "rate limit rules: [<route>][@<tier>]=<limit>;..."},
+	{key: "http.rate_limit.store", env: "USERS_RATE_LIMIT_STORE", def: RateLimitStoreMemory,
+		usage: "where rate limit buckets are kept: memory or postgres"},
+	{key: "http.rate_limit.failed_auth", env: "USERS_RATE_LIMIT_FAILED_AUTH", def: "20/1m",
+		usage: "failed authentications of a client IP: <requests>/<period>[:<burst>], empty disables the limit"},
+	{key: "http.trusted_proxies", env: "USERS_HTTP_TRUSTED_PROXIES",
+		usage: "IP addresses or CIDR ranges of proxies whose X-Forwarded-For and X-Real-IP headers are believed"},
+
+	{key: "admin.bind_address", env: "USERS_ADMIN_BIND_ADDRESS", def: ":9090",
+		usage: "address of the listener for operational endpoints"},
+
+	{key: "tracing.exporter", env: "USERS_TRACING_EXPORTER", def: "none", usage: "none, stdout or otlp"},
+	{key: "tracing.otlp_endpoint", env: "USERS_TRACING_OTLP_ENDPOINT", usage: "host and port of an OTLP/HTTP collector"},
+	{key: "tracing.otlp_insecure", env: "USERS_TRACING_OTLP_INSECURE", def: "false", boolean: true,
+		usage: "export traces over plain HTTP"},
+	{key: "tracing.sample_ratio", env: "USERS_TRACING_SAMPLE_RATIO", def: "1",
+		usage: "fraction of new traces that are sampled"},
+}
+
+func lookupSetting(key string) (setting, bool) {
+	for _, s := range settings {
+		if s.key == key {
+			return s, true
+		}
+	}
+
+	return setting{}, false
+}
+
+type value struct {
+	raw    string
+	source string
+}
+
+// values are the raw settings by key along with where they came from.
+type values map[string]value
+
+func (v values) get(key string) string {
+	return v[key].raw
+}
+
+// invalid describes an invalid setting so that it can be found in the source it came from.
+func (v values) invalid(key string, err error) error {
+	s, _ := lookupSetting(key)
+	val := v[key]
+
+	return errors.Wrapf(err, "invalid %s %q (from %s)", key, val.raw, describeSource(s, val.source))
+}
+
+// missing describes a required setting that wasn't given anywhere.
+func (v values) missing(key string) error {
+	s, _ := lookupSetting(key)
+
+	return errors.Errorf("%s is required: set %s, -%s or %s in the config file", key, s.env, s.flagName(), key)
+}
+
+func (v values) duration(key string) (time.Duration, error) {
+	d, err := time.ParseDuration(v.get(key))
+	if err != nil {
+		return 0, v.invalid(key, err)
+	}
+
+	if d < 0 {
+		return 0, v.invalid(key, errors.New("must not be negative"))
+	}
+
+	return d, nil
+}
+
+func (v values) bool(key string) (bool, error) {
+	b, err := strconv.ParseBool(v.get(key))
+	if err != nil {
+		return false, v.invalid(key, err)
+	}
+
+	return b, nil
+}
+
+func (v values) ratio(key string) (float64, error) {
+	r, err := strconv.ParseFloat(v.get(key), 64)
+	if err != nil {
+		return 0, v.invalid(key, err)
+	}
+
+	if r < 0 || r > 1 {
+		return 0, v.invalid(key, errors.New("must be between 0 and 1"))
+	}
+
+	return r, nil
+}
+
+func (v values) int(key string) (int, error) {
+	i, err := strconv.Atoi(v.get(key))
+	if err != nil {
+		return 0, v.invalid(key, err)
+	}
+
+	if i < 0 {
+		return 0, v.invalid(key, errors.New("must not be negative"))
+	}
+
+	return i, nil
+}
+
+func (v values) int32(key string) (int32, error) {
+	i, err := strconv.ParseInt(v.get(key), 10, 32)
+	if err != nil {
+		return 0, v.invalid(key, err)
+	}
+
+	return int32(i), nil
+}
+
+func describeSource(s setting, source string) string {
+	switch source {
+	case sourceEnv:
+		return "environment variable " + s.env
+	case sourceFlag:
+		return "flag -" + s.flagName()
+	case sourceFile:
+		return "config file"
+	default:
+		return "default"
+	}
+}
+
+// newFlagSet registers a flag for every setting. The flags that were given end up in flagValues.
+func newFlagSet(flagValues map[string]string) *flag.FlagSet {
+	flags := flag.NewFlagSet("users", flag.ContinueOnError)
+	flags.String(flagConfigFile, "", "YAML or TOML config file (or "+envKeyConfigFile+")")
+
+	for _, s := range settings {
+		key := s.key
+		usage := fmt.Sprintf("%s (%s)", s.usage, s.env)
+
+		if s.boolean {
+			flags.BoolFunc(s.flagName(), usage, func(v string) error {
+				flagValues[key] = v
+				return nil
+			})
+
+			continue
+		}
+
+		flags.Func(s.flagName(), usage, func(v string) error {
+			flagValues[key] = v
+			return nil
+		})
+	}
+
+	return flags
+}
+
+// readConfigFile reads the settings from a YAML or TOML file, where they're nested by the parts of their keys.
+func readConfigFile(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read config file")
+	}
+
+	var tree map[string]any
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &tree)
+	case ".toml":
+		err = toml.Unmarshal(content, &tree)
+	default:
+		return nil, ErrUnknownConfigFormat
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse config file %s", path)
+	}
+
+	fileValues := make(map[string]string)
+	if err := flatten("", tree, fileValues); err != nil {
+		return nil, errors.Wrapf(err, "invalid config file %s", path)
+	}
+
+	return fileValues, nil
+}
+
+func flatten(prefix string, tree map[string]any, out map[string]string) error {
+	keys := make([]string, 0, len(tree))
+	for k := range tree {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+
+		switch v := tree[k].(type) {
+		case map[string]any:
+			if err := flatten(key, v, out); err != nil {
+				return err
+			}
+
+			continue
+		case []any:
+			// lists such as rate limit rules are given as separate items rather than a ";"-separated string
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+
+			out[key] = strings.Join(items, listSeparator)
+		case nil:
+			out[key] = ""
+		default:
+			out[key] = fmt.Sprint(v)
+		}
+
+		if _, ok := lookupSetting(key); !ok {
+			return errors.Wrap(ErrUnknownSetting, key)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+// Package db holds the database migrations (for Postgres and SQLite), which are embedded into the binary.
+package db
+
+import (
+	"embed"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+//go:embed migrations/*.sql
+var Migrations embed.FS
+
+// SQLiteMigrations is the separate migration set of the SQLite schema, kept in the "sqlite" directory.
+//
+//go:embed sqlite/*.sql
+var SQLiteMigrations embed.FS
+
+const migrationsDir = "migrations"
+
+// LatestVersion returns the version of the newest migration, which the schema has to be at.
+func LatestVersion() (uint, error) {
+	entries, err := fs.ReadDir(Migrations, migrationsDir)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read migrations")
+	}
+
+	var latest uint
+
+	for _, entry := range entries {
+		prefix, _, ok := strings.Cut(entry.Name(), "_")
+		if !ok {
+			continue
+		}
+
+		version, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			return 0, errors.Wrapf(err, "invalid migration file name %q", entry.Name())
+		}
+
+		if uint(version) > latest {
+			latest = uint(version)
+		}
+	}
+
+	return latest, nil
+}
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    name varchar(255) NOT NULL,
+    prefix varchar(32) NOT NULL UNIQUE,
+    key_hash varchar(64) NOT NULL,
+    scopes text[] NOT NULL DEFAULT '{}',
+    expires_at timestamp DEFAULT NULL,
+    last_used_at timestamp DEFAULT NULL,
+    revoked boolean NOT NULL DEFAULT FALSE,
+    created_at timestamp NOT NULL DEFAULT NOW(),
+    revoked_at timestamp DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS api_keys_user_id_idx ON api_keys (user_id);
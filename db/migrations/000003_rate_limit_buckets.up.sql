@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key varchar(512) PRIMARY KEY,
+    tokens double precision NOT NULL,
+    updated_at timestamp NOT NULL DEFAULT LOCALTIMESTAMP
+);
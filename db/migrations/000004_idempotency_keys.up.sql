@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key varchar(512) PRIMARY KEY,
+    fingerprint varchar(64) NOT NULL,
+    status_code integer NOT NULL DEFAULT 0,
+    response_headers jsonb DEFAULT NULL,
+    response_body bytea DEFAULT NULL,
+    created_at timestamp NOT NULL DEFAULT LOCALTIMESTAMP,
+    expires_at timestamp NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+    id bigserial PRIMARY KEY,
+    operator varchar(255) NOT NULL,
+    action varchar(64) NOT NULL,
+    user_id bigint DEFAULT NULL,
+    details jsonb DEFAULT NULL,
+    error text NOT NULL DEFAULT '',
+    created_at timestamp NOT NULL DEFAULT LOCALTIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS audit_log_user_id_idx ON audit_log (user_id);
@@ -0,0 +1,8 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS updated_at timestamp;
+
+-- filling the column in isn't a change of the users, so it isn't recorded in user_changes
+ALTER TABLE users DISABLE TRIGGER users_record_change;
+UPDATE users SET updated_at = COALESCE(deleted_at, created_at);
+ALTER TABLE users ENABLE TRIGGER users_record_change;
+
+ALTER TABLE users ALTER COLUMN updated_at SET DEFAULT NOW(), ALTER COLUMN updated_at SET NOT NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email varchar(255) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at timestamp DEFAULT NULL;
+
+-- an email belongs to at most one active user, deleted users keep theirs in case they're restored
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_idx ON users (email) WHERE email <> '' AND NOT deleted;
@@ -0,0 +1,12 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS phone_verified_at timestamp DEFAULT NULL;
+
+-- the last code sent to the phone number of a user, a new code replaces it
+CREATE TABLE IF NOT EXISTS phone_verifications (
+    user_id bigint PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    phone_number varchar(30) NOT NULL,
+    code_hash varchar(64) NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    expires_at timestamp NOT NULL,
+    created_at timestamp NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS phone_verifications_expires_at_idx ON phone_verifications (expires_at);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS user_status_changes;
+
+ALTER TABLE users DROP COLUMN IF EXISTS status_before_delete;
+ALTER TABLE users DROP COLUMN IF EXISTS status;
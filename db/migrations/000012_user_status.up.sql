@@ -0,0 +1,16 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status varchar(16) NOT NULL DEFAULT 'active'
+    CHECK (status IN ('pending', 'active', 'suspended', 'locked', 'deleted'));
+-- the status a deleted user had, it's given back when the user is restored
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status_before_delete varchar(16);
+UPDATE users SET status = 'deleted' WHERE deleted;
+
+CREATE TABLE IF NOT EXISTS user_status_changes (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    from_status varchar(16) NOT NULL,
+    to_status varchar(16) NOT NULL,
+    reason text NOT NULL DEFAULT '',
+    actor varchar(255) NOT NULL DEFAULT '',
+    created_at timestamp NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS user_status_changes_user_id_idx ON user_status_changes (user_id);
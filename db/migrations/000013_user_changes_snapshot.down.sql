@@ -0,0 +1,29 @@
+CREATE OR REPLACE FUNCTION record_user_change() RETURNS trigger AS $$
+DECLARE
+    changed_user_id bigint;
+    change_type varchar(16);
+    change_id bigint;
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW IS NOT DISTINCT FROM OLD THEN
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP = 'INSERT' THEN
+        changed_user_id := NEW.id;
+        change_type := 'created';
+    ELSIF TG_OP = 'DELETE' THEN
+        changed_user_id := OLD.id;
+        change_type := 'deleted';
+    ELSE
+        changed_user_id := NEW.id;
+        change_type := CASE WHEN NEW.deleted AND NOT OLD.deleted THEN 'deleted' ELSE 'updated' END;
+    END IF;
+
+    INSERT INTO user_changes (user_id, type) VALUES (changed_user_id, change_type) RETURNING id INTO change_id;
+    PERFORM pg_notify('user_changes', change_id::text);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE user_changes DROP COLUMN IF EXISTS snapshot;
@@ -0,0 +1,36 @@
+-- the row of the user right after the change, so that an event tells what the change was rather than
+-- what the user is like when the event is read; changes recorded before have no snapshot
+ALTER TABLE user_changes ADD COLUMN IF NOT EXISTS snapshot jsonb;
+
+CREATE OR REPLACE FUNCTION record_user_change() RETURNS trigger AS $$
+DECLARE
+    changed_user_id bigint;
+    change_type varchar(16);
+    change_snapshot jsonb;
+    change_id bigint;
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW IS NOT DISTINCT FROM OLD THEN
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP = 'INSERT' THEN
+        changed_user_id := NEW.id;
+        change_type := 'created';
+        change_snapshot := to_jsonb(NEW);
+    ELSIF TG_OP = 'DELETE' THEN
+        changed_user_id := OLD.id;
+        change_type := 'deleted';
+        change_snapshot := to_jsonb(OLD);
+    ELSE
+        changed_user_id := NEW.id;
+        change_type := CASE WHEN NEW.deleted AND NOT OLD.deleted THEN 'deleted' ELSE 'updated' END;
+        change_snapshot := to_jsonb(NEW);
+    END IF;
+
+    INSERT INTO user_changes (user_id, type, snapshot) VALUES (changed_user_id, change_type, change_snapshot)
+        RETURNING id INTO change_id;
+    PERFORM pg_notify('user_changes', change_id::text);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
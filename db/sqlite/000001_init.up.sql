@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS users (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    first_name varchar(255) NOT NULL,
+    last_name varchar(255) NOT NULL,
+    phone_number varchar(30) NOT NULL,
+    address varchar(255) NOT NULL,
+    deleted boolean NOT NULL DEFAULT FALSE,
+    created_at datetime NOT NULL,
+    deleted_at datetime DEFAULT NULL
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    user_id bigint NOT NULL,
+    name varchar(255) NOT NULL,
+    prefix varchar(32) NOT NULL UNIQUE,
+    key_hash varchar(64) NOT NULL,
+    scopes text NOT NULL DEFAULT '[]',
+    expires_at datetime DEFAULT NULL,
+    last_used_at datetime DEFAULT NULL,
+    revoked boolean NOT NULL DEFAULT FALSE,
+    created_at datetime NOT NULL,
+    revoked_at datetime DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS api_keys_user_id_idx ON api_keys (user_id);
@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN updated_at datetime;
+
+UPDATE users SET updated_at = COALESCE(deleted_at, created_at);
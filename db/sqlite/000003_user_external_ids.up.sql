@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS user_external_ids (
+    user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    source varchar(64) NOT NULL,
+    external_id varchar(255) NOT NULL,
+    PRIMARY KEY (source, external_id)
+);
+CREATE INDEX IF NOT EXISTS user_external_ids_user_id_idx ON user_external_ids (user_id);
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN attributes text NOT NULL DEFAULT '{}';
@@ -0,0 +1,4 @@
+ALTER TABLE users ADD COLUMN email varchar(255) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN email_verified_at datetime DEFAULT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_idx ON users (email) WHERE email <> '' AND NOT deleted;
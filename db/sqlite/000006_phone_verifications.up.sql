@@ -0,0 +1,10 @@
+ALTER TABLE users ADD COLUMN phone_verified_at datetime DEFAULT NULL;
+
+CREATE TABLE IF NOT EXISTS phone_verifications (
+    user_id integer PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    phone_number varchar(30) NOT NULL,
+    code_hash varchar(64) NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    expires_at datetime NOT NULL,
+    created_at datetime NOT NULL
+);
@@ -0,0 +1,104 @@
+package entities
+
+import "time"
+
+type APIKeyScope string
+
+const (
+	APIKeyScopeCreateUsers APIKeyScope = "users:create"
+	APIKeyScopeViewUsers   APIKeyScope = "users:view"
+	APIKeyScopeUpdateUsers APIKeyScope = "users:update"
+	APIKeyScopeDeleteUsers APIKeyScope = "users:delete"
+)
+
+// Permission returns the user permission the scope is mapped to.
+func (s APIKeyScope) Permission() (UserPermission, error) {
+	switch s {
+	case APIKeyScopeCreateUsers:
+		return CreateUsersGranted(), nil
+	case APIKeyScopeViewUsers:
+		return ViewUsersGranted(), nil
+	case APIKeyScopeUpdateUsers:
+		return UpdateUsersGranted(), nil
+	case APIKeyScopeDeleteUsers:
+		return DeleteUsersGranted(), nil
+	default:
+		return nil, ErrUnknownAPIKeyScope
+	}
+}
+
+// GrantedTo reports whether the user holds the permission behind the scope,
+// so that nobody can issue a key more powerful than themselves.
+func (s APIKeyScope) GrantedTo(au *AuthenticatedUser) bool {
+	switch s {
+	case APIKeyScopeCreateUsers:
+		return au.canCreate
+	case APIKeyScopeViewUsers:
+		return au.canViewOthers
+	case APIKeyScopeUpdateUsers:
+		return au.canUpdateOthers
+	case APIKeyScopeDeleteUsers:
+		return au.canDelete
+	default:
+		return false
+	}
+}
+
+type APIKey struct {
+	ID         int64
+	UserID     int64
+	Name       string
+	Prefix     string
+	KeyHash    string
+	Scopes     []string
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	Revoked    bool
+	CreatedAt  time.Time
+	RevokedAt  *time.Time
+}
+
+func (k APIKey) IsRevoked() bool {
+	return k.Revoked
+}
+
+func (k APIKey) IsExpired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// Permissions converts the scopes of the key to user permissions.
+func (k APIKey) Permissions() ([]UserPermission, error) {
+	permissions := make([]UserPermission, 0, len(k.Scopes))
+
+	for _, scope := range k.Scopes {
+		p, err := APIKeyScope(scope).Permission()
+		if err != nil {
+			return nil, err
+		}
+
+		permissions = append(permissions, p)
+	}
+
+	return permissions, nil
+}
+
+type CreateAPIKeyParams struct {
+	UserID    int64
+	Name      string
+	Scopes    []string
+	ExpiresAt *time.Time
+}
+
+type UpdateAPIKeyParams struct {
+	Name      *string
+	Scopes    *[]string
+	ExpiresAt *time.Time
+}
+
+// StoreAPIKeyParams is what gets persisted for a new key: the plaintext key
+// itself is never stored, only its prefix (for lookup) and its hash.
+type StoreAPIKeyParams struct {
+	CreateAPIKeyParams
+	Prefix  string
+	KeyHash string
+}
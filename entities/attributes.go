@@ -0,0 +1,92 @@
+package entities
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Attributes are custom fields of a user, a JSON object whose shape is given by the attributes schema
+// rather than by the service.
+type Attributes map[string]any
+
+// Normalize returns a deep copy of the attributes with the values of the types JSON decodes to,
+// e.g. float64 for all numbers, so that they compare the same way as the stored ones. It's never nil.
+func (a Attributes) Normalize() (Attributes, error) {
+	normalized := Attributes{}
+	if len(a) == 0 {
+		return normalized, nil
+	}
+
+	encoded, err := json.Marshal(a)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode attributes")
+	}
+
+	if err := json.Unmarshal(encoded, &normalized); err != nil {
+		return nil, errors.Wrap(err, "failed to decode attributes")
+	}
+
+	return normalized, nil
+}
+
+// MergeAttributes applies the patch to the attributes as a JSON Merge Patch (RFC 7396): objects are merged
+// recursively, a null value removes the attribute and any other value replaces it. The attributes aren't modified.
+func MergeAttributes(attributes, patch Attributes) Attributes {
+	return mergeObjects(attributes, patch)
+}
+
+func mergeObjects(target, patch map[string]any) map[string]any {
+	merged := make(map[string]any, len(target)+len(patch))
+	for k, v := range target {
+		merged[k] = v
+	}
+
+	for k, v := range patch {
+		if v == nil {
+			delete(merged, k)
+			continue
+		}
+
+		patchObject, ok := v.(map[string]any)
+		if !ok {
+			merged[k] = v
+			continue
+		}
+
+		targetObject, _ := merged[k].(map[string]any)
+		merged[k] = mergeObjects(targetObject, patchObject)
+	}
+
+	return merged
+}
+
+// ValidateAttributeFilter checks that the attributes can be used to filter users, i.e. all the values are scalars.
+func ValidateAttributeFilter(filter Attributes) error {
+	for k, v := range filter {
+		switch v.(type) {
+		case string, float64, bool:
+		default:
+			return errors.Wrapf(ErrInvalidAttributeFilter, "attribute %q", k)
+		}
+	}
+
+	return nil
+}
+
+// ParseAttributeCondition parses a key=value condition of an attribute filter. The value is taken as JSON
+// if it's valid and as a string otherwise, so that vip=true matches a boolean while team=core matches a string.
+func ParseAttributeCondition(condition string) (string, any, error) {
+	key, raw, ok := strings.Cut(condition, "=")
+	if !ok || key == "" {
+		return "", nil, errors.Wrapf(ErrInvalidAttributeCondition, "condition %q", condition)
+	}
+
+	var value any
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		value = raw
+	}
+
+	return key, value, nil
+}
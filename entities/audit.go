@@ -0,0 +1,15 @@
+package entities
+
+import "time"
+
+// AuditRecord is an action an operator took on users outside the API, e.g. from the admin CLI.
+type AuditRecord struct {
+	ID       int64
+	Operator string
+	Action   string
+	UserID   *int64
+	Details  map[string]any
+	// Error is the reason the action failed, empty if it succeeded.
+	Error     string
+	CreatedAt time.Time
+}
@@ -6,6 +6,7 @@ type AuthenticatedUser struct {
 	canDelete       bool
 	canUpdateOthers bool
 	canViewOthers   bool
+	withAPIKey      bool
 }
 
 type UserPermission func(user *AuthenticatedUser)
@@ -34,6 +35,13 @@ func ViewUsersGranted() UserPermission {
 	}
 }
 
+// AuthenticatedWithAPIKey marks the user as authenticated by an API key rather than by their own credentials.
+func AuthenticatedWithAPIKey() UserPermission {
+	return func(au *AuthenticatedUser) {
+		au.withAPIKey = true
+	}
+}
+
 func NewAuthenticatedUser(id int64, permissions ...UserPermission) *AuthenticatedUser {
 	au := &AuthenticatedUser{id: id}
 
@@ -60,6 +68,32 @@ func (au AuthenticatedUser) CanUpdateUser(id int64) bool {
 	return au.canUpdateOthers || au.id == id
 }
 
+// CanUpsert reports whether the user may replace any user or create one, as jobs syncing other systems do.
+func (au AuthenticatedUser) CanUpsert() bool {
+	return au.canCreate && au.canUpdateOthers
+}
+
+// CanChangeStatus reports whether the user may suspend or activate another user, no one changes their own status.
+func (au AuthenticatedUser) CanChangeStatus(id int64) bool {
+	return au.canUpdateOthers && au.id != id
+}
+
+// CanManageAPIKeys reports whether the user may issue and edit the API keys of the given user.
+// A leaked API key must not be able to mint keys or extend itself beyond its own expiration.
+func (au AuthenticatedUser) CanManageAPIKeys(id int64) bool {
+	return !au.withAPIKey && au.CanUpdateUser(id)
+}
+
 func (au AuthenticatedUser) CanViewUser(id int64) bool {
 	return au.canViewOthers || au.id == id
 }
+
+// CanListUsers reports whether the user may list users, only those who may view any user do.
+func (au AuthenticatedUser) CanListUsers() bool {
+	return au.canViewOthers
+}
+
+// IsPrivileged reports whether the user holds any permission over other users.
+func (au AuthenticatedUser) IsPrivileged() bool {
+	return au.canCreate || au.canDelete || au.canUpdateOthers || au.canViewOthers
+}
@@ -0,0 +1,34 @@
+package entities
+
+import "context"
+
+type (
+	callerContextKey      struct{}
+	primaryReadContextKey struct{}
+)
+
+// ContextWithCaller marks ctx as serving a request of the user with the given ID,
+// e.g. so that the user reads their own writes.
+func ContextWithCaller(ctx context.Context, userID int64) context.Context {
+	return context.WithValue(ctx, callerContextKey{}, userID)
+}
+
+// CallerFromContext returns the ID of the user the request is served for.
+func CallerFromContext(ctx context.Context) (int64, bool) {
+	userID, ok := ctx.Value(callerContextKey{}).(int64)
+
+	return userID, ok
+}
+
+// ContextWithPrimaryRead makes the reads done with ctx go to the primary database rather than a replica,
+// e.g. when they must see a change that has just been made.
+func ContextWithPrimaryRead(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryReadContextKey{}, true)
+}
+
+// IsPrimaryRead reports whether the reads done with ctx must go to the primary database.
+func IsPrimaryRead(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryReadContextKey{}).(bool)
+
+	return primary
+}
@@ -0,0 +1,9 @@
+package entities
+
+import "strings"
+
+// NormalizeEmail returns the email the way users are stored and compared,
+// addresses differing only in case or surrounding spaces belong to the same user.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -3,6 +3,31 @@ package entities
 import "github.com/pkg/errors"
 
 var (
-	ErrUserNotFound = errors.New("user not found")
-	ErrUserDeleted  = errors.New("user was deleted")
+	ErrUserNotFound                 = errors.New("user not found")
+	ErrUserDeleted                  = errors.New("user was deleted")
+	ErrExternalIDTaken              = errors.New("external ID belongs to another user")
+	ErrEmailTaken                   = errors.New("email belongs to another user")
+	ErrEmailChanged                 = errors.New("email of the user has changed")
+	ErrNoEmail                      = errors.New("user has no email")
+	ErrEmailAlreadyVerified         = errors.New("email of the user is already verified")
+	ErrInvalidVerificationToken     = errors.New("verification token is invalid or has expired")
+	ErrPhoneChanged                 = errors.New("phone number of the user has changed")
+	ErrPhoneAlreadyVerified         = errors.New("phone number of the user is already verified")
+	ErrPhoneVerificationNotFound    = errors.New("phone verification not found")
+	ErrInvalidVerificationCode      = errors.New("verification code is invalid or has expired")
+	ErrTooManyVerificationAttempts  = errors.New("too many attempts to enter the verification code")
+	ErrVerificationCodeRecentlySent = errors.New("verification code was sent recently")
+	ErrInvalidStatus                = errors.New("status of a new user must be pending or active")
+	ErrStatusTransition             = errors.New("status of the user can't be changed that way")
+	ErrStatusChanged                = errors.New("status of the user has changed")
+	ErrUserSuspended                = errors.New("user is suspended, locked or deleted")
+	ErrVerificationDisabled         = errors.New("verification isn't configured")
+	ErrInvalidAttributes            = errors.New("attributes don't match the schema")
+	ErrInvalidAttributeFilter       = errors.New("attributes can be filtered by strings, numbers and booleans only")
+	ErrInvalidAttributeCondition    = errors.New("attribute condition must be given as key=value")
+	ErrAPIKeyNotFound               = errors.New("API key not found")
+	ErrAPIKeyRevoked                = errors.New("API key was revoked")
+	ErrAPIKeyExpired                = errors.New("API key has expired")
+	ErrInvalidAPIKey                = errors.New("invalid API key")
+	ErrUnknownAPIKeyScope           = errors.New("unknown API key scope")
 )
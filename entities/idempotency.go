@@ -0,0 +1,19 @@
+package entities
+
+import "time"
+
+// IdempotencyRecord remembers the response to a request made with an idempotency key.
+// A record without a status code belongs to a request that is still in progress.
+type IdempotencyRecord struct {
+	Key             string
+	Fingerprint     string
+	StatusCode      int
+	ResponseHeaders map[string][]string
+	ResponseBody    []byte
+	CreatedAt       time.Time
+	ExpiresAt       time.Time
+}
+
+func (r IdempotencyRecord) IsCompleted() bool {
+	return r.StatusCode != 0
+}
@@ -0,0 +1,19 @@
+package entities
+
+import "time"
+
+// PhoneVerification is the last one-time code sent to the phone number of a user, only a hash of the code is kept.
+type PhoneVerification struct {
+	UserID int64
+	// PhoneNumber is the number the code was sent to, the code doesn't verify any other number.
+	PhoneNumber string
+	CodeHash    string
+	// Attempts is how many times the code was entered, right or wrong.
+	Attempts  int
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+func (v PhoneVerification) IsExpired(now time.Time) bool {
+	return !now.Before(v.ExpiresAt)
+}
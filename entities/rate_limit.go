@@ -0,0 +1,72 @@
+package entities
+
+import (
+	"math"
+	"time"
+)
+
+// RateLimit is a token bucket that holds up to Burst tokens (Requests if Burst isn't set)
+// and is refilled with Requests tokens every Period.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+func (l RateLimit) IsUnlimited() bool {
+	return l.Requests <= 0 || l.Period <= 0
+}
+
+func (l RateLimit) Capacity() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+
+	return l.Requests
+}
+
+func (l RateLimit) tokensPerSecond() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+type RateLimitDecision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is the time until the next token becomes available.
+	RetryAfter time.Duration
+	// ResetAfter is the time until the bucket is full again.
+	ResetAfter time.Duration
+}
+
+// TakeToken refills the bucket that held the given number of tokens at updatedAt
+// and tries to take one token out of it. It returns the number of tokens left.
+func (l RateLimit) TakeToken(tokens float64, updatedAt, now time.Time) (float64, RateLimitDecision) {
+	capacity := float64(l.Capacity())
+	rate := l.tokensPerSecond()
+
+	elapsed := now.Sub(updatedAt)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	tokens = math.Min(capacity, tokens+elapsed.Seconds()*rate)
+
+	decision := RateLimitDecision{Limit: l.Capacity()}
+
+	if tokens >= 1 {
+		tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = secondsToDuration((1 - tokens) / rate)
+	}
+
+	decision.Remaining = int(math.Floor(tokens))
+	decision.ResetAfter = secondsToDuration((capacity - tokens) / rate)
+
+	return tokens, decision
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,38 @@
+package entities_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/torwig/user-service/entities"
+)
+
+func TestRateLimit_TakeToken(t *testing.T) {
+	limit := entities.RateLimit{Requests: 2, Period: time.Second}
+	now := time.Now()
+
+	tokens, decision := limit.TakeToken(float64(limit.Capacity()), now, now)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, 1, decision.Remaining)
+	assert.Equal(t, 2, decision.Limit)
+
+	tokens, decision = limit.TakeToken(tokens, now, now)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, 0, decision.Remaining)
+	assert.Equal(t, time.Second, decision.ResetAfter)
+
+	tokens, decision = limit.TakeToken(tokens, now, now)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, 500*time.Millisecond, decision.RetryAfter)
+
+	// half a second later the bucket has one token again
+	_, decision = limit.TakeToken(tokens, now, now.Add(500*time.Millisecond))
+	assert.True(t, decision.Allowed)
+}
+
+func TestRateLimit_Capacity(t *testing.T) {
+	assert.Equal(t, 10, entities.RateLimit{Requests: 10, Period: time.Minute}.Capacity())
+	assert.Equal(t, 3, entities.RateLimit{Requests: 10, Period: time.Minute, Burst: 3}.Capacity())
+	assert.True(t, entities.RateLimit{}.IsUnlimited())
+}
@@ -0,0 +1,68 @@
+package entities
+
+import "time"
+
+// UserStatus is the stage of the lifecycle of a user.
+type UserStatus string
+
+const (
+	// UserStatusPending is a user that was created but hasn't been activated yet.
+	UserStatusPending UserStatus = "pending"
+	UserStatusActive  UserStatus = "active"
+	// UserStatusSuspended is a user barred from acting on their own, e.g. for breaking the terms of use.
+	UserStatusSuspended UserStatus = "suspended"
+	// UserStatusLocked is a user barred from acting on their own for security reasons, e.g. a compromised account.
+	UserStatusLocked UserStatus = "locked"
+	// UserStatusDeleted is set by deleting the user, restoring it gives back the status it had before.
+	// It isn't changed to directly.
+	UserStatusDeleted UserStatus = "deleted"
+)
+
+// userStatusTransitions lists the statuses every status can be changed to.
+var userStatusTransitions = map[UserStatus][]UserStatus{
+	UserStatusPending:   {UserStatusActive},
+	UserStatusActive:    {UserStatusSuspended, UserStatusLocked},
+	UserStatusSuspended: {UserStatusActive},
+	UserStatusLocked:    {UserStatusActive},
+}
+
+// CanChangeTo reports whether the status can be changed to the given one.
+func (s UserStatus) CanChangeTo(to UserStatus) bool {
+	for _, status := range userStatusTransitions[s] {
+		if status == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CanAuthenticate reports whether a user with the status may act with their own credentials.
+func (s UserStatus) CanAuthenticate() bool {
+	return s != UserStatusSuspended && s != UserStatusLocked && s != UserStatusDeleted
+}
+
+type ChangeUserStatusParams struct {
+	Status UserStatus
+	// Reason explains the change to whoever looks at the history of the user.
+	Reason string
+	// Actor is who changed the status, e.g. user:42 or operator:alice.
+	Actor string
+}
+
+// StatusChangeCause is recorded along with a status changed by deleting or restoring the user.
+type StatusChangeCause struct {
+	Reason string
+	Actor  string
+}
+
+// UserStatusChange is a record of a changed status of a user.
+type UserStatusChange struct {
+	ID         int64
+	UserID     int64
+	FromStatus UserStatus
+	ToStatus   UserStatus
+	Reason     string
+	Actor      string
+	CreatedAt  time.Time
+}
@@ -1,32 +1,108 @@
 package entities
 
-import "time"
+import (
+	"sort"
+	"time"
+)
 
 type User struct {
 	ID          int64
 	FirstName   string
 	LastName    string
 	PhoneNumber string
-	Address     string
-	Deleted     bool
-	CreatedAt   time.Time
-	DeletedAt   *time.Time
+	// PhoneVerifiedAt is when the user proved to own the phone number, it's reset when the number changes.
+	PhoneVerifiedAt *time.Time
+	Address         string
+	// Email is normalized, see NormalizeEmail, and empty if the user has none.
+	Email string
+	// EmailVerifiedAt is when the user proved to own the email, it's reset when the email changes.
+	EmailVerifiedAt *time.Time
+	// Status is UserStatusDeleted if and only if the user is deleted.
+	Status    UserStatus
+	Deleted   bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time
+	// ExternalIDs identify the user in other systems, sorted by source and ID.
+	ExternalIDs []ExternalID
+	Attributes  Attributes
 }
 
 func (u User) IsDeleted() bool {
 	return u.Deleted
 }
 
+func (u User) IsEmailVerified() bool {
+	return u.Email != "" && u.EmailVerifiedAt != nil
+}
+
+func (u User) IsPhoneVerified() bool {
+	return u.PhoneVerifiedAt != nil
+}
+
 type CreateUserParams struct {
 	FirstName   string
 	LastName    string
 	PhoneNumber string
 	Address     string
+	Email       string
+	// Status of a new user is either pending or active, the default. Replacing a user doesn't change it.
+	Status      UserStatus
+	ExternalIDs []ExternalID
+	Attributes  Attributes
 }
 
+// ReplaceUserParams are all the mutable fields of a user, the fields that aren't set are cleared.
+type ReplaceUserParams = CreateUserParams
+
 type UpdateUserParams struct {
 	FirstName   *string
 	LastName    *string
 	PhoneNumber *string
 	Address     *string
+	// Email replaces the email of the user, an empty one removes it.
+	Email *string
+	// ExternalIDs replace all the external IDs of the user.
+	ExternalIDs *[]ExternalID
+	// Attributes is a JSON Merge Patch of the attributes of the user, see MergeAttributes.
+	Attributes *Attributes
+}
+
+// ListUsersParams selects a page of users ordered by ID.
+type ListUsersParams struct {
+	// AfterID is the ID of the last user of the previous page.
+	AfterID        int64
+	Limit          uint64
+	IncludeDeleted bool
+	// Attributes select the users having all of these attributes, see ValidateAttributeFilter.
+	Attributes Attributes
+}
+
+// ExternalID identifies a user in another system, e.g. an HR or a CRM one.
+// An ID belongs to at most one user within its source.
+type ExternalID struct {
+	Source string
+	ID     string
+}
+
+// WithExternalID returns the IDs with the given one added unless they already contain it.
+func WithExternalID(ids []ExternalID, id ExternalID) []ExternalID {
+	for _, existing := range ids {
+		if existing == id {
+			return ids
+		}
+	}
+
+	return append(append([]ExternalID(nil), ids...), id)
+}
+
+// SortExternalIDs orders the IDs by source and ID, the way users carry them.
+func SortExternalIDs(ids []ExternalID) {
+	sort.Slice(ids, func(i, j int) bool {
+		if ids[i].Source != ids[j].Source {
+			return ids[i].Source < ids[j].Source
+		}
+
+		return ids[i].ID < ids[j].ID
+	})
 }
@@ -0,0 +1,21 @@
+package entities
+
+import "time"
+
+type UserChangeType string
+
+const (
+	UserCreated UserChangeType = "created"
+	UserUpdated UserChangeType = "updated"
+	UserDeleted UserChangeType = "deleted"
+)
+
+// UserChange is a change of a user along with the state of the user right after the change.
+// External IDs aren't part of the change, they are the current ones of the user.
+type UserChange struct {
+	// ID grows with every change, so that a stream of changes can be resumed after the last seen one.
+	ID        int64
+	Type      UserChangeType
+	User      User
+	CreatedAt time.Time
+}
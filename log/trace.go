@@ -0,0 +1,19 @@
+package log
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// WithTraceContext adds the trace and span IDs of the span in ctx to the logger,
+// so that log records can be correlated with traces.
+func WithTraceContext(ctx context.Context, logger *zap.SugaredLogger) *zap.SugaredLogger {
+	spanCtx := trace.SpanContextFromContext(ctx)
+	if !spanCtx.IsValid() {
+		return logger
+	}
+
+	return logger.With("trace_id", spanCtx.TraceID().String(), "span_id", spanCtx.SpanID().String())
+}
@@ -12,6 +12,11 @@ type Config struct {
 }
 
 func NewZapLogger(config Config) *zap.SugaredLogger {
+	return NewZapLoggerTo(config, "stdout")
+}
+
+// NewZapLoggerTo creates a logger writing to the given path, e.g. "stderr" for commands that print to stdout.
+func NewZapLoggerTo(config Config, outputPath string) *zap.SugaredLogger {
 	encoderCfg := zap.NewProductionEncoderConfig()
 	encoderCfg.EncodeTime = zapcore.ISO8601TimeEncoder
 	encoderCfg.EncodeLevel = zapcore.CapitalLevelEncoder
@@ -25,7 +30,7 @@ func NewZapLogger(config Config) *zap.SugaredLogger {
 		DisableStacktrace: true,
 		Encoding:          "json",
 		EncoderConfig:     encoderCfg,
-		OutputPaths:       []string{"stdout"},
+		OutputPaths:       []string{outputPath},
 	}
 
 	zapLog, err := loggerCfg.Build()
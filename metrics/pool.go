@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolCollector reads pgxpool statistics on every scrape.
+type poolCollector struct {
+	stat func() *pgxpool.Stat
+
+	acquiredConns       *prometheus.Desc
+	idleConns           *prometheus.Desc
+	constructingConns   *prometheus.Desc
+	totalConns          *prometheus.Desc
+	maxConns            *prometheus.Desc
+	acquires            *prometheus.Desc
+	emptyAcquires       *prometheus.Desc
+	canceledAcquires    *prometheus.Desc
+	acquireDuration     *prometheus.Desc
+	newConns            *prometheus.Desc
+	maxLifetimeDestroys *prometheus.Desc
+	maxIdleTimeDestroys *prometheus.Desc
+}
+
+func newPoolCollector(stat func() *pgxpool.Stat) *poolCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+	}
+
+	return &poolCollector{
+		stat:                stat,
+		acquiredConns:       desc("acquired_connections", "Number of currently acquired connections."),
+		idleConns:           desc("idle_connections", "Number of currently idle connections."),
+		constructingConns:   desc("constructing_connections", "Number of connections being established."),
+		totalConns:          desc("connections", "Total number of connections in the pool."),
+		maxConns:            desc("max_connections", "Maximum size of the pool."),
+		acquires:            desc("acquires_total", "Number of successful acquires from the pool."),
+		emptyAcquires:       desc("empty_acquires_total", "Number of acquires that waited for a connection."),
+		canceledAcquires:    desc("canceled_acquires_total", "Number of acquires canceled by a context."),
+		acquireDuration:     desc("acquire_duration_seconds_total", "Total time spent on acquires."),
+		newConns:            desc("new_connections_total", "Number of new connections opened."),
+		maxLifetimeDestroys: desc("max_lifetime_destroys_total", "Number of connections closed due to max lifetime."),
+		maxIdleTimeDestroys: desc("max_idle_destroys_total", "Number of connections closed due to max idle time."),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stat()
+
+	gauge := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v)
+	}
+	counter := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v)
+	}
+
+	gauge(c.acquiredConns, float64(s.AcquiredConns()))
+	gauge(c.idleConns, float64(s.IdleConns()))
+	gauge(c.constructingConns, float64(s.ConstructingConns()))
+	gauge(c.totalConns, float64(s.TotalConns()))
+	gauge(c.maxConns, float64(s.MaxConns()))
+	counter(c.acquires, float64(s.AcquireCount()))
+	counter(c.emptyAcquires, float64(s.EmptyAcquireCount()))
+	counter(c.canceledAcquires, float64(s.CanceledAcquireCount()))
+	counter(c.acquireDuration, s.AcquireDuration().Seconds())
+	counter(c.newConns, float64(s.NewConnsCount()))
+	counter(c.maxLifetimeDestroys, float64(s.MaxLifetimeDestroyCount()))
+	counter(c.maxIdleTimeDestroys, float64(s.MaxIdleDestroyCount()))
+}
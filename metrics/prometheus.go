@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "users"
+
+// Prometheus collects RED metrics of the HTTP API, repository query latencies and business counters.
+type Prometheus struct {
+	registry      *prometheus.Registry
+	httpRequests  *prometheus.CounterVec
+	httpDuration  *prometheus.HistogramVec
+	queryDuration *prometheus.HistogramVec
+	usersCreated  prometheus.Counter
+	usersUpdated  prometheus.Counter
+	usersDeleted  prometheus.Counter
+}
+
+func NewPrometheus() *Prometheus {
+	p := &Prometheus{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Number of handled HTTP requests.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of handled HTTP requests.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "repository",
+			Name:      "query_duration_seconds",
+			Help:      "Latency of repository operations.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}, []string{"operation"}),
+		usersCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "created_total",
+			Help:      "Number of created users.",
+		}),
+		usersUpdated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "updated_total",
+			Help:      "Number of updated users.",
+		}),
+		usersDeleted: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "deleted_total",
+			Help:      "Number of deleted users.",
+		}),
+	}
+
+	p.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		p.httpRequests,
+		p.httpDuration,
+		p.queryDuration,
+		p.usersCreated,
+		p.usersUpdated,
+		p.usersDeleted,
+	)
+
+	return p
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (p *Prometheus) Handler() http.Handler {
+	return promhttp.HandlerFor(p.registry, promhttp.HandlerOpts{Registry: p.registry})
+}
+
+// RegisterPool exports statistics of the connection pool.
+func (p *Prometheus) RegisterPool(stat func() *pgxpool.Stat) {
+	p.registry.MustRegister(newPoolCollector(stat))
+}
+
+func (p *Prometheus) ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	p.httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	p.httpDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+func (p *Prometheus) ObserveQuery(operation string, duration time.Duration) {
+	p.queryDuration.WithLabelValues(operation).Observe(duration.Seconds())
+}
+
+func (p *Prometheus) UserCreated() {
+	p.usersCreated.Inc()
+}
+
+func (p *Prometheus) UserUpdated() {
+	p.usersUpdated.Inc()
+}
+
+func (p *Prometheus) UserDeleted() {
+	p.usersDeleted.Inc()
+}
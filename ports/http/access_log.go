@@ -0,0 +1,120 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	mathrand "math/rand"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/torwig/user-service/log"
+	"go.uber.org/zap"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+
+	requestIDBytes = 16
+)
+
+// validRequestID limits propagated request IDs to something safe to log and echo back.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// accessLogEntry collects what is known only deeper in the middleware chain, e.g. the caller.
+type accessLogEntry struct {
+	userID int64
+}
+
+// WithAccessLogSampleRatio logs only the given fraction of successful requests,
+// failed ones (4xx and 5xx) are always logged.
+func WithAccessLogSampleRatio(ratio float64) HandlerOption {
+	return func(h *Handler) {
+		h.accessLogSampleRatio = ratio
+	}
+}
+
+// logRequests assigns a request ID (or propagates the one of the caller), attaches a request-scoped
+// logger to the context and writes an access log record once the request has been handled.
+func (h *Handler) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, requestID)
+
+		logger := log.WithTraceContext(r.Context(), h.log.With("request_id", requestID))
+		entry := &accessLogEntry{}
+
+		ctx := log.NewContext(r.Context(), logger)
+		ctx = context.WithValue(ctx, accessLogEntryCtxKey, entry)
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		if status < http.StatusBadRequest && !h.sampleAccessLog() {
+			return
+		}
+
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		fields := []interface{}{
+			"method", r.Method,
+			"route", route,
+			"status", status,
+			"latency", time.Since(start),
+			"bytes", ww.BytesWritten(),
+		}
+
+		if entry.userID != 0 {
+			fields = append(fields, "user_id", entry.userID)
+		}
+
+		logger.Infow("request handled", fields...)
+	})
+}
+
+func (h *Handler) sampleAccessLog() bool {
+	if h.accessLogSampleRatio >= 1 {
+		return true
+	}
+
+	return mathrand.Float64() < h.accessLogSampleRatio // #nosec G404
+}
+
+// recordCaller reports the authenticated caller to the access log of the request.
+func recordCaller(r *http.Request, userID int64) {
+	if entry, ok := r.Context().Value(accessLogEntryCtxKey).(*accessLogEntry); ok {
+		entry.userID = userID
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, requestIDBytes)
+	if _, err := rand.Read(b); err != nil {
+		// an ID that can't be correlated is better than failing the request
+		return "unknown"
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// logger returns the request-scoped logger carrying the request ID and the trace of the request.
+func (h *Handler) logger(r *http.Request) *zap.SugaredLogger {
+	return log.FromContext(r.Context())
+}
@@ -0,0 +1,63 @@
+package http_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torwig/user-service/entities"
+	userhttp "github.com/torwig/user-service/ports/http"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestHandler_LogRequests(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	router := userhttp.NewHandler(stubUserService{}, stubAPIKeyService{},
+		stubAuthenticator{user: entities.NewAuthenticatedUser(5)},
+		zap.New(core).Sugar(), userhttp.WithAccessLogSampleRatio(0)).Router()
+
+	get := func(path, requestID string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Authorization", "Bearer token")
+		if requestID != "" {
+			r.Header.Set(userhttp.RequestIDHeader, requestID)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		return w
+	}
+
+	t.Run("Successful request is sampled out", func(t *testing.T) {
+		w := get("/api/v1/users/5", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, w.Header().Get(userhttp.RequestIDHeader), 32)
+		assert.Zero(t, logs.TakeAll())
+	})
+
+	t.Run("Failed request is logged with the propagated request ID", func(t *testing.T) {
+		w := get("/api/v1/users/6", "req-42")
+		require.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, "req-42", w.Header().Get(userhttp.RequestIDHeader))
+
+		entries := logs.TakeAll()
+		require.Len(t, entries, 1)
+
+		fields := entries[0].ContextMap()
+		assert.Equal(t, "req-42", fields["request_id"])
+		assert.Equal(t, http.MethodGet, fields["method"])
+		assert.Equal(t, "/api/v1/users/{id}", fields["route"])
+		assert.EqualValues(t, http.StatusForbidden, fields["status"])
+		assert.EqualValues(t, 5, fields["user_id"])
+	})
+
+	t.Run("Invalid request ID is replaced", func(t *testing.T) {
+		w := get("/api/v1/users/5", "bad id\n")
+		assert.Len(t, w.Header().Get(userhttp.RequestIDHeader), 32)
+	})
+}
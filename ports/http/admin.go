@@ -0,0 +1,26 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// AdminHandler serves operational endpoints that must not be exposed on the public listener.
+type AdminHandler struct {
+	metrics http.Handler
+}
+
+func NewAdminHandler(metrics http.Handler) *AdminHandler {
+	return &AdminHandler{metrics: metrics}
+}
+
+func (h *AdminHandler) Router() http.Handler {
+	r := chi.NewRouter()
+	r.Use(middleware.Recoverer)
+
+	r.Handle("/metrics", h.metrics)
+
+	return r
+}
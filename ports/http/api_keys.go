@@ -0,0 +1,218 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+	"github.com/torwig/user-service/ports/http/requests"
+	"github.com/torwig/user-service/ports/http/responses"
+)
+
+func (h *Handler) createAPIKey(w http.ResponseWriter, r *http.Request) {
+	au, err := AuthenticatedUserFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	req, err := requests.NewCreateAPIKey(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	params := req.ToCreateAPIKeyParams(au.ID())
+
+	if !au.CanManageAPIKeys(params.UserID) || !scopesGrantedTo(au, params.Scopes) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	createdKey, plaintext, err := h.keySvc.CreateAPIKey(r.Context(), params)
+	if err != nil {
+		h.logger(r).Errorf("failed to create API key: %s", err)
+
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	responses.SendJSON(w, http.StatusCreated, responses.CreatedAPIKeyFromEntity(createdKey, plaintext))
+}
+
+func (h *Handler) listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	au, err := AuthenticatedUserFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	userID := au.ID()
+
+	if userIDStr := r.URL.Query().Get("user_id"); userIDStr != "" {
+		userID, err = strconv.ParseInt(userIDStr, 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	if userID != au.ID() && !au.CanManageAPIKeys(userID) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	keys, err := h.keySvc.ListAPIKeys(r.Context(), userID)
+	if err != nil {
+		h.logger(r).Errorf("failed to list API keys of user %d: %s", userID, err)
+
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	responses.SendJSON(w, http.StatusOK, responses.APIKeyListFromEntities(keys))
+}
+
+func (h *Handler) getAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := identifierFromRequestURL(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	au, err := AuthenticatedUserFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	key, ok := h.apiKeyVisibleTo(w, r, id, au.CanViewUser)
+	if !ok {
+		return
+	}
+
+	responses.SendJSON(w, http.StatusOK, responses.APIKeyFromEntity(key))
+}
+
+func (h *Handler) updateAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := identifierFromRequestURL(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	au, err := AuthenticatedUserFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	req, err := requests.NewUpdateAPIKey(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	params := req.ToUpdateAPIKeyParams()
+
+	if params.Scopes != nil && !scopesGrantedTo(au, *params.Scopes) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	key, ok := h.apiKeyVisibleTo(w, r, id, au.CanUpdateUser)
+	if !ok {
+		return
+	}
+
+	if !au.CanManageAPIKeys(key.UserID) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	updatedKey, err := h.keySvc.UpdateAPIKey(r.Context(), id, params)
+	if err != nil {
+		h.logger(r).Errorf("failed to update API key %d: %s", id, err)
+
+		switch {
+		case errors.Is(err, entities.ErrAPIKeyNotFound):
+			w.WriteHeader(http.StatusNotFound)
+		case errors.Is(err, entities.ErrAPIKeyRevoked):
+			w.WriteHeader(http.StatusConflict)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	responses.SendJSON(w, http.StatusOK, responses.APIKeyFromEntity(updatedKey))
+}
+
+func (h *Handler) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := identifierFromRequestURL(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	au, err := AuthenticatedUserFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if _, ok := h.apiKeyVisibleTo(w, r, id, au.CanUpdateUser); !ok {
+		return
+	}
+
+	err = h.keySvc.RevokeAPIKey(r.Context(), id)
+	if err != nil {
+		h.logger(r).Errorf("failed to revoke API key %d: %s", id, err)
+
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiKeyVisibleTo loads the key and checks that the caller may access the keys of its owner.
+// Keys of other users are reported as missing so that their identifiers don't leak.
+func (h *Handler) apiKeyVisibleTo(
+	w http.ResponseWriter,
+	r *http.Request,
+	id int64,
+	allowed func(userID int64) bool,
+) (entities.APIKey, bool) {
+	key, err := h.keySvc.GetAPIKey(r.Context(), id)
+	if err != nil {
+		h.logger(r).Errorf("failed to get API key %d: %s", id, err)
+
+		if errors.Is(err, entities.ErrAPIKeyNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+
+		return key, false
+	}
+
+	if !allowed(key.UserID) {
+		w.WriteHeader(http.StatusNotFound)
+		return key, false
+	}
+
+	return key, true
+}
+
+func scopesGrantedTo(au *entities.AuthenticatedUser, scopes []string) bool {
+	for _, s := range scopes {
+		if !entities.APIKeyScope(s).GrantedTo(au) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,82 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/torwig/user-service/adapters/repository"
+	"github.com/torwig/user-service/entities"
+	userhttp "github.com/torwig/user-service/ports/http"
+	"github.com/torwig/user-service/ports/http/generated"
+	"github.com/torwig/user-service/service"
+	"go.uber.org/zap"
+)
+
+func TestHandler_APIKeyAuthentication(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryRepository()
+	svc := service.New(repo)
+	keySvc := service.NewAPIKeyService(repo, repo)
+
+	user, err := svc.CreateUser(ctx, entities.CreateUserParams{
+		FirstName: "John", LastName: "Doe", PhoneNumber: "+1234567890", Address: "Springfield",
+	})
+	require.NoError(t, err)
+
+	// a key can't grant more than its creator holds
+	creator := entities.NewAuthenticatedUser(user.ID, entities.ViewUsersGranted())
+	router := userhttp.NewHandler(svc, keySvc, stubAuthenticator{user: creator}, zap.NewNop().Sugar()).Router()
+
+	do := func(authorization, method, path, body string) *httptest.ResponseRecorder {
+		return serve(router, method, path, body, "Authorization", authorization)
+	}
+
+	w := do("Bearer token", http.MethodPost, "/api/v1/api-keys", `{"name":"sync","scopes":["users:view"]}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var created generated.CreatedAPIKey
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	require.True(t, strings.HasPrefix(created.Key, "usk_"))
+
+	userPath := "/api/v1/users/" + strconv.FormatInt(user.ID, 10)
+
+	t.Run("Valid key", func(t *testing.T) {
+		w := do("ApiKey "+created.Key, http.MethodGet, userPath, "")
+		require.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Wrong secret", func(t *testing.T) {
+		w := do("ApiKey "+created.Key+"x", http.MethodGet, userPath, "")
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Key creation with a key", func(t *testing.T) {
+		w := do("ApiKey "+created.Key, http.MethodPost, "/api/v1/api-keys", `{"name":"copy","scopes":["users:view"]}`)
+		require.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Key extension with a key", func(t *testing.T) {
+		w := do("ApiKey "+created.Key, http.MethodPatch, "/api/v1/api-keys/"+strconv.FormatInt(created.ApiKey.Id, 10),
+			`{"expires_at":"2099-01-01T00:00:00Z"}`)
+		require.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Keys of another user listed with a key", func(t *testing.T) {
+		w := do("ApiKey "+created.Key, http.MethodGet, "/api/v1/api-keys?user_id="+strconv.FormatInt(user.ID+1, 10), "")
+		require.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Revoked key", func(t *testing.T) {
+		w := do("Bearer token", http.MethodDelete, "/api/v1/api-keys/"+strconv.FormatInt(created.ApiKey.Id, 10), "")
+		require.Equal(t, http.StatusNoContent, w.Code)
+
+		w = do("ApiKey "+created.Key, http.MethodGet, userPath, "")
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
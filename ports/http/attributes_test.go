@@ -0,0 +1,102 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torwig/user-service/adapters/repository"
+	"github.com/torwig/user-service/adapters/schema"
+	"github.com/torwig/user-service/entities"
+	"github.com/torwig/user-service/ports/http/generated"
+	"github.com/torwig/user-service/service"
+)
+
+func TestHandler_Attributes(t *testing.T) {
+	attributesSchema, err := schema.NewAttributesSchema([]byte(`{
+		"type": "object",
+		"properties": {
+			"team": {"type": "string"},
+			"level": {"type": "integer", "minimum": 1}
+		},
+		"additionalProperties": false
+	}`))
+	require.NoError(t, err)
+
+	svc := service.New(repository.NewInMemoryRepository(), service.WithAttributesValidator(attributesSchema))
+	admin := entities.NewAuthenticatedUser(1000,
+		entities.CreateUsersGranted(), entities.ViewUsersGranted(), entities.UpdateUsersGranted())
+
+	do := func(method, path, contentType, body string) *httptest.ResponseRecorder {
+		return serveAs(svc, admin, method, path, body, "Content-Type", contentType)
+	}
+
+	w := do(http.MethodPost, "/api/v1/users", "application/json", `{"first_name":"John","last_name":"Doe",`+
+		`"phone_number":"+1234567890","address":"Springfield","attributes":{"team":"core","level":2}}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, map[string]interface{}{"team": "core", "level": 2.0}, userOf(t, w).Attributes)
+
+	t.Run("invalid on create", func(t *testing.T) {
+		w := do(http.MethodPost, "/api/v1/users", "application/json", `{"first_name":"Jane","last_name":"Doe",`+
+			`"phone_number":"+1234567891","address":"Springfield","attributes":{"level":0}}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("update merges attributes", func(t *testing.T) {
+		w := do(http.MethodPatch, "/api/v1/users/1", "application/json", `{"attributes":{"level":3}}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, map[string]interface{}{"team": "core", "level": 3.0}, userOf(t, w).Attributes)
+	})
+
+	t.Run("merged attributes are validated", func(t *testing.T) {
+		w := do(http.MethodPatch, "/api/v1/users/1", "application/json", `{"attributes":{"nickname":"Johnny"}}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("merge patch removes attribute", func(t *testing.T) {
+		w := do(http.MethodPatch, "/api/v1/users/1", "application/merge-patch+json", `{"attributes":{"team":null}}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, map[string]interface{}{"level": 3.0}, userOf(t, w).Attributes)
+	})
+
+	t.Run("replace sets all attributes", func(t *testing.T) {
+		w := do(http.MethodPut, "/api/v1/users/1", "application/json", `{"first_name":"John","last_name":"Doe",`+
+			`"phone_number":"+1234567890","address":"Springfield","attributes":{"team":"edge"}}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, map[string]interface{}{"team": "edge"}, userOf(t, w).Attributes)
+	})
+	t.Run("list by attributes", func(t *testing.T) {
+		w := do(http.MethodPost, "/api/v1/users", "application/json", `{"first_name":"Jane","last_name":"Doe",`+
+			`"phone_number":"+1234567891","address":"Springfield","attributes":{"team":"core","level":2}}`)
+		require.Equal(t, http.StatusCreated, w.Code)
+		jane := userOf(t, w)
+
+		w = do(http.MethodGet, "/api/v1/users?attribute=team=core&attribute=level=2", "", "")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var list generated.UserList
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&list))
+		require.Len(t, list.Items, 1)
+		assert.Equal(t, jane.Id, list.Items[0].Id)
+
+		// the value is taken as JSON, so "2" is another attribute than 2
+		w = do(http.MethodGet, `/api/v1/users?attribute=level="2"`, "", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&list))
+		assert.Empty(t, list.Items)
+	})
+
+	t.Run("list by invalid filter", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/api/v1/users?attribute=team", "", "").Code)
+		assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/api/v1/users?attribute=tags=[1]", "", "").Code)
+		assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/api/v1/users?limit=0", "", "").Code)
+	})
+
+	t.Run("list requires permission to view any user", func(t *testing.T) {
+		w := serveAs(svc, entities.NewAuthenticatedUser(1), http.MethodGet, "/api/v1/users?attribute=team=core", "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
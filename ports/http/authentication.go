@@ -13,32 +13,115 @@ type contextKey int
 
 const (
 	authenticatedUserCtxKey contextKey = 1
+	accessLogEntryCtxKey    contextKey = 2
+	authAttemptCtxKey       contextKey = 3
+)
+
+const (
+	bearerAuthScheme = "Bearer"
+	apiKeyAuthScheme = "ApiKey"
 )
 
 var ErrNotFoundInRequest = errors.New("failed to get from request")
 
-func BearerTokenAuthentication(authenticator UserAuthenticator) func(next http.Handler) http.Handler {
+// credentialsAuthenticator authenticates the credentials of a single authorization scheme.
+type credentialsAuthenticator func(ctx context.Context, credentials string) (*entities.AuthenticatedUser, error)
+
+func BearerTokenAuthentication(
+	authenticator UserAuthenticator,
+	statusChecker UserStatusChecker,
+) func(next http.Handler) http.Handler {
+	return schemeAuthentication(map[string]credentialsAuthenticator{
+		bearerAuthScheme: bearerToken(authenticator),
+	}, statusChecker)
+}
+
+func APIKeyAuthentication(
+	authenticator APIKeyAuthenticator,
+	statusChecker UserStatusChecker,
+) func(next http.Handler) http.Handler {
+	return schemeAuthentication(map[string]credentialsAuthenticator{
+		apiKeyAuthScheme: authenticator.AuthenticateAPIKey,
+	}, statusChecker)
+}
+
+// Authentication accepts either a bearer token or an API key in the Authorization header.
+func Authentication(
+	tokenAuthenticator UserAuthenticator,
+	keyAuthenticator APIKeyAuthenticator,
+	statusChecker UserStatusChecker,
+) func(next http.Handler) http.Handler {
+	return schemeAuthentication(map[string]credentialsAuthenticator{
+		bearerAuthScheme: bearerToken(tokenAuthenticator),
+		apiKeyAuthScheme: keyAuthenticator.AuthenticateAPIKey,
+	}, statusChecker)
+}
+
+// schemeAuthentication rejects the credentials of suspended and locked users even if they are still valid.
+func schemeAuthentication(
+	schemes map[string]credentialsAuthenticator,
+	statusChecker UserStatusChecker,
+) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, err := AuthenticatedUserFromRequest(r); err == nil {
+				// already authenticated by a client certificate
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			authHeaderValue := r.Header.Get("Authorization")
 			values := strings.Split(authHeaderValue, " ")
-			if len(values) != 2 || values[0] != "Bearer" {
+			if len(values) != 2 {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			authenticate, ok := schemes[values[0]]
+			if !ok {
 				w.WriteHeader(http.StatusUnauthorized)
 				return
 			}
 
-			user, err := authenticator.ParseAccessToken(values[1])
+			user, err := authenticate(r.Context(), values[1])
 			if err != nil {
+				recordFailedAuthentication(r)
+
 				w.WriteHeader(http.StatusUnauthorized)
 				return
 			}
 
-			ctxWithUser := context.WithValue(r.Context(), authenticatedUserCtxKey, user)
-			next.ServeHTTP(w, r.WithContext(ctxWithUser))
+			if err := statusChecker.CheckUserStatus(r.Context(), user.ID()); err != nil {
+				if errors.Is(err, entities.ErrUserSuspended) {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+
+				w.WriteHeader(http.StatusInternalServerError)
+
+				return
+			}
+
+			next.ServeHTTP(w, withAuthenticatedUser(r, user))
 		})
 	}
 }
 
+func bearerToken(authenticator UserAuthenticator) credentialsAuthenticator {
+	return func(_ context.Context, token string) (*entities.AuthenticatedUser, error) {
+		return authenticator.ParseAccessToken(token)
+	}
+}
+
+func withAuthenticatedUser(r *http.Request, user *entities.AuthenticatedUser) *http.Request {
+	recordCaller(r, user.ID())
+
+	ctx := context.WithValue(r.Context(), authenticatedUserCtxKey, user)
+	ctx = entities.ContextWithCaller(ctx, user.ID())
+
+	return r.WithContext(ctx)
+}
+
 func AuthenticatedUserFromRequest(r *http.Request) (*entities.AuthenticatedUser, error) {
 	au, ok := r.Context().Value(authenticatedUserCtxKey).(*entities.AuthenticatedUser)
 	if !ok {
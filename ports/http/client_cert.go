@@ -0,0 +1,75 @@
+package http
+
+import (
+	"crypto/x509"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+)
+
+var ErrUnknownCertificateSubject = errors.New("client certificate subject isn't mapped to a user")
+
+// ClientCertUser describes the user a client certificate with the given subject
+// (e.g. "CN=billing,O=Acme") is authenticated as. Scopes are the same as for API keys.
+type ClientCertUser struct {
+	Subject string
+	UserID  int64
+	Scopes  []string
+}
+
+type ClientCertAuthenticator struct {
+	users map[string]*entities.AuthenticatedUser
+}
+
+func NewClientCertAuthenticator(users []ClientCertUser) (*ClientCertAuthenticator, error) {
+	a := &ClientCertAuthenticator{users: make(map[string]*entities.AuthenticatedUser, len(users))}
+
+	for _, u := range users {
+		permissions := make([]entities.UserPermission, 0, len(u.Scopes))
+
+		for _, s := range u.Scopes {
+			p, err := entities.APIKeyScope(s).Permission()
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid scope %q for subject %q", s, u.Subject)
+			}
+
+			permissions = append(permissions, p)
+		}
+
+		a.users[u.Subject] = entities.NewAuthenticatedUser(u.UserID, permissions...)
+	}
+
+	return a, nil
+}
+
+func (a *ClientCertAuthenticator) AuthenticateCertificate(cert *x509.Certificate) (*entities.AuthenticatedUser, error) {
+	au, ok := a.users[cert.Subject.String()]
+	if !ok {
+		return nil, ErrUnknownCertificateSubject
+	}
+
+	return au, nil
+}
+
+// ClientCertificateAuthentication authenticates requests that came with a verified client certificate
+// whose subject is mapped to a user. Other requests are passed as is, so that the next
+// authentication middleware can check their Authorization header.
+func ClientCertificateAuthentication(authenticator *ClientCertAuthenticator) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user, err := authenticator.AuthenticateCertificate(r.TLS.VerifiedChains[0][0])
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, withAuthenticatedUser(r, user))
+		})
+	}
+}
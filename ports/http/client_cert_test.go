@@ -0,0 +1,74 @@
+package http_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torwig/user-service/entities"
+	userhttp "github.com/torwig/user-service/ports/http"
+)
+
+func TestNewClientCertAuthenticator(t *testing.T) {
+	_, err := userhttp.NewClientCertAuthenticator([]userhttp.ClientCertUser{
+		{Subject: "CN=billing", UserID: 1, Scopes: []string{"users:fly"}},
+	})
+	require.ErrorIs(t, err, entities.ErrUnknownAPIKeyScope)
+}
+
+func TestClientCertificateAuthentication(t *testing.T) {
+	certAuth, err := userhttp.NewClientCertAuthenticator([]userhttp.ClientCertUser{
+		{Subject: "CN=billing,O=Acme", UserID: 1001, Scopes: []string{"users:view"}},
+	})
+	require.NoError(t, err)
+
+	var authenticatedUser *entities.AuthenticatedUser
+
+	handler := userhttp.ClientCertificateAuthentication(certAuth)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authenticatedUser, _ = userhttp.AuthenticatedUserFromRequest(r)
+		}),
+	)
+
+	requestWithCert := func(subject pkix.Name) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/users/1", nil)
+		r.TLS = &tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{{Subject: subject}}},
+		}
+
+		return r
+	}
+
+	t.Run("Mapped subject", func(t *testing.T) {
+		authenticatedUser = nil
+
+		handler.ServeHTTP(httptest.NewRecorder(),
+			requestWithCert(pkix.Name{CommonName: "billing", Organization: []string{"Acme"}}))
+
+		require.NotNil(t, authenticatedUser)
+		assert.Equal(t, int64(1001), authenticatedUser.ID())
+		assert.True(t, authenticatedUser.CanViewUser(1))
+		assert.False(t, authenticatedUser.CanUpdateUser(1))
+	})
+
+	t.Run("Unknown subject", func(t *testing.T) {
+		authenticatedUser = nil
+
+		handler.ServeHTTP(httptest.NewRecorder(), requestWithCert(pkix.Name{CommonName: "crm"}))
+
+		assert.Nil(t, authenticatedUser)
+	})
+
+	t.Run("No client certificate", func(t *testing.T) {
+		authenticatedUser = nil
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/users/1", nil))
+
+		assert.Nil(t, authenticatedUser)
+	})
+}
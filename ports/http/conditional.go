@@ -0,0 +1,68 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ifNoneMatchHeader     = "If-None-Match"
+	ifModifiedSinceHeader = "If-Modified-Since"
+	// etagHashLength is the number of bytes of the hash of a representation kept in its entity tag.
+	etagHashLength = 16
+)
+
+// representationETag returns a strong entity tag of the JSON representation of a resource,
+// it changes whenever any field of the representation does.
+func representationETag(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode representation")
+	}
+
+	sum := sha256.Sum256(data)
+
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:etagHashLength]) + `"`, nil
+}
+
+// setValidators makes the response cacheable by the client only, as it depends on the caller's permissions.
+func setValidators(w http.ResponseWriter, etag string, lastModified time.Time) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", "private")
+}
+
+// notModified reports whether the copy the client has cached is still current. If-Modified-Since is ignored
+// when If-None-Match is given, as required by RFC 9110.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if ifNoneMatch := r.Header.Get(ifNoneMatchHeader); ifNoneMatch != "" {
+		return entityTagMatches(ifNoneMatch, etag)
+	}
+
+	ifModifiedSince, err := http.ParseTime(r.Header.Get(ifModifiedSinceHeader))
+	if err != nil {
+		return false
+	}
+
+	// Last-Modified has a precision of a second, so the fraction of the time is ignored
+	return !lastModified.Truncate(time.Second).After(ifModifiedSince)
+}
+
+// entityTagMatches checks the list of entity tags of If-None-Match using the weak comparison.
+func entityTagMatches(list, etag string) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
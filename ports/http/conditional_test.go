@@ -0,0 +1,75 @@
+package http_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torwig/user-service/entities"
+	userhttp "github.com/torwig/user-service/ports/http"
+	"go.uber.org/zap"
+)
+
+func TestHandler_ConditionalGetUser(t *testing.T) {
+	router := userhttp.NewHandler(stubUserService{}, stubAPIKeyService{},
+		stubAuthenticator{user: entities.NewAuthenticatedUser(1)}, zap.NewNop().Sugar()).Router()
+
+	get := func(headers map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/users/1", nil)
+		r.Header.Set("Authorization", "Bearer token")
+
+		for name, value := range headers {
+			r.Header.Set(name, value)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		return w
+	}
+
+	w := get(nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	etag := w.Header().Get("ETag")
+	assert.Regexp(t, `^"[\w-]+"$`, etag, "entity tag must be strong")
+	assert.Equal(t, "Fri, 01 Mar 2024 12:30:15 GMT", w.Header().Get("Last-Modified"))
+	assert.Equal(t, "private", w.Header().Get("Cache-Control"))
+	assert.Contains(t, w.Body.String(), `"updated_at":"2024-03-01T12:30:15.5Z"`)
+
+	assert.Equal(t, etag, get(nil).Header().Get("ETag"), "entity tag must be stable")
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+	}{
+		{"matching entity tag", map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"one of entity tags", map[string]string{"If-None-Match": `"other", W/` + etag}, http.StatusNotModified},
+		{"any entity tag", map[string]string{"If-None-Match": "*"}, http.StatusNotModified},
+		{"other entity tag", map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+		{"modified since", map[string]string{"If-Modified-Since": "Fri, 01 Mar 2024 12:30:14 GMT"}, http.StatusOK},
+		{"not modified since", map[string]string{
+			"If-Modified-Since": stubUserUpdatedAt.Format(http.TimeFormat),
+		}, http.StatusNotModified},
+		{"entity tag takes precedence", map[string]string{
+			"If-None-Match":     `"other"`,
+			"If-Modified-Since": stubUserUpdatedAt.Add(time.Hour).Format(http.TimeFormat),
+		}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(tt.headers)
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, etag, w.Header().Get("ETag"))
+
+			if tt.status == http.StatusNotModified {
+				assert.Empty(t, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+	"github.com/torwig/user-service/ports/http/requests"
+	"github.com/torwig/user-service/ports/http/responses"
+)
+
+// sendEmailVerification sends a verification token to the email of the user.
+func (h *Handler) sendEmailVerification(w http.ResponseWriter, r *http.Request) {
+	id, err := identifierFromRequestURL(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	au, err := AuthenticatedUserFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if !au.CanUpdateUser(id) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	err = h.svc.SendEmailVerification(r.Context(), id)
+	if err != nil {
+		h.logger(r).Errorf("failed to send email verification to user %d: %s", id, err)
+		writeVerificationError(w, err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// verifyEmail marks the email of the user as verified with the token sent to it.
+func (h *Handler) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	id, err := identifierFromRequestURL(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	au, err := AuthenticatedUserFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if !au.CanUpdateUser(id) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	req, err := requests.NewVerifyEmail(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.svc.VerifyEmail(r.Context(), id, req.Token)
+	if err != nil {
+		h.logger(r).Errorf("failed to verify email of user %d: %s", id, err)
+		writeVerificationError(w, err)
+
+		return
+	}
+
+	responses.SendJSON(w, http.StatusOK, responses.UserFromEntity(user))
+}
+
+// writeVerificationError responds to a failed verification of an email or a phone number.
+func writeVerificationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, entities.ErrUserNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, entities.ErrNoEmail), errors.Is(err, entities.ErrEmailAlreadyVerified),
+		errors.Is(err, entities.ErrPhoneAlreadyVerified):
+		w.WriteHeader(http.StatusConflict)
+	case errors.Is(err, entities.ErrInvalidVerificationToken), errors.Is(err, entities.ErrInvalidVerificationCode):
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	case errors.Is(err, entities.ErrTooManyVerificationAttempts),
+		errors.Is(err, entities.ErrVerificationCodeRecentlySent):
+		w.WriteHeader(http.StatusTooManyRequests)
+	case errors.Is(err, entities.ErrVerificationDisabled):
+		w.WriteHeader(http.StatusNotImplemented)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
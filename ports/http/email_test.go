@@ -0,0 +1,98 @@
+package http_test
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torwig/user-service/adapters/notify"
+	"github.com/torwig/user-service/adapters/repository"
+	"github.com/torwig/user-service/entities"
+	"github.com/torwig/user-service/service"
+)
+
+func TestHandler_EmailVerification(t *testing.T) {
+	messagesFile := filepath.Join(t.TempDir(), "messages.jsonl")
+	svc := service.New(repository.NewInMemoryRepository(), service.WithEmailVerification(
+		service.EmailVerificationConfig{Secret: []byte("verification-secret")}, notify.NewFileNotifier(messagesFile)))
+	admin := entities.NewAuthenticatedUser(1000,
+		entities.CreateUsersGranted(), entities.ViewUsersGranted(), entities.UpdateUsersGranted())
+
+	lastToken := func() string {
+		messages, err := notify.ReadMessages(messagesFile)
+		require.NoError(t, err)
+		require.NotEmpty(t, messages)
+
+		return messages[len(messages)-1].Token
+	}
+
+	w := serveAs(svc, admin, http.MethodPost, "/api/v1/users", `{"first_name":"John","last_name":"Doe",`+
+		`"phone_number":"+1234567890","address":"Springfield","email":" John.Doe@Example.com "}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	user := userOf(t, w)
+	require.NotNil(t, user.Email)
+	assert.Equal(t, "john.doe@example.com", *user.Email)
+	assert.Nil(t, user.EmailVerifiedAt)
+
+	t.Run("email of another user", func(t *testing.T) {
+		w := serveAs(svc, admin, http.MethodPost, "/api/v1/users", `{"first_name":"Jane","last_name":"Doe",`+
+			`"phone_number":"+1234567891","address":"Springfield","email":"john.doe@example.com"}`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("invalid email", func(t *testing.T) {
+		w := serveAs(svc, admin, http.MethodPatch, "/api/v1/users/1", `{"email":"john.doe"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		w := serveAs(svc, admin, http.MethodPost, "/api/v1/users/1/email/verify", `{"token":"invalid"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("token of previous email", func(t *testing.T) {
+		require.Equal(t, http.StatusAccepted,
+			serveAs(svc, admin, http.MethodPost, "/api/v1/users/1/email/verification", "").Code)
+		token := lastToken()
+
+		w := serveAs(svc, admin, http.MethodPatch, "/api/v1/users/1", `{"email":"john@example.com"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = serveAs(svc, admin, http.MethodPost, "/api/v1/users/1/email/verify", `{"token":"`+token+`"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("verify email", func(t *testing.T) {
+		require.Equal(t, http.StatusAccepted,
+			serveAs(svc, admin, http.MethodPost, "/api/v1/users/1/email/verification", "").Code)
+
+		messages, err := notify.ReadMessages(messagesFile)
+		require.NoError(t, err)
+		assert.Equal(t, "john@example.com", messages[len(messages)-1].To)
+
+		w := serveAs(svc, admin, http.MethodPost, "/api/v1/users/1/email/verify", `{"token":"`+lastToken()+`"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotNil(t, userOf(t, w).EmailVerifiedAt)
+
+		w = serveAs(svc, admin, http.MethodPost, "/api/v1/users/1/email/verification", "")
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("changed email must be verified again", func(t *testing.T) {
+		w := serveAs(svc, admin, http.MethodPatch, "/api/v1/users/1", `{"email":"doe@example.com"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Nil(t, userOf(t, w).EmailVerifiedAt)
+	})
+
+	t.Run("user without email", func(t *testing.T) {
+		w := serveAs(svc, admin, http.MethodPatch, "/api/v1/users/1", `{"email":""}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Nil(t, userOf(t, w).Email)
+
+		w = serveAs(svc, admin, http.MethodPost, "/api/v1/users/1/email/verification", "")
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+}
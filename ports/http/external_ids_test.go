@@ -0,0 +1,74 @@
+package http_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torwig/user-service/adapters/repository"
+	"github.com/torwig/user-service/entities"
+	"github.com/torwig/user-service/ports/http/generated"
+	"github.com/torwig/user-service/service"
+)
+
+func TestHandler_ExternalIDs(t *testing.T) {
+	svc := service.New(repository.NewInMemoryRepository())
+
+	admin := entities.NewAuthenticatedUser(1000,
+		entities.CreateUsersGranted(), entities.ViewUsersGranted(), entities.UpdateUsersGranted())
+
+	w := serveAs(svc, admin, http.MethodPost, "/api/v1/users", `{"first_name":"John","last_name":"Doe",`+
+		`"phone_number":"+1234567890","address":"Springfield","external_ids":[{"source":"hr","id":"E-1"}]}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	created := userOf(t, w)
+	assert.Equal(t, []generated.ExternalID{{Source: "hr", Id: "E-1"}}, created.ExternalIds)
+
+	t.Run("lookup", func(t *testing.T) {
+		w := serveAs(svc, admin, http.MethodGet, "/api/v1/users/by-external/hr/E-1", "")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		user := userOf(t, w)
+		assert.Equal(t, created, user)
+	})
+
+	t.Run("unknown ID", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, serveAs(svc, admin, http.MethodGet, "/api/v1/users/by-external/hr/E-2", "").Code)
+	})
+
+	t.Run("user the caller can't view", func(t *testing.T) {
+		stranger := entities.NewAuthenticatedUser(created.Id + 1)
+		assert.Equal(t, http.StatusNotFound,
+			serveAs(svc, stranger, http.MethodGet, "/api/v1/users/by-external/hr/E-1", "").Code)
+	})
+
+	t.Run("invalid source", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest,
+			serveAs(svc, admin, http.MethodGet, "/api/v1/users/by-external/HR/E-1", "").Code)
+	})
+
+	t.Run("ID of another user", func(t *testing.T) {
+		w := serveAs(svc, admin, http.MethodPost, "/api/v1/users", `{"first_name":"Jane","last_name":"Doe",`+
+			`"phone_number":"+1234567891","address":"Springfield","external_ids":[{"source":"hr","id":"E-1"}]}`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("duplicate ID", func(t *testing.T) {
+		w := serveAs(svc, admin, http.MethodPatch, "/api/v1/users/1",
+			`{"external_ids":[{"source":"crm","id":"7"},{"source":"crm","id":"7"}]}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("update replaces IDs", func(t *testing.T) {
+		w := serveAs(svc, admin, http.MethodPatch, "/api/v1/users/1", `{"external_ids":[{"source":"crm","id":"7"}]}`)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		user, err := svc.GetUserByExternalID(context.Background(), "crm", "7")
+		require.NoError(t, err)
+		assert.Equal(t, []entities.ExternalID{{Source: "crm", ID: "7"}}, user.ExternalIDs)
+
+		assert.Equal(t, http.StatusNotFound, serveAs(svc, admin, http.MethodGet, "/api/v1/users/by-external/hr/E-1", "").Code)
+	})
+}
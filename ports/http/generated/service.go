@@ -3,37 +3,296 @@
 // Code generated by github.com/deepmap/oapi-codegen version v1.13.4 DO NOT EDIT.
 package generated
 
+import (
+	"time"
+)
+
 const (
+	ApiKeyAuthScopes = "apiKeyAuth.Scopes"
 	BearerAuthScopes = "bearerAuth.Scopes"
 )
 
+// Defines values for APIKeyScope.
+const (
+	UsersCreate APIKeyScope = "users:create"
+	UsersDelete APIKeyScope = "users:delete"
+	UsersUpdate APIKeyScope = "users:update"
+	UsersView   APIKeyScope = "users:view"
+)
+
+// Defines values for UserCreateParamsStatus.
+const (
+	UserCreateParamsStatusActive  UserCreateParamsStatus = "active"
+	UserCreateParamsStatusPending UserCreateParamsStatus = "pending"
+)
+
+// Defines values for UserStatus.
+const (
+	UserStatusActive    UserStatus = "active"
+	UserStatusDeleted   UserStatus = "deleted"
+	UserStatusLocked    UserStatus = "locked"
+	UserStatusPending   UserStatus = "pending"
+	UserStatusSuspended UserStatus = "suspended"
+)
+
+// APIKey defines model for APIKey.
+type APIKey struct {
+	CreatedAt  time.Time     `json:"created_at"`
+	ExpiresAt  *time.Time    `json:"expires_at,omitempty"`
+	Id         int64         `json:"id"`
+	LastUsedAt *time.Time    `json:"last_used_at,omitempty"`
+	Name       string        `json:"name"`
+	Prefix     string        `json:"prefix"`
+	Revoked    bool          `json:"revoked"`
+	Scopes     []APIKeyScope `json:"scopes"`
+	UserId     int64         `json:"user_id"`
+}
+
+// APIKeyCreateParams defines model for APIKeyCreateParams.
+type APIKeyCreateParams struct {
+	ExpiresAt *time.Time    `json:"expires_at,omitempty"`
+	Name      string        `json:"name"`
+	Scopes    []APIKeyScope `json:"scopes"`
+
+	// UserId Owner of the key, defaults to the caller
+	UserId *int64 `json:"user_id,omitempty"`
+}
+
+// APIKeyList defines model for APIKeyList.
+type APIKeyList struct {
+	Items []APIKey `json:"items"`
+}
+
+// APIKeyScope defines model for APIKeyScope.
+type APIKeyScope string
+
+// APIKeyUpdateParams defines model for APIKeyUpdateParams.
+type APIKeyUpdateParams struct {
+	ExpiresAt *time.Time     `json:"expires_at,omitempty"`
+	Name      *string        `json:"name,omitempty"`
+	Scopes    *[]APIKeyScope `json:"scopes,omitempty"`
+}
+
+// CreatedAPIKey defines model for CreatedAPIKey.
+type CreatedAPIKey struct {
+	ApiKey APIKey `json:"api_key"`
+	Key    string `json:"key"`
+}
+
+// EmailVerification defines model for EmailVerification.
+type EmailVerification struct {
+	// Token Token sent to the email of the user
+	Token string `json:"token"`
+}
+
+// ExternalID defines model for ExternalID.
+type ExternalID struct {
+	// Id Identifier of the user in the source
+	Id string `json:"id"`
+
+	// Source System the identifier comes from, e.g. hr or crm
+	Source string `json:"source"`
+}
+
+// Health defines model for Health.
+type Health struct {
+	// Checks Results of the individual readiness checks.
+	Checks *[]HealthCheck `json:"checks,omitempty"`
+
+	// Status Overall readiness of the service: ready, not ready or draining.
+	Status string `json:"status"`
+}
+
+// HealthCheck defines model for HealthCheck.
+type HealthCheck struct {
+	// Error Why the check failed.
+	Error *string `json:"error,omitempty"`
+
+	// Name Name of the checked dependency.
+	Name string `json:"name"`
+
+	// Status Result of the check: ok or failed.
+	Status string `json:"status"`
+}
+
+// PhoneVerification defines model for PhoneVerification.
+type PhoneVerification struct {
+	// Code One-time code sent to the phone number of the user
+	Code string `json:"code"`
+}
+
 // User defines model for User.
 type User struct {
-	Address     string `json:"address"`
-	FirstName   string `json:"first_name"`
-	Id          int64  `json:"id"`
-	LastName    string `json:"last_name"`
-	PhoneNumber string `json:"phone_number"`
+	Address string `json:"address"`
+
+	// Attributes Custom fields of the user, matching the attributes schema of the service
+	Attributes map[string]interface{} `json:"attributes"`
+	CreatedAt  time.Time              `json:"created_at"`
+
+	// Email Email of the user, normalized to lower case
+	Email *string `json:"email,omitempty"`
+
+	// EmailVerifiedAt When the user verified the email, missing until then
+	EmailVerifiedAt *time.Time   `json:"email_verified_at,omitempty"`
+	ExternalIds     []ExternalID `json:"external_ids"`
+	FirstName       string       `json:"first_name"`
+	Id              int64        `json:"id"`
+	LastName        string       `json:"last_name"`
+	PhoneNumber     string       `json:"phone_number"`
+
+	// PhoneVerifiedAt When the user verified the phone number, missing until then
+	PhoneVerifiedAt *time.Time `json:"phone_verified_at,omitempty"`
+
+	// Status Stage of the lifecycle of the user. A pending user is activated, an active one can be suspended or locked (by operators) and activated again. Deleting a user sets the deleted status, restoring it gives back the status the user had before.
+	Status    UserStatus `json:"status"`
+	UpdatedAt time.Time  `json:"updated_at"`
 }
 
 // UserCreateParams defines model for UserCreateParams.
 type UserCreateParams struct {
-	Address     string `json:"address"`
-	FirstName   string `json:"first_name"`
-	LastName    string `json:"last_name"`
-	PhoneNumber string `json:"phone_number"`
+	Address string `json:"address"`
+
+	// Attributes Custom fields of the user, matching the attributes schema of the service
+	Attributes  *map[string]interface{} `json:"attributes,omitempty"`
+	Email       *string                 `json:"email,omitempty"`
+	ExternalIds *[]ExternalID           `json:"external_ids,omitempty"`
+	FirstName   string                  `json:"first_name"`
+	LastName    string                  `json:"last_name"`
+	PhoneNumber string                  `json:"phone_number"`
+
+	// Status Status of a new user, ignored when the user is replaced
+	Status *UserCreateParamsStatus `json:"status,omitempty"`
+}
+
+// UserCreateParamsStatus Status of a new user, ignored when the user is replaced
+type UserCreateParamsStatus string
+
+// UserList defines model for UserList.
+type UserList struct {
+	Items []User `json:"items"`
+}
+
+// UserStatus Stage of the lifecycle of the user. A pending user is activated, an active one can be suspended or locked (by operators) and activated again. Deleting a user sets the deleted status, restoring it gives back the status the user had before.
+type UserStatus string
+
+// UserStatusChange defines model for UserStatusChange.
+type UserStatusChange struct {
+	// Reason Why the status of the user is changed
+	Reason *string `json:"reason,omitempty"`
 }
 
 // UserUpdateParams defines model for UserUpdateParams.
 type UserUpdateParams struct {
-	Address     *string `json:"address,omitempty"`
-	FirstName   *string `json:"first_name,omitempty"`
-	LastName    *string `json:"last_name,omitempty"`
-	PhoneNumber *string `json:"phone_number,omitempty"`
+	Address *string `json:"address,omitempty"`
+
+	// Attributes JSON Merge Patch of the attributes, a null value removes an attribute
+	Attributes *map[string]interface{} `json:"attributes,omitempty"`
+
+	// Email New email of the user, which has to be verified again, an empty one removes it
+	Email *string `json:"email,omitempty"`
+
+	// ExternalIds Replace all the external IDs of the user
+	ExternalIds *[]ExternalID `json:"external_ids,omitempty"`
+	FirstName   *string       `json:"first_name,omitempty"`
+	LastName    *string       `json:"last_name,omitempty"`
+	PhoneNumber *string       `json:"phone_number,omitempty"`
+}
+
+// IdempotencyKey defines model for IdempotencyKey.
+type IdempotencyKey = string
+
+// ListAPIKeysParams defines parameters for ListAPIKeys.
+type ListAPIKeysParams struct {
+	// UserId Owner of the keys, defaults to the caller
+	UserId *int64 `form:"user_id,omitempty" json:"user_id,omitempty"`
+}
+
+// ListUsersParams defines parameters for ListUsers.
+type ListUsersParams struct {
+	// AfterId ID of the last user of the previous page
+	AfterId *int64 `form:"after_id,omitempty" json:"after_id,omitempty"`
+
+	// Limit Maximum number of users in the page
+	Limit *uint64 `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// IncludeDeleted Whether deleted users are listed too
+	IncludeDeleted *bool `form:"include_deleted,omitempty" json:"include_deleted,omitempty"`
+
+	// Attribute Condition key=value the attributes of the listed users must match, the value is taken as JSON if it's valid and as a string otherwise
+	Attribute *[]string `form:"attribute,omitempty" json:"attribute,omitempty"`
+}
+
+// CreateUserParams defines parameters for CreateUser.
+type CreateUserParams struct {
+	// IdempotencyKey Unique key of the request. A retry with the same key gets the stored response (marked with the Idempotent-Replayed header) instead of being executed again.
+	IdempotencyKey *IdempotencyKey `json:"Idempotency-Key,omitempty"`
 }
 
+// DeleteUserParams defines parameters for DeleteUser.
+type DeleteUserParams struct {
+	// IdempotencyKey Unique key of the request. A retry with the same key gets the stored response (marked with the Idempotent-Replayed header) instead of being executed again.
+	IdempotencyKey *IdempotencyKey `json:"Idempotency-Key,omitempty"`
+}
+
+// GetUserParams defines parameters for GetUser.
+type GetUserParams struct {
+	// IfNoneMatch ETag of the cached user, 304 is returned if it's still current
+	IfNoneMatch *string `json:"If-None-Match,omitempty"`
+
+	// IfModifiedSince Time the cached user was modified, ignored with If-None-Match
+	IfModifiedSince *string `json:"If-Modified-Since,omitempty"`
+}
+
+// UpsertUserByExternalIDParams defines parameters for UpsertUserByExternalID.
+type UpsertUserByExternalIDParams struct {
+	// IdempotencyKey Unique key of the request. A retry with the same key gets the stored response (marked with the Idempotent-Replayed header) instead of being executed again.
+	IdempotencyKey *IdempotencyKey `json:"Idempotency-Key,omitempty"`
+}
+
+// UpdateUserParams defines parameters for UpdateUser.
+type UpdateUserParams struct {
+	// IdempotencyKey Unique key of the request. A retry with the same key gets the stored response (marked with the Idempotent-Replayed header) instead of being executed again.
+	IdempotencyKey *IdempotencyKey `json:"Idempotency-Key,omitempty"`
+}
+
+// ReplaceUserParams defines parameters for ReplaceUser.
+type ReplaceUserParams struct {
+	// IdempotencyKey Unique key of the request. A retry with the same key gets the stored response (marked with the Idempotent-Replayed header) instead of being executed again.
+	IdempotencyKey *IdempotencyKey `json:"Idempotency-Key,omitempty"`
+}
+
+// WatchUsersParams defines parameters for WatchUsers.
+type WatchUsersParams struct {
+	// LastEventID ID of the last received event, the stream resumes right after it
+	LastEventID *int64 `json:"Last-Event-ID,omitempty"`
+}
+
+// CreateAPIKeyJSONRequestBody defines body for CreateAPIKey for application/json ContentType.
+type CreateAPIKeyJSONRequestBody = APIKeyCreateParams
+
+// UpdateAPIKeyJSONRequestBody defines body for UpdateAPIKey for application/json ContentType.
+type UpdateAPIKeyJSONRequestBody = APIKeyUpdateParams
+
 // CreateUserJSONRequestBody defines body for CreateUser for application/json ContentType.
 type CreateUserJSONRequestBody = UserCreateParams
 
+// UpsertUserByExternalIDJSONRequestBody defines body for UpsertUserByExternalID for application/json ContentType.
+type UpsertUserByExternalIDJSONRequestBody = UserCreateParams
+
 // UpdateUserJSONRequestBody defines body for UpdateUser for application/json ContentType.
 type UpdateUserJSONRequestBody = UserUpdateParams
+
+// ReplaceUserJSONRequestBody defines body for ReplaceUser for application/json ContentType.
+type ReplaceUserJSONRequestBody = UserCreateParams
+
+// SuspendUserJSONRequestBody defines body for SuspendUser for application/json ContentType.
+type SuspendUserJSONRequestBody = UserStatusChange
+
+// ActivateUserJSONRequestBody defines body for ActivateUser for application/json ContentType.
+type ActivateUserJSONRequestBody = UserStatusChange
+
+// VerifyEmailJSONRequestBody defines body for VerifyEmail for application/json ContentType.
+type VerifyEmailJSONRequestBody = EmailVerification
+
+// VerifyPhoneJSONRequestBody defines body for VerifyPhone for application/json ContentType.
+type VerifyPhoneJSONRequestBody = PhoneVerification
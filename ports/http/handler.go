@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"io/fs"
 	"net/http"
+	"net/netip"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -14,6 +16,8 @@ import (
 	"github.com/torwig/user-service/entities"
 	"github.com/torwig/user-service/ports/http/requests"
 	"github.com/torwig/user-service/ports/http/responses"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -26,24 +30,91 @@ var (
 var docsFS embed.FS
 
 type UserService interface {
+	UserStatusChecker
 	CreateUser(ctx context.Context, params entities.CreateUserParams) (entities.User, error)
 	GetUser(ctx context.Context, id int64) (entities.User, error)
+	GetUserByExternalID(ctx context.Context, source, externalID string) (entities.User, error)
+	ListUsers(ctx context.Context, params entities.ListUsersParams) ([]entities.User, error)
 	UpdateUser(ctx context.Context, id int64, params entities.UpdateUserParams) (entities.User, error)
-	DeleteUser(ctx context.Context, id int64) error
+	ReplaceUser(ctx context.Context, id int64, params entities.ReplaceUserParams) (entities.User, error)
+	UpsertUser(
+		ctx context.Context,
+		externalID entities.ExternalID,
+		params entities.ReplaceUserParams,
+	) (entities.User, bool, error)
+	DeleteUser(ctx context.Context, id int64, cause entities.StatusChangeCause) error
+	SendEmailVerification(ctx context.Context, id int64) error
+	VerifyEmail(ctx context.Context, id int64, token string) (entities.User, error)
+	SendPhoneVerification(ctx context.Context, id int64) error
+	VerifyPhone(ctx context.Context, id int64, code string) (entities.User, error)
+	ChangeUserStatus(ctx context.Context, id int64, params entities.ChangeUserStatusParams) (entities.User, error)
+}
+
+type APIKeyService interface {
+	APIKeyAuthenticator
+	CreateAPIKey(ctx context.Context, params entities.CreateAPIKeyParams) (entities.APIKey, string, error)
+	GetAPIKey(ctx context.Context, id int64) (entities.APIKey, error)
+	ListAPIKeys(ctx context.Context, userID int64) ([]entities.APIKey, error)
+	UpdateAPIKey(ctx context.Context, id int64, params entities.UpdateAPIKeyParams) (entities.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64) error
 }
 
 type UserAuthenticator interface {
 	ParseAccessToken(t string) (*entities.AuthenticatedUser, error)
 }
 
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (*entities.AuthenticatedUser, error)
+}
+
+type UserStatusChecker interface {
+	// CheckUserStatus returns entities.ErrUserSuspended if the user may not use their credentials.
+	CheckUserStatus(ctx context.Context, id int64) error
+}
+
 type Handler struct {
-	svc  UserService
-	auth UserAuthenticator
-	log  *zap.SugaredLogger
+	svc         UserService
+	keySvc      APIKeyService
+	auth        UserAuthenticator
+	certAuth    *ClientCertAuthenticator
+	rateLimiter *RateLimiter
+	log         *zap.SugaredLogger
+
+	idempotencyStore  IdempotencyStore
+	idempotencyKeyTTL time.Duration
+	requestObserver   RequestObserver
+	tracer            trace.Tracer
+	propagator        propagation.TextMapPropagator
+
+	accessLogSampleRatio float64
+	readiness            *Readiness
+	userChanges          UserChangeFeed
+	trustedProxies       []netip.Prefix
 }
 
-func NewHandler(userSvc UserService, userAuth UserAuthenticator, log *zap.SugaredLogger) *Handler {
-	return &Handler{svc: userSvc, auth: userAuth, log: log}
+type HandlerOption func(h *Handler)
+
+// WithClientCertAuthenticator lets internal callers authenticate with client certificates.
+func WithClientCertAuthenticator(certAuth *ClientCertAuthenticator) HandlerOption {
+	return func(h *Handler) {
+		h.certAuth = certAuth
+	}
+}
+
+func NewHandler(
+	userSvc UserService,
+	keySvc APIKeyService,
+	userAuth UserAuthenticator,
+	log *zap.SugaredLogger,
+	options ...HandlerOption,
+) *Handler {
+	h := &Handler{svc: userSvc, keySvc: keySvc, auth: userAuth, log: log, accessLogSampleRatio: 1}
+
+	for _, o := range options {
+		o(h)
+	}
+
+	return h
 }
 
 func (h *Handler) Router() http.Handler {
@@ -53,29 +124,77 @@ func (h *Handler) Router() http.Handler {
 	}
 
 	r := chi.NewRouter()
+	r.Use(h.traceRequests)
+	r.Use(h.logRequests)
+	r.Use(h.observeRequests)
 	r.Use(middleware.Recoverer)
 
 	r.Handle("/docs/*", http.StripPrefix("/docs/", http.FileServer(http.FS(docsAsRootFS))))
 
-	r.Get("/health", h.healthcheck)
+	r.Get("/livez", h.livez)
+	r.Get("/readyz", h.readyz)
+	// deprecated, kept for the probes configured before /livez was added
+	r.Get("/health", h.livez)
 
 	r.Route("/api/v1/users", func(r chi.Router) {
-		r.Use(BearerTokenAuthentication(h.auth))
+		h.useAuthentication(r)
 
-		r.Post("/", h.createUser)
+		r.With(h.rateLimiting).Get("/", h.listUsers)
+		r.With(h.rateLimiting, h.idempotency).Post("/", h.createUser)
+		r.With(h.rateLimiting).Get("/by-external/{source}/{externalID}", h.getUserByExternalID)
+		r.With(h.rateLimiting, h.idempotency).Put("/by-external/{source}/{externalID}", h.upsertUserByExternalID)
 
 		r.Route("/{id}", func(r chi.Router) {
+			r = r.With(h.rateLimiting)
+
 			r.Get("/", h.getUser)
-			r.Patch("/", h.updateUser)
-			r.Delete("/", h.deleteUser)
+			r.With(h.idempotency).Patch("/", h.updateUser)
+			r.With(h.idempotency).Put("/", h.replaceUser)
+			r.With(h.idempotency).Delete("/", h.deleteUser)
+			r.Post("/email/verification", h.sendEmailVerification)
+			r.Post("/email/verify", h.verifyEmail)
+			r.Post("/phone/verification", h.sendPhoneVerification)
+			r.Post("/phone/verify", h.verifyPhone)
+		})
+
+		r.With(h.rateLimiting).Post("/{id}:suspend", h.suspendUser)
+		r.With(h.rateLimiting).Post("/{id}:activate", h.activateUser)
+	})
+
+	if h.userChanges != nil {
+		r.Group(func(r chi.Router) {
+			h.useAuthentication(r)
+
+			r.With(h.rateLimiting).Get("/api/v1/users:watch", h.watchUsers)
+		})
+	}
+
+	r.Route("/api/v1/api-keys", func(r chi.Router) {
+		h.useAuthentication(r)
+
+		r.With(h.rateLimiting).Get("/", h.listAPIKeys)
+		r.With(h.rateLimiting).Post("/", h.createAPIKey)
+
+		r.Route("/{id}", func(r chi.Router) {
+			r = r.With(h.rateLimiting)
+
+			r.Get("/", h.getAPIKey)
+			r.Patch("/", h.updateAPIKey)
+			r.Delete("/", h.revokeAPIKey)
 		})
 	})
 
 	return r
 }
 
-func (h *Handler) healthcheck(w http.ResponseWriter, _ *http.Request) {
-	w.WriteHeader(http.StatusOK)
+func (h *Handler) useAuthentication(r chi.Router) {
+	r.Use(h.failedAuthLimiting)
+
+	if h.certAuth != nil {
+		r.Use(ClientCertificateAuthentication(h.certAuth))
+	}
+
+	r.Use(Authentication(h.auth, h.keySvc, h.svc))
 }
 
 func (h *Handler) createUser(w http.ResponseWriter, r *http.Request) {
@@ -98,9 +217,17 @@ func (h *Handler) createUser(w http.ResponseWriter, r *http.Request) {
 
 	createdUser, err := h.svc.CreateUser(r.Context(), req.ToCreateUserParams())
 	if err != nil {
-		h.log.Errorf("failed to create user: %s", err)
+		h.logger(r).Errorf("failed to create user: %s", err)
+
+		switch {
+		case errors.Is(err, entities.ErrExternalIDTaken), errors.Is(err, entities.ErrEmailTaken):
+			w.WriteHeader(http.StatusConflict)
+		case errors.Is(err, entities.ErrInvalidAttributes):
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
 
-		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
@@ -108,7 +235,7 @@ func (h *Handler) createUser(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) getUser(w http.ResponseWriter, r *http.Request) {
-	id, err := userIdentifierFromRequestURL(r)
+	id, err := identifierFromRequestURL(r)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
@@ -127,7 +254,7 @@ func (h *Handler) getUser(w http.ResponseWriter, r *http.Request) {
 
 	user, err := h.svc.GetUser(r.Context(), id)
 	if err != nil {
-		h.log.Errorf("failed to get user %d: %s", id, err)
+		h.logger(r).Errorf("failed to get user %d: %s", id, err)
 
 		if errors.Is(err, entities.ErrUserNotFound) {
 			w.WriteHeader(http.StatusNotFound)
@@ -138,11 +265,99 @@ func (h *Handler) getUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	response := responses.UserFromEntity(user)
+
+	etag, err := representationETag(response)
+	if err != nil {
+		h.logger(r).Errorf("failed to get user %d: %s", id, err)
+
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	setValidators(w, etag, user.UpdatedAt)
+
+	if notModified(r, etag, user.UpdatedAt) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	responses.SendJSON(w, http.StatusOK, response)
+}
+
+// listUsers returns a page of users ordered by ID to those who may view any user.
+func (h *Handler) listUsers(w http.ResponseWriter, r *http.Request) {
+	au, err := AuthenticatedUserFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if !au.CanListUsers() {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	req, err := requests.NewListUsers(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	users, err := h.svc.ListUsers(r.Context(), req.ToListUsersParams())
+	if err != nil {
+		h.logger(r).Errorf("failed to list users: %s", err)
+
+		if errors.Is(err, entities.ErrInvalidAttributeFilter) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	responses.SendJSON(w, http.StatusOK, responses.UserListFromEntities(users))
+}
+
+// getUserByExternalID looks the user up by an ID of another system. A user the caller can't view
+// is reported as not found, so that the IDs of other systems can't be probed.
+func (h *Handler) getUserByExternalID(w http.ResponseWriter, r *http.Request) {
+	source, externalID := chi.URLParam(r, "source"), chi.URLParam(r, "externalID")
+	if requests.ValidateExternalIDSource(source) != nil || externalID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	au, err := AuthenticatedUserFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.svc.GetUserByExternalID(r.Context(), source, externalID)
+	if err != nil {
+		h.logger(r).Errorf("failed to get user by external ID %q of %q: %s", externalID, source, err)
+
+		if errors.Is(err, entities.ErrUserNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	if !au.CanViewUser(user.ID) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
 	responses.SendJSON(w, http.StatusOK, responses.UserFromEntity(user))
 }
 
 func (h *Handler) updateUser(w http.ResponseWriter, r *http.Request) {
-	id, err := userIdentifierFromRequestURL(r)
+	id, err := identifierFromRequestURL(r)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
@@ -159,15 +374,140 @@ func (h *Handler) updateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	req, err := requests.NewUpdateUser(r)
+	req, ok := h.updateUserRequest(w, r, id)
+	if !ok {
+		return
+	}
+
+	updatedUser, err := h.svc.UpdateUser(r.Context(), id, req.ToUpdateUserParams())
 	if err != nil {
+		h.logger(r).Errorf("failed to update user %d: %s", id, err)
+
+		switch {
+		case errors.Is(err, entities.ErrUserNotFound):
+			w.WriteHeader(http.StatusNotFound)
+		case errors.Is(err, entities.ErrExternalIDTaken), errors.Is(err, entities.ErrEmailTaken):
+			w.WriteHeader(http.StatusConflict)
+		case errors.Is(err, entities.ErrInvalidAttributes):
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	responses.SendJSON(w, http.StatusOK, responses.UserFromEntity(updatedUser))
+}
+
+// replaceUser sets all the fields of the user.
+func (h *Handler) replaceUser(w http.ResponseWriter, r *http.Request) {
+	id, err := identifierFromRequestURL(r)
+	if err != nil || id <= 0 {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	updatedUser, err := h.svc.UpdateUser(r.Context(), id, req.ToUpdateUserParams())
+	au, err := AuthenticatedUserFromRequest(r)
 	if err != nil {
-		h.log.Errorf("failed to update user %d: %s", id, err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if !au.CanUpdateUser(id) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	req, err := requests.NewReplaceUser(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.svc.ReplaceUser(r.Context(), id, req.ToReplaceUserParams())
+	if err != nil {
+		h.logger(r).Errorf("failed to replace user %d: %s", id, err)
+		writeReplaceError(w, err)
+
+		return
+	}
+
+	responses.SendJSON(w, http.StatusOK, responses.UserFromEntity(user))
+}
+
+// upsertUserByExternalID sets all the fields of the user the external ID belongs to,
+// a new user is created with the external ID if there is none.
+func (h *Handler) upsertUserByExternalID(w http.ResponseWriter, r *http.Request) {
+	externalID := entities.ExternalID{Source: chi.URLParam(r, "source"), ID: chi.URLParam(r, "externalID")}
+	if requests.ValidateExternalID(externalID.Source, externalID.ID) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	au, err := AuthenticatedUserFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if !au.CanUpsert() {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	req, err := requests.NewReplaceUser(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	user, created, err := h.svc.UpsertUser(r.Context(), externalID, req.ToReplaceUserParams())
+	if err != nil {
+		h.logger(r).Errorf("failed to upsert user %s/%s: %s", externalID.Source, externalID.ID, err)
+		writeReplaceError(w, err)
+
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+
+	responses.SendJSON(w, status, responses.UserFromEntity(user))
+}
+
+func writeReplaceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, entities.ErrUserNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, entities.ErrUserDeleted), errors.Is(err, entities.ErrExternalIDTaken),
+		errors.Is(err, entities.ErrEmailTaken):
+		w.WriteHeader(http.StatusConflict)
+	case errors.Is(err, entities.ErrInvalidAttributes):
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// updateUserRequest decodes the fields to update, either given as they are or as a patch of the current user.
+// It responds with an error itself if the request can't be decoded.
+func (h *Handler) updateUserRequest(w http.ResponseWriter, r *http.Request, id int64) (requests.UpdateUser, bool) {
+	if !requests.IsPatch(r) {
+		req, err := requests.NewUpdateUser(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return req, false
+		}
+
+		return req, true
+	}
+
+	user, err := h.svc.GetUser(r.Context(), id)
+	if err != nil {
+		h.logger(r).Errorf("failed to get user %d to patch: %s", id, err)
 
 		if errors.Is(err, entities.ErrUserNotFound) {
 			w.WriteHeader(http.StatusNotFound)
@@ -175,14 +515,33 @@ func (h *Handler) updateUser(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusInternalServerError)
 		}
 
-		return
+		return requests.UpdateUser{}, false
 	}
 
-	responses.SendJSON(w, http.StatusOK, responses.UserFromEntity(updatedUser))
+	req, err := requests.NewPatchUser(r, responses.UserFromEntity(user))
+
+	switch {
+	case err == nil:
+		return req, true
+	case errors.Is(err, requests.ErrRequestBodyDecodingFailed):
+		w.WriteHeader(http.StatusBadRequest)
+	case errors.Is(err, requests.ErrPatchTestFailed):
+		w.WriteHeader(http.StatusConflict)
+	case errors.Is(err, requests.ErrPatchNotApplicable), errors.Is(err, requests.ErrReadOnlyField),
+		errors.Is(err, requests.ErrEmptyRequestField), errors.Is(err, requests.ErrInvalidExternalID),
+		errors.Is(err, requests.ErrDuplicateExternalID), errors.Is(err, requests.ErrInvalidEmail):
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	default:
+		h.logger(r).Errorf("failed to patch user %d: %s", id, err)
+
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	return req, false
 }
 
 func (h *Handler) deleteUser(w http.ResponseWriter, r *http.Request) {
-	id, err := userIdentifierFromRequestURL(r)
+	id, err := identifierFromRequestURL(r)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
@@ -199,9 +558,9 @@ func (h *Handler) deleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.svc.DeleteUser(r.Context(), id)
+	err = h.svc.DeleteUser(r.Context(), id, entities.StatusChangeCause{Actor: fmt.Sprintf("user:%d", au.ID())})
 	if err != nil {
-		h.log.Errorf("failed to delete user %d: %s", id, err)
+		h.logger(r).Errorf("failed to delete user %d: %s", id, err)
 
 		if errors.Is(err, entities.ErrUserNotFound) {
 			w.WriteHeader(http.StatusNotFound)
@@ -215,7 +574,7 @@ func (h *Handler) deleteUser(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func userIdentifierFromRequestURL(r *http.Request) (int64, error) {
+func identifierFromRequestURL(r *http.Request) (int64, error) {
 	idStr := chi.URLParam(r, "id")
 	if idStr == "" {
 		return 0, errEmptyParameter
@@ -0,0 +1,111 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/torwig/user-service/ports/http/generated"
+	"github.com/torwig/user-service/ports/http/responses"
+)
+
+const (
+	readinessCheckTimeout = 2 * time.Second
+
+	healthStatusReady    = "ready"
+	healthStatusNotReady = "not ready"
+	healthStatusDraining = "draining"
+)
+
+// ReadinessCheck checks a dependency the service can't serve requests without.
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// Readiness decides whether the service should receive traffic. It's shared by the handler,
+// which reports it, and the server, which drains it on shutdown.
+type Readiness struct {
+	checks   []ReadinessCheck
+	draining atomic.Bool
+}
+
+func NewReadiness(checks ...ReadinessCheck) *Readiness {
+	return &Readiness{checks: checks}
+}
+
+// Drain makes the service report not ready from now on.
+func (rd *Readiness) Drain() {
+	rd.draining.Store(true)
+}
+
+func (rd *Readiness) IsDraining() bool {
+	return rd.draining.Load()
+}
+
+// Check runs all the checks concurrently and reports whether every one of them passed.
+func (rd *Readiness) Check(ctx context.Context) (bool, []generated.HealthCheck) {
+	results := make([]generated.HealthCheck, len(rd.checks))
+	ready := atomic.Bool{}
+	ready.Store(true)
+
+	var wg sync.WaitGroup
+
+	for i, check := range rd.checks {
+		wg.Add(1)
+
+		go func(i int, check ReadinessCheck) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+			defer cancel()
+
+			err := check.Check(checkCtx)
+			if err != nil {
+				ready.Store(false)
+			}
+
+			results[i] = responses.HealthCheckResult(check.Name, err)
+		}(i, check)
+	}
+
+	wg.Wait()
+
+	return ready.Load(), results
+}
+
+// WithReadiness reports the readiness of the service at /readyz.
+func WithReadiness(rd *Readiness) HandlerOption {
+	return func(h *Handler) {
+		h.readiness = rd
+	}
+}
+
+// livez reports that the process is up and able to handle requests.
+func (h *Handler) livez(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) readyz(w http.ResponseWriter, r *http.Request) {
+	if h.readiness == nil {
+		responses.SendJSON(w, http.StatusOK, responses.Health(healthStatusReady, nil))
+		return
+	}
+
+	if h.readiness.IsDraining() {
+		responses.SendJSON(w, http.StatusServiceUnavailable, responses.Health(healthStatusDraining, nil))
+		return
+	}
+
+	ready, checks := h.readiness.Check(r.Context())
+	if !ready {
+		h.logger(r).Warnw("service isn't ready", "checks", checks)
+		responses.SendJSON(w, http.StatusServiceUnavailable, responses.Health(healthStatusNotReady, checks))
+
+		return
+	}
+
+	responses.SendJSON(w, http.StatusOK, responses.Health(healthStatusReady, checks))
+}
@@ -0,0 +1,64 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	userhttp "github.com/torwig/user-service/ports/http"
+	"github.com/torwig/user-service/ports/http/generated"
+	"go.uber.org/zap"
+)
+
+func TestHandler_Readyz(t *testing.T) {
+	var dbErr error
+
+	readiness := userhttp.NewReadiness(
+		userhttp.ReadinessCheck{Name: "postgres", Check: func(context.Context) error { return dbErr }},
+		userhttp.ReadinessCheck{Name: "migrations", Check: func(context.Context) error { return nil }},
+	)
+
+	router := userhttp.NewHandler(stubUserService{}, stubAPIKeyService{}, stubAuthenticator{},
+		zap.NewNop().Sugar(), userhttp.WithReadiness(readiness)).Router()
+
+	readyz := func() (int, generated.Health) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		var health generated.Health
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&health))
+
+		return w.Code, health
+	}
+
+	code, health := readyz()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready", health.Status)
+
+	dbErr = errors.New("connection refused")
+
+	code, health = readyz()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not ready", health.Status)
+	require.NotNil(t, health.Checks)
+	require.Len(t, *health.Checks, 2)
+	assert.Equal(t, "failed", (*health.Checks)[0].Status)
+	assert.Equal(t, "connection refused", *(*health.Checks)[0].Error)
+	assert.Equal(t, "ok", (*health.Checks)[1].Status)
+
+	dbErr = nil
+	readiness.Drain()
+
+	code, health = readyz()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "draining", health.Status)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,202 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/torwig/user-service/entities"
+)
+
+const (
+	idempotencyKeyHeader         = "Idempotency-Key"
+	idempotentReplayedHeader     = "Idempotent-Replayed"
+	maxIdempotencyKeyLength      = 255
+	maxIdempotentRequestBodySize = 1 << 20
+	// idempotencyReservationLease is how long a key stays reserved for a request that is still in progress,
+	// so that a key reserved by a crashed instance becomes free again long before the completed key TTL.
+	idempotencyReservationLease = time.Minute
+)
+
+type IdempotencyStore interface {
+	// ReserveIdempotencyKey stores an in-progress record that expires after the lease for a free (or expired) key
+	// and returns true, otherwise it returns the record that holds the key.
+	ReserveIdempotencyKey(
+		ctx context.Context,
+		key string,
+		fingerprint string,
+		lease time.Duration,
+	) (entities.IdempotencyRecord, bool, error)
+	// CompleteIdempotencyKey stores the response and keeps the key for the given time.
+	CompleteIdempotencyKey(
+		ctx context.Context,
+		key string,
+		statusCode int,
+		headers map[string][]string,
+		body []byte,
+		ttl time.Duration,
+	) error
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+}
+
+// WithIdempotencyStore enables Idempotency-Key support for endpoints that change users,
+// the keys are kept for the given time.
+func WithIdempotencyStore(store IdempotencyStore, ttl time.Duration) HandlerOption {
+	return func(h *Handler) {
+		h.idempotencyStore = store
+		h.idempotencyKeyTTL = ttl
+	}
+}
+
+// idempotency replays the stored response to a request retried with the same Idempotency-Key.
+// It has to wrap endpoint handlers (rather than routers) to see the complete route pattern.
+func (h *Handler) idempotency(next http.Handler) http.Handler {
+	if h.idempotencyStore == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idempotencyKey := r.Header.Get(idempotencyKeyHeader)
+		if idempotencyKey == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(idempotencyKey) > maxIdempotencyKeyLength {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentRequestBodySize))
+		if err != nil {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		// keys are scoped to the caller, so that nobody can see a response meant for somebody else
+		caller, _ := h.identifyCaller(r)
+		key := caller + "|" + idempotencyKey
+
+		record, reserved, err := h.idempotencyStore.ReserveIdempotencyKey(
+			r.Context(), key, requestFingerprint(r, body), idempotencyReservationLease)
+		if err != nil {
+			h.logger(r).Errorf("failed to reserve idempotency key %q: %s", key, err)
+
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if !reserved {
+			h.replayIdempotentResponse(w, r, record, body)
+			return
+		}
+
+		headersBefore := w.Header().Clone()
+
+		var responseBody bytes.Buffer
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(&responseBody)
+
+		// the outcome has to be saved even if the client has gone already
+		ctx := context.WithoutCancel(r.Context())
+
+		defer func() {
+			if p := recover(); p != nil {
+				// the request has failed, it can be retried with the same key
+				h.releaseIdempotencyKey(ctx, r, key)
+
+				panic(p)
+			}
+		}()
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		if status >= http.StatusInternalServerError {
+			// failed requests can be retried with the same key
+			h.releaseIdempotencyKey(ctx, r, key)
+
+			return
+		}
+
+		err = h.idempotencyStore.CompleteIdempotencyKey(ctx, key, status,
+			headersSetBy(w.Header(), headersBefore), responseBody.Bytes(), h.idempotencyKeyTTL)
+		if err != nil {
+			h.logger(r).Errorf("failed to store response for idempotency key %q: %s", key, err)
+		}
+	})
+}
+
+func (h *Handler) releaseIdempotencyKey(ctx context.Context, r *http.Request, key string) {
+	if err := h.idempotencyStore.ReleaseIdempotencyKey(ctx, key); err != nil {
+		h.logger(r).Errorf("failed to release idempotency key %q: %s", key, err)
+	}
+}
+
+func (h *Handler) replayIdempotentResponse(
+	w http.ResponseWriter,
+	r *http.Request,
+	record entities.IdempotencyRecord,
+	body []byte,
+) {
+	if record.Fingerprint != requestFingerprint(r, body) {
+		// the same key was used for a different request
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	if !record.IsCompleted() {
+		// the original request is still being processed
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusConflict)
+
+		return
+	}
+
+	for name, values := range record.ResponseHeaders {
+		w.Header()[name] = values
+	}
+
+	w.Header().Set(idempotentReplayedHeader, strconv.FormatBool(true))
+	w.WriteHeader(record.StatusCode)
+	_, _ = w.Write(record.ResponseBody)
+}
+
+// requestFingerprint identifies a request by its method, route, URL, content type and body.
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+
+	hash.Write([]byte(r.Method + " " + chi.RouteContext(r.Context()).RoutePattern() + " " + r.URL.RequestURI() + "\n"))
+	hash.Write([]byte(r.Header.Get("Content-Type") + "\n"))
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// headersSetBy returns the headers that were added after the snapshot was taken,
+// e.g. rate limit headers set by an outer middleware aren't stored.
+func headersSetBy(headers, snapshot http.Header) map[string][]string {
+	result := make(map[string][]string)
+
+	for name, values := range headers {
+		if _, ok := snapshot[name]; !ok {
+			result[name] = values
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,158 @@
+package http_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torwig/user-service/entities"
+	userhttp "github.com/torwig/user-service/ports/http"
+	"go.uber.org/zap"
+)
+
+type fakeIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]entities.IdempotencyRecord
+}
+
+func (s *fakeIdempotencyStore) ReserveIdempotencyKey(
+	_ context.Context,
+	key string,
+	fingerprint string,
+	lease time.Duration,
+) (entities.IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[key]; ok && time.Now().Before(record.ExpiresAt) {
+		return record, false, nil
+	}
+
+	record := entities.IdempotencyRecord{Key: key, Fingerprint: fingerprint, ExpiresAt: time.Now().Add(lease)}
+	s.records[key] = record
+
+	return record, true, nil
+}
+
+func (s *fakeIdempotencyStore) CompleteIdempotencyKey(
+	_ context.Context,
+	key string,
+	statusCode int,
+	headers map[string][]string,
+	body []byte,
+	ttl time.Duration,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record := s.records[key]
+	record.StatusCode = statusCode
+	record.ResponseHeaders = headers
+	record.ResponseBody = body
+	record.ExpiresAt = time.Now().Add(ttl)
+	s.records[key] = record
+
+	return nil
+}
+
+func (s *fakeIdempotencyStore) ReleaseIdempotencyKey(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+
+	return nil
+}
+
+type countingUserService struct {
+	stubUserService
+	created int
+	panics  bool
+}
+
+func (s *countingUserService) CreateUser(ctx context.Context, params entities.CreateUserParams) (entities.User, error) {
+	if s.panics {
+		s.panics = false
+		panic("failed to create user")
+	}
+
+	s.created++
+
+	return s.stubUserService.CreateUser(ctx, params)
+}
+
+func TestHandler_Idempotency(t *testing.T) {
+	svc := &countingUserService{}
+	store := &fakeIdempotencyStore{records: make(map[string]entities.IdempotencyRecord)}
+
+	router := userhttp.NewHandler(svc, stubAPIKeyService{},
+		stubAuthenticator{user: entities.NewAuthenticatedUser(1, entities.CreateUsersGranted())},
+		zap.NewNop().Sugar(), userhttp.WithIdempotencyStore(store, time.Hour)).Router()
+
+	createUserAs := func(contentType, idempotencyKey, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/users", strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer token")
+		r.Header.Set("Content-Type", contentType)
+		r.Header.Set("Idempotency-Key", idempotencyKey)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		return w
+	}
+
+	createUser := func(idempotencyKey, body string) *httptest.ResponseRecorder {
+		return createUserAs("application/json", idempotencyKey, body)
+	}
+
+	body := `{"first_name":"John","last_name":"Doe","phone_number":"+1234567890","address":"Springfield"}`
+
+	first := createUser("key-1", body)
+	require.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+
+	t.Run("Retry replays the stored response", func(t *testing.T) {
+		retry := createUser("key-1", body)
+		require.Equal(t, http.StatusCreated, retry.Code)
+		assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+		assert.Equal(t, "application/json", retry.Header().Get("Content-Type"))
+		assert.Equal(t, first.Body.String(), retry.Body.String())
+		assert.Equal(t, 1, svc.created)
+	})
+
+	t.Run("Same key with another body", func(t *testing.T) {
+		w := createUser("key-1", strings.Replace(body, "John", "Jane", 1))
+		require.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, 1, svc.created)
+	})
+
+	t.Run("Same key with another content type", func(t *testing.T) {
+		w := createUserAs("text/plain", "key-1", body)
+		require.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, 1, svc.created)
+	})
+
+	t.Run("Another key", func(t *testing.T) {
+		w := createUser("key-2", body)
+		require.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, 2, svc.created)
+	})
+
+	t.Run("Panic releases the key", func(t *testing.T) {
+		svc.panics = true
+
+		w := createUser("key-3", body)
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+
+		w = createUser("key-3", body)
+		require.Equal(t, http.StatusCreated, w.Code)
+		assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+		assert.Equal(t, 3, svc.created)
+	})
+}
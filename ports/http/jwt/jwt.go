@@ -1,6 +1,9 @@
 package jwt
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/pkg/errors"
 	"github.com/torwig/user-service/entities"
@@ -13,8 +16,17 @@ var (
 	ErrUnexpectedClaims        = errors.New("unexpected claims received")
 	ErrInvalidAccessToken      = errors.New("invalid access token")
 	ErrUnexpectedSigningMethod = errors.New("unexpected signing method")
+	ErrEmptySecretKey          = errors.New("secret key is empty")
 )
 
+// Permissions are the flags of the access token granting access to other users.
+type Permissions struct {
+	CreateUsers bool
+	ViewUsers   bool
+	UpdateUsers bool
+	DeleteUsers bool
+}
+
 type authClaims struct {
 	jwt.RegisteredClaims
 	UserID         int64 `json:"user_id"`
@@ -37,7 +49,65 @@ func NewAuthenticator(cfg Config) *Authenticator {
 	return &Authenticator{cfg: cfg}
 }
 
+// IssueAccessToken mints a token the authenticator accepts, e.g. for local development and tests.
+func (a *Authenticator) IssueAccessToken(userID int64, permissions Permissions, ttl time.Duration) (string, error) {
+	if len(a.cfg.SecretKey) == 0 {
+		return "", ErrEmptySecretKey
+	}
+
+	now := time.Now()
+
+	claims := authClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "user_authentication",
+			Issuer:    a.cfg.Issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		UserID:         userID,
+		CanCreateUsers: permissions.CreateUsers,
+		CanDeleteUsers: permissions.DeleteUsers,
+		CanUpdateUsers: permissions.UpdateUsers,
+		CanViewUsers:   permissions.ViewUsers,
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(a.cfg.SecretKey)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to sign access token")
+	}
+
+	return token, nil
+}
+
 func (a *Authenticator) ParseAccessToken(t string) (*entities.AuthenticatedUser, error) {
+	claims, err := a.parseClaims(t)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := make([]entities.UserPermission, 0, maxNumberOfPermissions)
+
+	if claims.CanCreateUsers {
+		permissions = append(permissions, entities.CreateUsersGranted())
+	}
+	if claims.CanViewUsers {
+		permissions = append(permissions, entities.ViewUsersGranted())
+	}
+	if claims.CanUpdateUsers {
+		permissions = append(permissions, entities.UpdateUsersGranted())
+	}
+	if claims.CanDeleteUsers {
+		permissions = append(permissions, entities.DeleteUsersGranted())
+	}
+
+	au := entities.NewAuthenticatedUser(claims.UserID, permissions...)
+
+	return au, nil
+}
+
+// parseClaims validates the token. The returned errors match the exported ones with errors.Is
+// and carry the exact reason of the rejection, e.g. an expired token.
+func (a *Authenticator) parseClaims(t string) (*authClaims, error) {
 	token, err := jwt.ParseWithClaims(t, &authClaims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, ErrUnexpectedSigningMethod
@@ -45,14 +115,18 @@ func (a *Authenticator) ParseAccessToken(t string) (*entities.AuthenticatedUser,
 
 		return a.cfg.SecretKey, nil
 	})
-	if err != nil || !token.Valid {
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAccessToken, err)
+	}
+
+	if !token.Valid {
 		return nil, ErrInvalidAccessToken
 	}
 
 	if a.cfg.Issuer != "" {
 		iss, issErr := token.Claims.GetIssuer()
 		if issErr != nil || iss != a.cfg.Issuer {
-			return nil, ErrUnexpectedIssuer
+			return nil, fmt.Errorf("%w: got %q, expected %q", ErrUnexpectedIssuer, iss, a.cfg.Issuer)
 		}
 	}
 
@@ -61,22 +135,31 @@ func (a *Authenticator) ParseAccessToken(t string) (*entities.AuthenticatedUser,
 		return nil, ErrUnexpectedClaims
 	}
 
-	permissions := make([]entities.UserPermission, 0, maxNumberOfPermissions)
+	return claims, nil
+}
 
-	if claims.CanCreateUsers {
-		permissions = append(permissions, entities.CreateUsersGranted())
-	}
-	if claims.CanViewUsers {
-		permissions = append(permissions, entities.ViewUsersGranted())
-	}
-	if claims.CanUpdateUsers {
-		permissions = append(permissions, entities.UpdateUsersGranted())
-	}
-	if claims.CanDeleteUsers {
-		permissions = append(permissions, entities.DeleteUsersGranted())
+// InspectAccessToken decodes the header and the claims of the token without verifying it
+// and validates it separately, so that the reason a token is rejected can be explained.
+func (a *Authenticator) InspectAccessToken(t string) (TokenInspection, error) {
+	var inspection TokenInspection
+
+	token, _, err := jwt.NewParser().ParseUnverified(t, jwt.MapClaims{})
+	if err != nil {
+		return inspection, errors.Wrap(err, "failed to decode access token")
 	}
 
-	au := entities.NewAuthenticatedUser(claims.UserID, permissions...)
+	inspection.Header = token.Header
+	inspection.Claims, _ = token.Claims.(jwt.MapClaims)
+	inspection.User, inspection.Rejection = a.ParseAccessToken(t)
 
-	return au, nil
+	return inspection, nil
+}
+
+type TokenInspection struct {
+	Header map[string]interface{}
+	Claims map[string]interface{}
+	// User is the authenticated user if the token is valid.
+	User *entities.AuthenticatedUser
+	// Rejection is the reason the token isn't accepted, nil if it's valid.
+	Rejection error
 }
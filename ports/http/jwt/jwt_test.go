@@ -0,0 +1,46 @@
+package jwt_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torwig/user-service/ports/http/jwt"
+)
+
+func TestAuthenticator_IssueAccessToken(t *testing.T) {
+	authenticator := jwt.NewAuthenticator(jwt.Config{SecretKey: []byte("secret"), Issuer: "localhost"})
+
+	token, err := authenticator.IssueAccessToken(42, jwt.Permissions{ViewUsers: true}, time.Minute)
+	require.NoError(t, err)
+
+	au, err := authenticator.ParseAccessToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), au.ID())
+	assert.True(t, au.CanViewUser(1))
+	assert.False(t, au.CanCreate())
+
+	t.Run("Expired token", func(t *testing.T) {
+		expired, err := authenticator.IssueAccessToken(42, jwt.Permissions{}, -time.Minute)
+		require.NoError(t, err)
+
+		inspection, err := authenticator.InspectAccessToken(expired)
+		require.NoError(t, err)
+		assert.Equal(t, float64(42), inspection.Claims["user_id"])
+		require.ErrorIs(t, inspection.Rejection, jwt.ErrInvalidAccessToken)
+		assert.Contains(t, inspection.Rejection.Error(), "expired")
+	})
+
+	t.Run("Other issuer", func(t *testing.T) {
+		other := jwt.NewAuthenticator(jwt.Config{SecretKey: []byte("secret"), Issuer: "elsewhere"})
+
+		_, err := other.ParseAccessToken(token)
+		require.ErrorIs(t, err, jwt.ErrUnexpectedIssuer)
+	})
+
+	t.Run("Empty secret", func(t *testing.T) {
+		_, err := jwt.NewAuthenticator(jwt.Config{}).IssueAccessToken(42, jwt.Permissions{}, time.Minute)
+		require.ErrorIs(t, err, jwt.ErrEmptySecretKey)
+	})
+}
@@ -0,0 +1,49 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const unmatchedRoute = "unmatched"
+
+type RequestObserver interface {
+	ObserveHTTPRequest(method, route string, status int, duration time.Duration)
+}
+
+// WithRequestObserver reports every handled request to the observer.
+func WithRequestObserver(o RequestObserver) HandlerOption {
+	return func(h *Handler) {
+		h.requestObserver = o
+	}
+}
+
+// observeRequests reports the route pattern rather than the path to keep the number of series bounded.
+// The pattern is complete only after the request has been routed, i.e. after next returns.
+func (h *Handler) observeRequests(next http.Handler) http.Handler {
+	if h.requestObserver == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		h.requestObserver.ObserveHTTPRequest(r.Method, route, status, time.Since(start))
+	})
+}
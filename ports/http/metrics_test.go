@@ -0,0 +1,51 @@
+package http_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torwig/user-service/entities"
+	userhttp "github.com/torwig/user-service/ports/http"
+	"go.uber.org/zap"
+)
+
+type observedRequest struct {
+	method string
+	route  string
+	status int
+}
+
+type fakeRequestObserver struct {
+	requests []observedRequest
+}
+
+func (o *fakeRequestObserver) ObserveHTTPRequest(method, route string, status int, _ time.Duration) {
+	o.requests = append(o.requests, observedRequest{method: method, route: route, status: status})
+}
+
+func TestHandler_ObserveRequests(t *testing.T) {
+	observer := &fakeRequestObserver{}
+
+	router := userhttp.NewHandler(stubUserService{}, stubAPIKeyService{},
+		stubAuthenticator{user: entities.NewAuthenticatedUser(5)},
+		zap.NewNop().Sugar(), userhttp.WithRequestObserver(observer)).Router()
+
+	for _, path := range []string{"/api/v1/users/5", "/api/v1/users/6", "/no/such/path"} {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Authorization", "Bearer token")
+
+		router.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	require.Len(t, observer.requests, 3)
+	assert.Equal(t, observedRequest{method: http.MethodGet, route: "/api/v1/users/{id}", status: http.StatusOK},
+		observer.requests[0])
+	assert.Equal(t, observedRequest{method: http.MethodGet, route: "/api/v1/users/{id}", status: http.StatusForbidden},
+		observer.requests[1])
+	assert.Equal(t, observedRequest{method: http.MethodGet, route: "unmatched", status: http.StatusNotFound},
+		observer.requests[2])
+}
@@ -0,0 +1,126 @@
+package http_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/torwig/user-service/entities"
+	userhttp "github.com/torwig/user-service/ports/http"
+	"go.uber.org/zap"
+)
+
+// recordingUserService remembers the parameters of the last update.
+type recordingUserService struct {
+	stubUserService
+	updates []entities.UpdateUserParams
+}
+
+func (s *recordingUserService) UpdateUser(
+	ctx context.Context,
+	id int64,
+	params entities.UpdateUserParams,
+) (entities.User, error) {
+	s.updates = append(s.updates, params)
+
+	return s.stubUserService.UpdateUser(ctx, id, params)
+}
+
+func TestHandler_PatchUser(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		params      entities.UpdateUserParams
+	}{
+		{
+			name:        "merge patch",
+			contentType: "application/merge-patch+json",
+			body:        `{"address":"Shelbyville","first_name":"John"}`,
+			status:      http.StatusOK,
+			params:      entities.UpdateUserParams{Address: stringPtr("Shelbyville")},
+		},
+		{
+			name:        "merge patch removing required field",
+			contentType: "application/merge-patch+json",
+			body:        `{"last_name":null}`,
+			status:      http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "merge patch of read-only field",
+			contentType: "application/merge-patch+json",
+			body:        `{"id":2}`,
+			status:      http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "merge patch adding unknown field",
+			contentType: "application/merge-patch+json",
+			body:        `{"nickname":"Johnny"}`,
+			status:      http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "invalid merge patch",
+			contentType: "application/merge-patch+json",
+			body:        `{"address":`,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "JSON patch",
+			contentType: "application/json-patch+json; charset=utf-8",
+			body: `[{"op":"test","path":"/first_name","value":"John"},` +
+				`{"op":"replace","path":"/last_name","value":"Smith"},{"op":"copy","from":"/last_name","path":"/address"}]`,
+			status: http.StatusOK,
+			params: entities.UpdateUserParams{LastName: stringPtr("Smith"), Address: stringPtr("Smith")},
+		},
+		{
+			name:        "JSON patch with failed test",
+			contentType: "application/json-patch+json",
+			body: `[{"op":"test","path":"/first_name","value":"Jack"},` +
+				`{"op":"replace","path":"/last_name","value":"Smith"}]`,
+			status: http.StatusConflict,
+		},
+		{
+			name:        "JSON patch of missing path",
+			contentType: "application/json-patch+json",
+			body:        `[{"op":"remove","path":"/nickname"}]`,
+			status:      http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "invalid JSON patch",
+			contentType: "application/json-patch+json",
+			body:        `{"op":"remove","path":"/address"}`,
+			status:      http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &recordingUserService{}
+			router := userhttp.NewHandler(svc, stubAPIKeyService{},
+				stubAuthenticator{user: entities.NewAuthenticatedUser(1)}, zap.NewNop().Sugar()).Router()
+
+			r := httptest.NewRequest(http.MethodPatch, "/api/v1/users/1", strings.NewReader(tt.body))
+			r.Header.Set("Authorization", "Bearer token")
+			r.Header.Set("Content-Type", tt.contentType)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.status, w.Code)
+
+			if tt.status == http.StatusOK {
+				assert.Equal(t, []entities.UpdateUserParams{tt.params}, svc.updates)
+			} else {
+				assert.Empty(t, svc.updates, "user must not be updated")
+			}
+		})
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,74 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/torwig/user-service/ports/http/requests"
+	"github.com/torwig/user-service/ports/http/responses"
+)
+
+// sendPhoneVerification sends a one-time code to the phone number of the user.
+func (h *Handler) sendPhoneVerification(w http.ResponseWriter, r *http.Request) {
+	id, err := identifierFromRequestURL(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	au, err := AuthenticatedUserFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if !au.CanUpdateUser(id) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	err = h.svc.SendPhoneVerification(r.Context(), id)
+	if err != nil {
+		h.logger(r).Errorf("failed to send phone verification to user %d: %s", id, err)
+		writeVerificationError(w, err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// verifyPhone marks the phone number of the user as verified with the code sent to it.
+func (h *Handler) verifyPhone(w http.ResponseWriter, r *http.Request) {
+	id, err := identifierFromRequestURL(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	au, err := AuthenticatedUserFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if !au.CanUpdateUser(id) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	req, err := requests.NewVerifyPhone(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.svc.VerifyPhone(r.Context(), id, req.Code)
+	if err != nil {
+		h.logger(r).Errorf("failed to verify phone number of user %d: %s", id, err)
+		writeVerificationError(w, err)
+
+		return
+	}
+
+	responses.SendJSON(w, http.StatusOK, responses.UserFromEntity(user))
+}
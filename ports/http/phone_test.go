@@ -0,0 +1,87 @@
+package http_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torwig/user-service/adapters/repository"
+	"github.com/torwig/user-service/entities"
+	"github.com/torwig/user-service/service"
+)
+
+func TestHandler_PhoneVerification(t *testing.T) {
+	repo := repository.NewInMemoryRepository()
+	sender := &fakeSMSSender{}
+	svc := service.New(repo, service.WithPhoneVerification(
+		service.PhoneVerificationConfig{Secret: []byte("verification-secret"), MaxAttempts: 2}, repo, sender))
+	admin := entities.NewAuthenticatedUser(1000,
+		entities.CreateUsersGranted(), entities.ViewUsersGranted(), entities.UpdateUsersGranted())
+
+	// a code that isn't the one sent, whatever it is
+	wrongCode := func(code string) string {
+		if code == "000000" {
+			return "000001"
+		}
+
+		return "000000"
+	}
+
+	w := serveAs(svc, admin, http.MethodPost, "/api/v1/users", `{"first_name":"John","last_name":"Doe",`+
+		`"phone_number":"+1234567890","address":"Springfield"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Nil(t, userOf(t, w).PhoneVerifiedAt)
+
+	t.Run("no code sent", func(t *testing.T) {
+		w := serveAs(svc, admin, http.MethodPost, "/api/v1/users/1/phone/verify", `{"code":"123456"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("too many attempts", func(t *testing.T) {
+		require.Equal(t, http.StatusAccepted,
+			serveAs(svc, admin, http.MethodPost, "/api/v1/users/1/phone/verification", "").Code)
+		code := sender.lastCode("+1234567890")
+		require.Len(t, code, 6)
+
+		w := serveAs(svc, admin, http.MethodPost, "/api/v1/users/1/phone/verify", `{"code":"`+wrongCode(code)+`"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+		w = serveAs(svc, admin, http.MethodPost, "/api/v1/users/1/phone/verify", `{"code":"`+wrongCode(code)+`"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+		w = serveAs(svc, admin, http.MethodPost, "/api/v1/users/1/phone/verify", `{"code":"`+code+`"}`)
+		assert.Equal(t, http.StatusTooManyRequests, w.Code, "the right code must not be accepted after the limit")
+	})
+
+	t.Run("code of previous phone number", func(t *testing.T) {
+		require.Equal(t, http.StatusAccepted,
+			serveAs(svc, admin, http.MethodPost, "/api/v1/users/1/phone/verification", "").Code)
+		code := sender.lastCode("+1234567890")
+
+		w := serveAs(svc, admin, http.MethodPatch, "/api/v1/users/1", `{"phone_number":"+1234567899"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = serveAs(svc, admin, http.MethodPost, "/api/v1/users/1/phone/verify", `{"code":"`+code+`"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("verify phone number", func(t *testing.T) {
+		require.Equal(t, http.StatusAccepted,
+			serveAs(svc, admin, http.MethodPost, "/api/v1/users/1/phone/verification", "").Code)
+
+		w := serveAs(svc, admin, http.MethodPost, "/api/v1/users/1/phone/verify",
+			`{"code":"`+sender.lastCode("+1234567899")+`"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotNil(t, userOf(t, w).PhoneVerifiedAt)
+
+		w = serveAs(svc, admin, http.MethodPost, "/api/v1/users/1/phone/verification", "")
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("changed phone number must be verified again", func(t *testing.T) {
+		w := serveAs(svc, admin, http.MethodPatch, "/api/v1/users/1", `{"phone_number":"+1234567890"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Nil(t, userOf(t, w).PhoneVerifiedAt)
+	})
+}
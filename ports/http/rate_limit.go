@@ -0,0 +1,305 @@
+package http
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/torwig/user-service/entities"
+)
+
+const (
+	RateLimitTierAnonymous  = "anonymous"
+	RateLimitTierUser       = "user"
+	RateLimitTierPrivileged = "privileged"
+
+	// maxBlockedClients bounds the number of clients remembered between sweeps of expired blocks.
+	maxBlockedClients = 10_000
+)
+
+type RateLimitStore interface {
+	// TakeToken takes a token from the bucket identified by the key, creating a full bucket if needed.
+	TakeToken(ctx context.Context, key string, limit entities.RateLimit) (entities.RateLimitDecision, error)
+}
+
+// RateLimitRule overrides the default limit for a route (e.g. "POST /api/v1/users"),
+// a tier or both. An empty route or tier matches any.
+type RateLimitRule struct {
+	Route string
+	Tier  string
+	Limit entities.RateLimit
+}
+
+type RateLimitConfig struct {
+	// Store is the name of the store buckets are kept in.
+	Store   string
+	Default entities.RateLimit
+	Rules   []RateLimitRule
+	// FailedAuth limits the failed authentications of every client IP, e.g. guessed credentials.
+	FailedAuth entities.RateLimit
+}
+
+func (c RateLimitConfig) Enabled() bool {
+	return !c.Default.IsUnlimited() || len(c.Rules) > 0 || !c.FailedAuth.IsUnlimited()
+}
+
+// limitFor picks the most specific rule: route and tier, route only, tier only, then the default.
+func (c RateLimitConfig) limitFor(route, tier string) entities.RateLimit {
+	limit := c.Default
+	bestScore := 0
+
+	for _, rule := range c.Rules {
+		if (rule.Route != "" && rule.Route != route) || (rule.Tier != "" && rule.Tier != tier) {
+			continue
+		}
+
+		score := 1
+		if rule.Route != "" {
+			score += 2
+		}
+		if rule.Tier != "" {
+			score++
+		}
+
+		if score > bestScore {
+			limit = rule.Limit
+			bestScore = score
+		}
+	}
+
+	return limit
+}
+
+type RateLimiter struct {
+	cfg   RateLimitConfig
+	store RateLimitStore
+
+	mu sync.Mutex
+	// blocked tells until when the client IPs that have run out of failed authentications are rejected.
+	blocked map[string]time.Time
+}
+
+func NewRateLimiter(cfg RateLimitConfig, store RateLimitStore) *RateLimiter {
+	return &RateLimiter{cfg: cfg, store: store, blocked: make(map[string]time.Time)}
+}
+
+func (rl *RateLimiter) block(ip string, d time.Duration) {
+	now := time.Now()
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if len(rl.blocked) >= maxBlockedClients {
+		for blockedIP, until := range rl.blocked {
+			if !now.Before(until) {
+				delete(rl.blocked, blockedIP)
+			}
+		}
+	}
+
+	rl.blocked[ip] = now.Add(d)
+}
+
+// blockedFor returns how long the client IP is still rejected for.
+func (rl *RateLimiter) blockedFor(ip string) time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	return time.Until(rl.blocked[ip])
+}
+
+// WithRateLimiter enables rate limiting of the API endpoints.
+func WithRateLimiter(rl *RateLimiter) HandlerOption {
+	return func(h *Handler) {
+		h.rateLimiter = rl
+	}
+}
+
+// rateLimiting has to wrap endpoint handlers (rather than routers) to see the complete route pattern.
+func (h *Handler) rateLimiting(next http.Handler) http.Handler {
+	if h.rateLimiter == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := r.Method + " " + chi.RouteContext(r.Context()).RoutePattern()
+		caller, tier := h.identifyCaller(r)
+
+		if h.takeRateLimitToken(w, r, route, route+"|"+caller, tier) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// failedAuthLimiting charges the client IP for every failed authentication. Once it runs out of them,
+// its requests are rejected before authentication until the next one is available,
+// so that guessing credentials is limited whether the guesses are right or not.
+func (h *Handler) failedAuthLimiting(next http.Handler) http.Handler {
+	if h.rateLimiter == nil || h.rateLimiter.cfg.FailedAuth.IsUnlimited() {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := h.clientIP(r)
+
+		if retryAfter := h.rateLimiter.blockedFor(ip); retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(retryAfter)))
+			w.WriteHeader(http.StatusTooManyRequests)
+
+			return
+		}
+
+		attempt := &authAttempt{}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authAttemptCtxKey, attempt)))
+
+		if !attempt.failed {
+			return
+		}
+
+		key := "failed-auth|ip:" + ip
+
+		decision, err := h.rateLimiter.store.TakeToken(r.Context(), key, h.rateLimiter.cfg.FailedAuth)
+		if err != nil {
+			h.logger(r).Errorf("failed to check rate limit of %s: %s", key, err)
+			return
+		}
+
+		if !decision.Allowed {
+			h.rateLimiter.block(ip, decision.RetryAfter)
+		}
+	})
+}
+
+// authAttempt collects the outcome of the authentication of a request.
+type authAttempt struct {
+	failed bool
+}
+
+// recordFailedAuthentication reports credentials that have been rejected.
+func recordFailedAuthentication(r *http.Request) {
+	if attempt, ok := r.Context().Value(authAttemptCtxKey).(*authAttempt); ok {
+		attempt.failed = true
+	}
+}
+
+// takeRateLimitToken takes a token from the bucket identified by the key and reports whether the request
+// may proceed, a rejected request is responded to.
+func (h *Handler) takeRateLimitToken(w http.ResponseWriter, r *http.Request, route, key, tier string) bool {
+	limit := h.rateLimiter.cfg.limitFor(route, tier)
+	if limit.IsUnlimited() {
+		return true
+	}
+
+	decision, err := h.rateLimiter.store.TakeToken(r.Context(), key, limit)
+	if err != nil {
+		// an unavailable store must not take the whole API down
+		h.logger(r).Errorf("failed to check rate limit of %s: %s", key, err)
+
+		return true
+	}
+
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.ResetAfter)))
+
+	if !decision.Allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(decision.RetryAfter)))
+		w.WriteHeader(http.StatusTooManyRequests)
+
+		return false
+	}
+
+	return true
+}
+
+// identifyCaller identifies the caller by the authenticated user or, failing that, by the client IP,
+// and returns it along with its rate limit tier.
+func (h *Handler) identifyCaller(r *http.Request) (string, string) {
+	au, err := AuthenticatedUserFromRequest(r)
+	if err == nil {
+		tier := RateLimitTierUser
+		if au.IsPrivileged() {
+			tier = RateLimitTierPrivileged
+		}
+
+		return "user:" + strconv.FormatInt(au.ID(), 10), tier
+	}
+
+	return "ip:" + h.clientIP(r), RateLimitTierAnonymous
+}
+
+// WithTrustedProxies makes the handler believe the client IP reported by the given proxies
+// in the X-Forwarded-For or X-Real-IP header, e.g. by a load balancer.
+func WithTrustedProxies(proxies []netip.Prefix) HandlerOption {
+	return func(h *Handler) {
+		h.trustedProxies = proxies
+	}
+}
+
+// clientIP returns the address of the client. Behind trusted proxies it's the last address
+// in X-Forwarded-For that isn't a trusted proxy, as the earlier ones can be forged by the client.
+func (h *Handler) clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	if !h.isTrustedProxy(ip) {
+		return ip
+	}
+
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if _, err := netip.ParseAddr(hop); err != nil {
+				break
+			}
+
+			ip = hop
+
+			if !h.isTrustedProxy(hop) {
+				break
+			}
+		}
+
+		return ip
+	}
+
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		if _, err := netip.ParseAddr(realIP); err == nil {
+			return realIP
+		}
+	}
+
+	return ip
+}
+
+func (h *Handler) isTrustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+
+	addr = addr.Unmap()
+
+	for _, proxy := range h.trustedProxies {
+		if proxy.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,162 @@
+package http_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torwig/user-service/adapters/repository"
+	"github.com/torwig/user-service/entities"
+	userhttp "github.com/torwig/user-service/ports/http"
+	"go.uber.org/zap"
+)
+
+func TestHandler_RateLimiting(t *testing.T) {
+	cfg := userhttp.RateLimitConfig{
+		Default: entities.RateLimit{Requests: 100, Period: time.Minute},
+		Rules: []userhttp.RateLimitRule{
+			{Route: "GET /api/v1/users/{id}", Tier: userhttp.RateLimitTierUser, Limit: entities.RateLimit{
+				Requests: 2,
+				Period:   time.Minute,
+			}},
+			{Route: "POST /api/v1/users", Limit: entities.RateLimit{Requests: 1, Period: time.Second}},
+		},
+	}
+
+	newRouter := func(user *entities.AuthenticatedUser) http.Handler {
+		rl := userhttp.NewRateLimiter(cfg, repository.NewInMemoryRateLimitStore())
+
+		return userhttp.NewHandler(stubUserService{}, stubAPIKeyService{}, stubAuthenticator{user: user},
+			zap.NewNop().Sugar(), userhttp.WithRateLimiter(rl)).Router()
+	}
+
+	get := func(router http.Handler, path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Authorization", "Bearer token")
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		return w
+	}
+
+	t.Run("Route and tier rule", func(t *testing.T) {
+		router := newRouter(entities.NewAuthenticatedUser(7))
+
+		w := get(router, "/api/v1/users/7")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+
+		w = get(router, "/api/v1/users/7")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+		w = get(router, "/api/v1/users/7")
+		require.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "30", w.Header().Get("Retry-After"))
+		assert.Equal(t, "60", w.Header().Get("RateLimit-Reset"))
+	})
+
+	t.Run("Route rule", func(t *testing.T) {
+		router := newRouter(entities.NewAuthenticatedUser(7, entities.CreateUsersGranted()))
+
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/users",
+			strings.NewReader(`{"first_name":"A","last_name":"B","phone_number":"1","address":"C"}`))
+		r.Header.Set("Authorization", "Bearer token")
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		require.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+	})
+
+	t.Run("Default limit for another tier", func(t *testing.T) {
+		router := newRouter(entities.NewAuthenticatedUser(7, entities.ViewUsersGranted()))
+
+		w := get(router, "/api/v1/users/8")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "100", w.Header().Get("RateLimit-Limit"))
+	})
+
+	t.Run("Failed authentications", func(t *testing.T) {
+		rl := userhttp.NewRateLimiter(userhttp.RateLimitConfig{
+			FailedAuth: entities.RateLimit{Requests: 2, Period: time.Minute},
+		}, repository.NewInMemoryRateLimitStore())
+
+		router := userhttp.NewHandler(stubUserService{}, stubAPIKeyService{}, stubAuthenticator{},
+			zap.NewNop().Sugar(), userhttp.WithRateLimiter(rl)).Router()
+
+		guess := func() *httptest.ResponseRecorder {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/users/7", nil)
+			r.Header.Set("Authorization", "ApiKey usk_guessed_secret")
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			return w
+		}
+
+		// the third failure runs out of the failed authentications and blocks the client IP
+		require.Equal(t, http.StatusUnauthorized, guess().Code)
+		require.Equal(t, http.StatusUnauthorized, guess().Code)
+		require.Equal(t, http.StatusUnauthorized, guess().Code)
+
+		w := guess()
+		require.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "30", w.Header().Get("Retry-After"))
+	})
+
+	t.Run("Successful authentications", func(t *testing.T) {
+		rl := userhttp.NewRateLimiter(userhttp.RateLimitConfig{
+			FailedAuth: entities.RateLimit{Requests: 1, Period: time.Minute},
+		}, repository.NewInMemoryRateLimitStore())
+
+		router := userhttp.NewHandler(stubUserService{}, stubAPIKeyService{},
+			stubAuthenticator{user: entities.NewAuthenticatedUser(7)}, zap.NewNop().Sugar(),
+			userhttp.WithRateLimiter(rl)).Router()
+
+		for i := 0; i < 5; i++ {
+			require.Equal(t, http.StatusOK, get(router, "/api/v1/users/7").Code)
+		}
+	})
+
+	t.Run("Client IP behind trusted proxies", func(t *testing.T) {
+		rl := userhttp.NewRateLimiter(userhttp.RateLimitConfig{
+			FailedAuth: entities.RateLimit{Requests: 1, Period: time.Minute},
+		}, repository.NewInMemoryRateLimitStore())
+
+		router := userhttp.NewHandler(stubUserService{}, stubAPIKeyService{}, stubAuthenticator{},
+			zap.NewNop().Sugar(), userhttp.WithRateLimiter(rl),
+			userhttp.WithTrustedProxies([]netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")})).Router()
+
+		guess := func(remoteAddr, forwardedFor string) int {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/users/7", nil)
+			r.RemoteAddr = remoteAddr
+			r.Header.Set("Authorization", "ApiKey usk_guessed_secret")
+			r.Header.Set("X-Forwarded-For", forwardedFor)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			return w.Code
+		}
+
+		require.Equal(t, http.StatusUnauthorized, guess("192.0.2.1:1234", "203.0.113.7, 192.0.2.9"))
+		require.Equal(t, http.StatusUnauthorized, guess("192.0.2.1:1234", "203.0.113.7, 192.0.2.9"))
+		require.Equal(t, http.StatusTooManyRequests, guess("192.0.2.1:1234", "203.0.113.7"))
+
+		// another client behind the same proxy isn't blocked
+		require.Equal(t, http.StatusUnauthorized, guess("192.0.2.1:1234", "203.0.113.8"))
+
+		// an untrusted peer can't pick its client IP
+		require.Equal(t, http.StatusUnauthorized, guess("198.51.100.1:1234", "203.0.113.8"))
+		require.Equal(t, http.StatusUnauthorized, guess("198.51.100.1:1234", "203.0.113.8"))
+		require.Equal(t, http.StatusTooManyRequests, guess("198.51.100.1:1234", "203.0.113.9"))
+	})
+}
@@ -0,0 +1,93 @@
+package http_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torwig/user-service/adapters/repository"
+	"github.com/torwig/user-service/entities"
+	"github.com/torwig/user-service/ports/http/generated"
+	"github.com/torwig/user-service/service"
+)
+
+func TestHandler_ReplaceUser(t *testing.T) {
+	repo := repository.NewInMemoryRepository()
+	svc := service.New(repo)
+
+	existing, err := repo.Create(context.Background(), entities.CreateUserParams{
+		FirstName: "John", LastName: "Doe", PhoneNumber: "+1234567890", Address: "Springfield",
+	})
+	require.NoError(t, err)
+
+	deleted, err := repo.Create(context.Background(), entities.CreateUserParams{
+		FirstName: "Jane", LastName: "Doe", PhoneNumber: "+1234567891", Address: "Springfield",
+	})
+	require.NoError(t, err)
+	require.NoError(t, repo.Delete(context.Background(), deleted.ID, entities.StatusChangeCause{}))
+
+	admin := entities.NewAuthenticatedUser(1000, entities.CreateUsersGranted(), entities.UpdateUsersGranted())
+	updater := entities.NewAuthenticatedUser(1000, entities.UpdateUsersGranted())
+	body := `{"first_name":"Bob","last_name":"Smith","phone_number":"+1000000000","address":"Shelbyville"}`
+
+	t.Run("replace", func(t *testing.T) {
+		w := serveAs(svc, updater, http.MethodPut, "/api/v1/users/1", body)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		user := userOf(t, w)
+		assert.Equal(t, existing.ID, user.Id)
+		assert.Equal(t, "Bob", user.FirstName)
+		assert.Equal(t, "Shelbyville", user.Address)
+		assert.True(t, existing.CreatedAt.Equal(user.CreatedAt))
+	})
+
+	t.Run("missing field", func(t *testing.T) {
+		w := serveAs(svc, updater, http.MethodPut, "/api/v1/users/1",
+			`{"first_name":"Bob","last_name":"Smith","phone_number":"+1000000000"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("replace missing user", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, serveAs(svc, admin, http.MethodPut, "/api/v1/users/500", body).Code)
+	})
+
+	t.Run("upsert requires permission to create", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden,
+			serveAs(svc, updater, http.MethodPut, "/api/v1/users/by-external/crm/C-1", body).Code)
+	})
+
+	t.Run("upsert creates user", func(t *testing.T) {
+		w := serveAs(svc, admin, http.MethodPut, "/api/v1/users/by-external/crm/C-1", body)
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		user := userOf(t, w)
+		assert.Greater(t, user.Id, deleted.ID, "the ID is given by the repository")
+		assert.Equal(t, []generated.ExternalID{{Source: "crm", Id: "C-1"}}, user.ExternalIds)
+
+		w = serveAs(svc, admin, http.MethodPut, "/api/v1/users/by-external/crm/C-1", strings.Replace(body, "Bob", "Rob", 1))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		replaced, err := svc.GetUser(context.Background(), user.Id)
+		require.NoError(t, err)
+		assert.Equal(t, "Rob", replaced.FirstName)
+	})
+
+	t.Run("upsert deleted user", func(t *testing.T) {
+		_, err := repo.Replace(context.Background(), deleted.ID, entities.ReplaceUserParams{
+			FirstName: "Jane", LastName: "Doe", PhoneNumber: "+1234567891", Address: "Springfield",
+			ExternalIDs: []entities.ExternalID{{Source: "crm", ID: "C-2"}},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusConflict,
+			serveAs(svc, admin, http.MethodPut, "/api/v1/users/by-external/crm/C-2", body).Code)
+	})
+
+	t.Run("invalid external ID", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest,
+			serveAs(svc, admin, http.MethodPut, "/api/v1/users/by-external/CRM/C-1", body).Code)
+	})
+}
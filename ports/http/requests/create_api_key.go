@@ -0,0 +1,67 @@
+package requests
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/torwig/user-service/entities"
+	"github.com/torwig/user-service/ports/http/generated"
+)
+
+type CreateAPIKey struct {
+	generated.CreateAPIKeyJSONRequestBody
+}
+
+func NewCreateAPIKey(r *http.Request) (CreateAPIKey, error) {
+	var req CreateAPIKey
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return req, ErrRequestBodyDecodingFailed
+	}
+
+	return req, req.Validate()
+}
+
+func (r CreateAPIKey) Validate() error {
+	if r.Name == "" {
+		return ErrEmptyRequestField
+	}
+
+	return validateScopes(r.Scopes)
+}
+
+// ToCreateAPIKeyParams converts the request to parameters of a key owned by
+// the given user unless the request explicitly names another owner.
+func (r CreateAPIKey) ToCreateAPIKeyParams(callerID int64) entities.CreateAPIKeyParams {
+	userID := callerID
+	if r.UserId != nil {
+		userID = *r.UserId
+	}
+
+	return entities.CreateAPIKeyParams{
+		UserID:    userID,
+		Name:      r.Name,
+		Scopes:    scopesToStrings(r.Scopes),
+		ExpiresAt: r.ExpiresAt,
+	}
+}
+
+func validateScopes(scopes []generated.APIKeyScope) error {
+	for _, s := range scopes {
+		if _, err := entities.APIKeyScope(s).Permission(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func scopesToStrings(scopes []generated.APIKeyScope) []string {
+	result := make([]string, 0, len(scopes))
+
+	for _, s := range scopes {
+		result = append(result, string(s))
+	}
+
+	return result
+}
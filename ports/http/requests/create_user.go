@@ -28,14 +28,41 @@ func (r CreateUser) Validate() error {
 		return ErrEmptyRequestField
 	}
 
-	return nil
+	if err := validateEmail(r.Email); err != nil {
+		return err
+	}
+
+	if r.Status != nil && *r.Status != generated.UserCreateParamsStatusPending &&
+		*r.Status != generated.UserCreateParamsStatusActive {
+		return ErrInvalidStatus
+	}
+
+	return validateExternalIDs(r.ExternalIds)
 }
 
 func (r CreateUser) ToCreateUserParams() entities.CreateUserParams {
-	return entities.CreateUserParams{
+	params := entities.CreateUserParams{
 		FirstName:   r.FirstName,
 		LastName:    r.LastName,
 		PhoneNumber: r.PhoneNumber,
 		Address:     r.Address,
 	}
+
+	if r.Email != nil {
+		params.Email = *r.Email
+	}
+
+	if r.Status != nil {
+		params.Status = entities.UserStatus(*r.Status)
+	}
+
+	if r.ExternalIds != nil {
+		params.ExternalIDs = externalIDsToEntities(*r.ExternalIds)
+	}
+
+	if r.Attributes != nil {
+		params.Attributes = *r.Attributes
+	}
+
+	return params
 }
@@ -0,0 +1,49 @@
+package requests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/ports/http/generated"
+)
+
+const maxEmailLength = 255
+
+var ErrInvalidEmail = errors.New("invalid email")
+
+// validateEmail checks that the email is a bare address such as john@example.com, an empty one means no email.
+func validateEmail(email *string) error {
+	if email == nil || *email == "" {
+		return nil
+	}
+
+	trimmed := strings.TrimSpace(*email)
+
+	address, err := mail.ParseAddress(trimmed)
+	if err != nil || address.Address != trimmed || len(trimmed) > maxEmailLength {
+		return errors.Wrapf(ErrInvalidEmail, "%q", *email)
+	}
+
+	return nil
+}
+
+type VerifyEmail struct {
+	generated.VerifyEmailJSONRequestBody
+}
+
+func NewVerifyEmail(r *http.Request) (VerifyEmail, error) {
+	var req VerifyEmail
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return req, ErrRequestBodyDecodingFailed
+	}
+
+	if req.Token == "" {
+		return req, ErrEmptyRequestField
+	}
+
+	return req, nil
+}
@@ -5,4 +5,6 @@ import "github.com/pkg/errors"
 var (
 	ErrRequestBodyDecodingFailed = errors.New("failed to decode a request body")
 	ErrEmptyRequestField         = errors.New("field must not be empty")
+	ErrInvalidStatus             = errors.New("status must be pending or active")
+	ErrInvalidQueryParameter     = errors.New("invalid query parameter")
 )
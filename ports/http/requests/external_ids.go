@@ -0,0 +1,91 @@
+package requests
+
+import (
+	"regexp"
+
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+	"github.com/torwig/user-service/ports/http/generated"
+)
+
+const maxExternalIDLength = 255
+
+var (
+	ErrInvalidExternalID   = errors.New("invalid external ID")
+	ErrDuplicateExternalID = errors.New("external ID is given more than once")
+
+	externalIDSourcePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,63}$`)
+)
+
+// ValidateExternalIDSource checks the name of the system external IDs come from, e.g. hr or crm.
+func ValidateExternalIDSource(source string) error {
+	if !externalIDSourcePattern.MatchString(source) {
+		return errors.Wrapf(ErrInvalidExternalID, "source %q", source)
+	}
+
+	return nil
+}
+
+// ValidateExternalID checks an external ID given in the URL the same way as the ones of a request body.
+func ValidateExternalID(source, id string) error {
+	return validateExternalIDs(&[]generated.ExternalID{{Source: source, Id: id}})
+}
+
+func validateExternalIDs(ids *[]generated.ExternalID) error {
+	if ids == nil {
+		return nil
+	}
+
+	seen := make(map[generated.ExternalID]bool, len(*ids))
+
+	for _, id := range *ids {
+		if err := ValidateExternalIDSource(id.Source); err != nil {
+			return err
+		}
+
+		if id.Id == "" || len(id.Id) > maxExternalIDLength {
+			return errors.Wrapf(ErrInvalidExternalID, "ID %q of source %q", id.Id, id.Source)
+		}
+
+		if seen[id] {
+			return errors.Wrapf(ErrDuplicateExternalID, "ID %q of source %q", id.Id, id.Source)
+		}
+
+		seen[id] = true
+	}
+
+	return nil
+}
+
+func externalIDsToEntities(ids []generated.ExternalID) []entities.ExternalID {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	externalIDs := make([]entities.ExternalID, 0, len(ids))
+	for _, id := range ids {
+		externalIDs = append(externalIDs, entities.ExternalID{Source: id.Source, ID: id.Id})
+	}
+
+	return externalIDs
+}
+
+// sameExternalIDs reports whether both lists have the same IDs regardless of their order.
+func sameExternalIDs(a, b []generated.ExternalID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	set := make(map[generated.ExternalID]bool, len(a))
+	for _, id := range a {
+		set[id] = true
+	}
+
+	for _, id := range b {
+		if !set[id] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,88 @@
+package requests
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+	"github.com/torwig/user-service/ports/http/generated"
+)
+
+const (
+	defaultListUsersLimit = 50
+	maxListUsersLimit     = 500
+)
+
+type ListUsers struct {
+	generated.ListUsersParams
+	attributes entities.Attributes
+}
+
+// NewListUsers reads the page and the attribute filter from the query, see entities.ParseAttributeCondition.
+func NewListUsers(r *http.Request) (ListUsers, error) {
+	var req ListUsers
+
+	query := r.URL.Query()
+
+	if s := query.Get("after_id"); s != "" {
+		afterID, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return req, errors.Wrap(ErrInvalidQueryParameter, "after_id")
+		}
+
+		req.AfterId = &afterID
+	}
+
+	if s := query.Get("limit"); s != "" {
+		limit, err := strconv.ParseUint(s, 10, 64)
+		if err != nil || limit == 0 || limit > maxListUsersLimit {
+			return req, errors.Wrap(ErrInvalidQueryParameter, "limit")
+		}
+
+		req.Limit = &limit
+	}
+
+	if s := query.Get("include_deleted"); s != "" {
+		includeDeleted, err := strconv.ParseBool(s)
+		if err != nil {
+			return req, errors.Wrap(ErrInvalidQueryParameter, "include_deleted")
+		}
+
+		req.IncludeDeleted = &includeDeleted
+	}
+
+	if conditions, ok := query["attribute"]; ok {
+		req.Attribute = &conditions
+		req.attributes = entities.Attributes{}
+
+		for _, condition := range conditions {
+			key, value, err := entities.ParseAttributeCondition(condition)
+			if err != nil {
+				return req, err
+			}
+
+			req.attributes[key] = value
+		}
+	}
+
+	return req, nil
+}
+
+func (r ListUsers) ToListUsersParams() entities.ListUsersParams {
+	params := entities.ListUsersParams{Limit: defaultListUsersLimit, Attributes: r.attributes}
+
+	if r.AfterId != nil {
+		params.AfterID = *r.AfterId
+	}
+
+	if r.Limit != nil {
+		params.Limit = *r.Limit
+	}
+
+	if r.IncludeDeleted != nil {
+		params.IncludeDeleted = *r.IncludeDeleted
+	}
+
+	return params
+}
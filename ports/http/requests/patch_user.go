@@ -0,0 +1,198 @@
+package requests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/ports/http/generated"
+)
+
+const (
+	// MergePatchContentType is the media type of JSON Merge Patch (RFC 7396) documents.
+	MergePatchContentType = "application/merge-patch+json"
+	// JSONPatchContentType is the media type of JSON Patch (RFC 6902) documents.
+	JSONPatchContentType = "application/json-patch+json"
+)
+
+var (
+	ErrPatchTestFailed    = errors.New("patch test operation failed")
+	ErrPatchNotApplicable = errors.New("patch can't be applied to the user")
+	ErrReadOnlyField      = errors.New("field is read-only")
+)
+
+// IsPatch reports whether the request body is a patch document rather than the fields to update.
+func IsPatch(r *http.Request) bool {
+	return patchMediaType(r) != ""
+}
+
+func patchMediaType(r *http.Request) string {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != MergePatchContentType && mediaType != JSONPatchContentType) {
+		return ""
+	}
+
+	return mediaType
+}
+
+// NewPatchUser applies the patch document of the request to the current representation of the user and
+// returns the fields that the patch changes. Unlike NewUpdateUser, it tells a removed field from an absent one,
+// and the patched user has to be valid as a whole.
+func NewPatchUser(r *http.Request, current generated.User) (UpdateUser, error) {
+	var req UpdateUser
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		return req, ErrRequestBodyDecodingFailed
+	}
+
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return req, errors.Wrap(err, "failed to encode user")
+	}
+
+	patchedDoc, err := applyPatch(patchMediaType(r), doc, patch)
+	if err != nil {
+		return req, err
+	}
+
+	// members removed by the patch are decoded as empty values
+	var patched generated.User
+
+	decoder := json.NewDecoder(bytes.NewReader(patchedDoc))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&patched); err != nil {
+		return req, errors.Wrap(ErrPatchNotApplicable, err.Error())
+	}
+
+	if patched.Id != current.Id || !patched.CreatedAt.Equal(current.CreatedAt) ||
+		!patched.UpdatedAt.Equal(current.UpdatedAt) || !sameTime(patched.EmailVerifiedAt, current.EmailVerifiedAt) ||
+		!sameTime(patched.PhoneVerifiedAt, current.PhoneVerifiedAt) || patched.Status != current.Status {
+		return req, ErrReadOnlyField
+	}
+
+	if err := (ReplaceUser{ReplaceUserJSONRequestBody: generated.ReplaceUserJSONRequestBody{
+		FirstName:   patched.FirstName,
+		LastName:    patched.LastName,
+		PhoneNumber: patched.PhoneNumber,
+		Address:     patched.Address,
+		Email:       patched.Email,
+		ExternalIds: &patched.ExternalIds,
+	}}).Validate(); err != nil {
+		return req, err
+	}
+
+	attributesPatch, err := attributesMergePatch(current.Attributes, patched.Attributes)
+	if err != nil {
+		return req, err
+	}
+
+	req.FirstName = changedField(current.FirstName, patched.FirstName)
+	req.LastName = changedField(current.LastName, patched.LastName)
+	req.PhoneNumber = changedField(current.PhoneNumber, patched.PhoneNumber)
+	req.Address = changedField(current.Address, patched.Address)
+	// a removed email is cleared
+	req.Email = changedField(stringValue(current.Email), stringValue(patched.Email))
+
+	if !sameExternalIDs(current.ExternalIds, patched.ExternalIds) {
+		req.ExternalIds = &patched.ExternalIds
+	}
+
+	if len(attributesPatch) > 0 {
+		req.Attributes = &attributesPatch
+	}
+
+	return req, nil
+}
+
+func applyPatch(mediaType string, doc, patch []byte) ([]byte, error) {
+	if mediaType == MergePatchContentType {
+		if !json.Valid(patch) {
+			return nil, ErrRequestBodyDecodingFailed
+		}
+
+		patchedDoc, err := jsonpatch.MergePatch(doc, patch)
+		if err != nil {
+			return nil, errors.Wrap(ErrPatchNotApplicable, err.Error())
+		}
+
+		return patchedDoc, nil
+	}
+
+	operations, err := jsonpatch.DecodePatch(patch)
+	if err != nil {
+		return nil, ErrRequestBodyDecodingFailed
+	}
+
+	patchedDoc, err := operations.Apply(doc)
+	if err != nil {
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			return nil, errors.Wrap(ErrPatchTestFailed, err.Error())
+		}
+
+		return nil, errors.Wrap(ErrPatchNotApplicable, err.Error())
+	}
+
+	return patchedDoc, nil
+}
+
+// attributesMergePatch returns the merge patch that turns the current attributes into the patched ones,
+// it's empty if they're the same.
+func attributesMergePatch(current, patched map[string]interface{}) (map[string]interface{}, error) {
+	if patched == nil {
+		patched = map[string]interface{}{}
+	}
+
+	currentDoc, err := json.Marshal(current)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode attributes")
+	}
+
+	patchedDoc, err := json.Marshal(patched)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode attributes")
+	}
+
+	patchDoc, err := jsonpatch.CreateMergePatch(currentDoc, patchedDoc)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create attributes patch")
+	}
+
+	var patch map[string]interface{}
+	if err := json.Unmarshal(patchDoc, &patch); err != nil {
+		return nil, errors.Wrap(err, "failed to decode attributes patch")
+	}
+
+	return patch, nil
+}
+
+// changedField returns the patched value only if it differs from the current one.
+func changedField(current, patched string) *string {
+	if current == patched {
+		return nil
+	}
+
+	return &patched
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Equal(*b)
+}
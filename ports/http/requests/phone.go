@@ -0,0 +1,26 @@
+package requests
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/torwig/user-service/ports/http/generated"
+)
+
+type VerifyPhone struct {
+	generated.VerifyPhoneJSONRequestBody
+}
+
+func NewVerifyPhone(r *http.Request) (VerifyPhone, error) {
+	var req VerifyPhone
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return req, ErrRequestBodyDecodingFailed
+	}
+
+	if req.Code == "" {
+		return req, ErrEmptyRequestField
+	}
+
+	return req, nil
+}
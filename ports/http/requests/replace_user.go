@@ -0,0 +1,40 @@
+package requests
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/torwig/user-service/entities"
+	"github.com/torwig/user-service/ports/http/generated"
+)
+
+type ReplaceUser struct {
+	generated.ReplaceUserJSONRequestBody
+}
+
+func NewReplaceUser(r *http.Request) (ReplaceUser, error) {
+	var req ReplaceUser
+
+	if err := json.NewDecoder(r.Body).Decode(&req.ReplaceUserJSONRequestBody); err != nil {
+		return req, ErrRequestBodyDecodingFailed
+	}
+
+	return req, req.Validate()
+}
+
+// Validate checks the user the same way a new one is checked.
+func (r ReplaceUser) Validate() error {
+	return r.createUser().Validate()
+}
+
+func (r ReplaceUser) ToReplaceUserParams() entities.ReplaceUserParams {
+	return r.createUser().ToCreateUserParams()
+}
+
+// createUser ignores the status, it's changed by suspending or activating the user only.
+func (r ReplaceUser) createUser() CreateUser {
+	body := r.ReplaceUserJSONRequestBody
+	body.Status = nil
+
+	return CreateUser{CreateUserJSONRequestBody: body}
+}
@@ -0,0 +1,34 @@
+package requests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+	"github.com/torwig/user-service/ports/http/generated"
+)
+
+type ChangeUserStatus struct {
+	generated.UserStatusChange
+}
+
+// NewChangeUserStatus decodes the optional body, a request without one changes the status without a reason.
+func NewChangeUserStatus(r *http.Request) (ChangeUserStatus, error) {
+	var req ChangeUserStatus
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return req, ErrRequestBodyDecodingFailed
+	}
+
+	return req, nil
+}
+
+func (r ChangeUserStatus) ToChangeUserStatusParams(
+	status entities.UserStatus,
+	actor string,
+) entities.ChangeUserStatusParams {
+	return entities.ChangeUserStatusParams{Status: status, Reason: stringValue(r.Reason), Actor: actor}
+}
@@ -0,0 +1,49 @@
+package requests
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/torwig/user-service/entities"
+	"github.com/torwig/user-service/ports/http/generated"
+)
+
+type UpdateAPIKey struct {
+	generated.UpdateAPIKeyJSONRequestBody
+}
+
+func NewUpdateAPIKey(r *http.Request) (UpdateAPIKey, error) {
+	var req UpdateAPIKey
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return req, ErrRequestBodyDecodingFailed
+	}
+
+	return req, req.Validate()
+}
+
+func (r UpdateAPIKey) Validate() error {
+	if r.Name != nil && *r.Name == "" {
+		return ErrEmptyRequestField
+	}
+
+	if r.Scopes != nil {
+		return validateScopes(*r.Scopes)
+	}
+
+	return nil
+}
+
+func (r UpdateAPIKey) ToUpdateAPIKeyParams() entities.UpdateAPIKeyParams {
+	params := entities.UpdateAPIKeyParams{
+		Name:      r.Name,
+		ExpiresAt: r.ExpiresAt,
+	}
+
+	if r.Scopes != nil {
+		scopes := scopesToStrings(*r.Scopes)
+		params.Scopes = &scopes
+	}
+
+	return params
+}
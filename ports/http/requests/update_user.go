@@ -19,14 +19,32 @@ func NewUpdateUser(r *http.Request) (UpdateUser, error) {
 		return req, ErrRequestBodyDecodingFailed
 	}
 
-	return req, nil
+	if err := validateEmail(req.Email); err != nil {
+		return req, err
+	}
+
+	return req, validateExternalIDs(req.ExternalIds)
 }
 
 func (r UpdateUser) ToUpdateUserParams() entities.UpdateUserParams {
-	return entities.UpdateUserParams{
+	params := entities.UpdateUserParams{
 		FirstName:   r.FirstName,
 		LastName:    r.LastName,
 		PhoneNumber: r.PhoneNumber,
 		Address:     r.Address,
+		Email:       r.Email,
+	}
+
+	// the IDs are replaced even with an empty list, which removes all of them
+	if r.ExternalIds != nil {
+		externalIDs := externalIDsToEntities(*r.ExternalIds)
+		params.ExternalIDs = &externalIDs
 	}
+
+	if r.Attributes != nil {
+		attributes := entities.Attributes(*r.Attributes)
+		params.Attributes = &attributes
+	}
+
+	return params
 }
@@ -0,0 +1,41 @@
+package responses
+
+import (
+	"github.com/torwig/user-service/entities"
+	"github.com/torwig/user-service/ports/http/generated"
+)
+
+func APIKeyFromEntity(k entities.APIKey) generated.APIKey {
+	scopes := make([]generated.APIKeyScope, 0, len(k.Scopes))
+	for _, s := range k.Scopes {
+		scopes = append(scopes, generated.APIKeyScope(s))
+	}
+
+	return generated.APIKey{
+		Id:         k.ID,
+		UserId:     k.UserID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     scopes,
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+		Revoked:    k.Revoked,
+		CreatedAt:  k.CreatedAt,
+	}
+}
+
+func APIKeyListFromEntities(keys []entities.APIKey) generated.APIKeyList {
+	items := make([]generated.APIKey, 0, len(keys))
+	for _, k := range keys {
+		items = append(items, APIKeyFromEntity(k))
+	}
+
+	return generated.APIKeyList{Items: items}
+}
+
+func CreatedAPIKeyFromEntity(k entities.APIKey, plaintext string) generated.CreatedAPIKey {
+	return generated.CreatedAPIKey{
+		ApiKey: APIKeyFromEntity(k),
+		Key:    plaintext,
+	}
+}
@@ -0,0 +1,26 @@
+package responses
+
+import (
+	"github.com/torwig/user-service/ports/http/generated"
+)
+
+func HealthCheckResult(name string, err error) generated.HealthCheck {
+	check := generated.HealthCheck{Name: name, Status: "ok"}
+
+	if err != nil {
+		msg := err.Error()
+		check.Status = "failed"
+		check.Error = &msg
+	}
+
+	return check
+}
+
+func Health(status string, checks []generated.HealthCheck) generated.Health {
+	health := generated.Health{Status: status}
+	if len(checks) > 0 {
+		health.Checks = &checks
+	}
+
+	return health
+}
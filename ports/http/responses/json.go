@@ -6,7 +6,7 @@ import (
 )
 
 func SendJSON(w http.ResponseWriter, statusCode int, v any) {
-	w.WriteHeader(statusCode)
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
 	_ = json.NewEncoder(w).Encode(v)
 }
@@ -6,11 +6,44 @@ import (
 )
 
 func UserFromEntity(u entities.User) generated.User {
-	return generated.User{
-		Id:          u.ID,
-		FirstName:   u.FirstName,
-		LastName:    u.LastName,
-		PhoneNumber: u.PhoneNumber,
-		Address:     u.Address,
+	externalIDs := make([]generated.ExternalID, 0, len(u.ExternalIDs))
+	for _, id := range u.ExternalIDs {
+		externalIDs = append(externalIDs, generated.ExternalID{Source: id.Source, Id: id.ID})
 	}
+
+	attributes := map[string]interface{}(u.Attributes)
+	if attributes == nil {
+		attributes = map[string]interface{}{}
+	}
+
+	user := generated.User{
+		Id:              u.ID,
+		FirstName:       u.FirstName,
+		LastName:        u.LastName,
+		PhoneNumber:     u.PhoneNumber,
+		PhoneVerifiedAt: u.PhoneVerifiedAt,
+		Address:         u.Address,
+		Status:          generated.UserStatus(u.Status),
+		CreatedAt:       u.CreatedAt,
+		UpdatedAt:       u.UpdatedAt,
+		ExternalIds:     externalIDs,
+		Attributes:      attributes,
+	}
+
+	if u.Email != "" {
+		email := u.Email
+		user.Email = &email
+		user.EmailVerifiedAt = u.EmailVerifiedAt
+	}
+
+	return user
+}
+
+func UserListFromEntities(users []entities.User) generated.UserList {
+	items := make([]generated.User, 0, len(users))
+	for _, u := range users {
+		items = append(items, UserFromEntity(u))
+	}
+
+	return generated.UserList{Items: items}
 }
@@ -2,33 +2,92 @@ package http
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
+	"net/netip"
 	"time"
 
 	"github.com/pkg/errors"
+	"go.uber.org/zap"
 )
 
 const (
-	requestReadTimeout    = 5 * time.Second
-	headerReadTimeout     = 2 * time.Second
-	responseWriteTimeout  = 5 * time.Second
-	connIdleTimeout       = 30 * time.Second
-	serverShutdownTimeout = 5 * time.Second
+	DefaultReadTimeout       = 5 * time.Second
+	DefaultReadHeaderTimeout = 2 * time.Second
+	DefaultWriteTimeout      = 5 * time.Second
+	DefaultIdleTimeout       = 30 * time.Second
+	DefaultShutdownTimeout   = 5 * time.Second
 )
 
 type Config struct {
 	BindAddress string
+	TLS         TLSConfig
+	RateLimit   RateLimitConfig
+	// TrustedProxies are the proxies whose X-Forwarded-For and X-Real-IP headers tell the client IP.
+	TrustedProxies []netip.Prefix
+	// IdempotencyKeyTTL is how long responses to requests with an Idempotency-Key are kept.
+	IdempotencyKeyTTL time.Duration
+	// AccessLogSampleRatio is the fraction of successful requests written to the access log.
+	AccessLogSampleRatio float64
+	// ShutdownDrainDelay is how long the server keeps serving after reporting not ready on shutdown,
+	// so that load balancers stop sending requests before the listener closes.
+	ShutdownDrainDelay time.Duration
+
+	// The timeouts of the server, zero values are replaced with the defaults.
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	ShutdownTimeout   time.Duration
+}
+
+func (c Config) withDefaults() Config {
+	if c.ReadTimeout == 0 {
+		c.ReadTimeout = DefaultReadTimeout
+	}
+	if c.ReadHeaderTimeout == 0 {
+		c.ReadHeaderTimeout = DefaultReadHeaderTimeout
+	}
+	if c.WriteTimeout == 0 {
+		c.WriteTimeout = DefaultWriteTimeout
+	}
+	if c.IdleTimeout == 0 {
+		c.IdleTimeout = DefaultIdleTimeout
+	}
+	if c.ShutdownTimeout == 0 {
+		c.ShutdownTimeout = DefaultShutdownTimeout
+	}
+
+	return c
 }
 
 type Server struct {
-	cfg    Config
-	server *http.Server
+	cfg          Config
+	log          *zap.SugaredLogger
+	server       *http.Server
+	certReloader *certificateReloader
+	readiness    *Readiness
 }
 
-func NewServer(config Config) *Server {
-	return &Server{cfg: config}
+type ServerOption func(s *Server)
+
+// WithDrainedReadiness makes the server report not ready at the start of shutdown.
+func WithDrainedReadiness(rd *Readiness) ServerOption {
+	return func(s *Server) {
+		s.readiness = rd
+	}
+}
+
+func NewServer(config Config, log *zap.SugaredLogger, options ...ServerOption) *Server {
+	s := &Server{cfg: config.withDefaults(), log: log}
+
+	for _, o := range options {
+		o(s)
+	}
+
+	return s
 }
 
 func (s *Server) Run(handler http.Handler) error {
@@ -37,12 +96,29 @@ func (s *Server) Run(handler http.Handler) error {
 		panic(fmt.Sprintf("failed to start listen on TCP socket: %s", err))
 	}
 
+	if s.cfg.TLS.Enabled() {
+		s.certReloader, err = newCertificateReloader(s.cfg.TLS)
+		if err != nil {
+			_ = listener.Close()
+
+			return errors.Wrap(err, "failed to load TLS certificates")
+		}
+
+		go s.certReloader.watch(func() {
+			s.log.Info("TLS certificates were reloaded")
+		}, func(err error) {
+			s.log.Errorf("failed to reload TLS certificates: %s", err)
+		})
+
+		listener = tls.NewListener(listener, s.certReloader.tlsConfig())
+	}
+
 	s.server = &http.Server{
 		Handler:           handler,
-		ReadTimeout:       requestReadTimeout,
-		ReadHeaderTimeout: headerReadTimeout,
-		WriteTimeout:      responseWriteTimeout,
-		IdleTimeout:       connIdleTimeout,
+		ReadTimeout:       s.cfg.ReadTimeout,
+		ReadHeaderTimeout: s.cfg.ReadHeaderTimeout,
+		WriteTimeout:      s.cfg.WriteTimeout,
+		IdleTimeout:       s.cfg.IdleTimeout,
 	}
 
 	err = s.server.Serve(listener)
@@ -54,7 +130,23 @@ func (s *Server) Run(handler http.Handler) error {
 }
 
 func (s *Server) Shutdown() error {
-	ctx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+	if s.certReloader != nil {
+		s.certReloader.stop()
+	}
+
+	if s.server == nil {
+		// the server failed to start
+		return nil
+	}
+
+	if s.readiness != nil {
+		s.readiness.Drain()
+
+		s.log.Infof("draining traffic for %s before shutdown", s.cfg.ShutdownDrainDelay)
+		time.Sleep(s.cfg.ShutdownDrainDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
 	defer cancel()
 
 	if err := s.server.Shutdown(ctx); err != nil {
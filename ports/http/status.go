@@ -0,0 +1,66 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+	"github.com/torwig/user-service/ports/http/requests"
+	"github.com/torwig/user-service/ports/http/responses"
+)
+
+// suspendUser bars the user from acting with their own credentials until they are activated again.
+func (h *Handler) suspendUser(w http.ResponseWriter, r *http.Request) {
+	h.changeUserStatus(w, r, entities.UserStatusSuspended)
+}
+
+// activateUser activates a pending user or lifts the suspension or the lock of the user.
+func (h *Handler) activateUser(w http.ResponseWriter, r *http.Request) {
+	h.changeUserStatus(w, r, entities.UserStatusActive)
+}
+
+func (h *Handler) changeUserStatus(w http.ResponseWriter, r *http.Request, status entities.UserStatus) {
+	id, err := identifierFromRequestURL(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	au, err := AuthenticatedUserFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if !au.CanChangeStatus(id) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	req, err := requests.NewChangeUserStatus(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	actor := fmt.Sprintf("user:%d", au.ID())
+
+	user, err := h.svc.ChangeUserStatus(r.Context(), id, req.ToChangeUserStatusParams(status, actor))
+	if err != nil {
+		h.logger(r).Errorf("failed to change status of user %d to %s: %s", id, status, err)
+
+		switch {
+		case errors.Is(err, entities.ErrUserNotFound):
+			w.WriteHeader(http.StatusNotFound)
+		case errors.Is(err, entities.ErrStatusTransition), errors.Is(err, entities.ErrStatusChanged):
+			w.WriteHeader(http.StatusConflict)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	responses.SendJSON(w, http.StatusOK, responses.UserFromEntity(user))
+}
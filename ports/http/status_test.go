@@ -0,0 +1,84 @@
+package http_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torwig/user-service/adapters/repository"
+	"github.com/torwig/user-service/entities"
+	"github.com/torwig/user-service/ports/http/generated"
+	"github.com/torwig/user-service/service"
+)
+
+func TestHandler_UserStatus(t *testing.T) {
+	repo := repository.NewInMemoryRepository()
+	svc := service.New(repo)
+	admin := entities.NewAuthenticatedUser(1000,
+		entities.CreateUsersGranted(), entities.ViewUsersGranted(), entities.UpdateUsersGranted())
+
+	w := serveAs(svc, admin, http.MethodPost, "/api/v1/users", `{"first_name":"John","last_name":"Doe",`+
+		`"phone_number":"+1234567890","address":"Springfield","status":"pending"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, generated.UserStatusPending, userOf(t, w).Status)
+
+	john := entities.NewAuthenticatedUser(1, entities.UpdateUsersGranted())
+
+	t.Run("invalid status on create", func(t *testing.T) {
+		w := serveAs(svc, admin, http.MethodPost, "/api/v1/users", `{"first_name":"Jane","last_name":"Doe",`+
+			`"phone_number":"+1234567891","address":"Springfield","status":"suspended"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("pending user can't be suspended", func(t *testing.T) {
+		w := serveAs(svc, admin, http.MethodPost, "/api/v1/users/1:suspend", "")
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("own status can't be changed", func(t *testing.T) {
+		w := serveAs(svc, john, http.MethodPost, "/api/v1/users/1:activate", "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("suspend user", func(t *testing.T) {
+		w := serveAs(svc, admin, http.MethodPost, "/api/v1/users/1:activate", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, generated.UserStatusActive, userOf(t, w).Status)
+
+		w = serveAs(svc, admin, http.MethodPost, "/api/v1/users/1:suspend", `{"reason":"spam"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, generated.UserStatusSuspended, userOf(t, w).Status)
+
+		changes, err := repo.ListStatusChanges(context.Background(), 1)
+		require.NoError(t, err)
+		require.Len(t, changes, 2)
+		assert.Equal(t, "spam", changes[1].Reason)
+		assert.Equal(t, "user:1000", changes[1].Actor)
+	})
+
+	t.Run("token of suspended user is rejected", func(t *testing.T) {
+		w := serveAs(svc, john, http.MethodGet, "/api/v1/users/1", "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = serveAs(svc, admin, http.MethodGet, "/api/v1/users/1", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("activate user", func(t *testing.T) {
+		w := serveAs(svc, admin, http.MethodPost, "/api/v1/users/1:activate", "")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = serveAs(svc, john, http.MethodGet, "/api/v1/users/1", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("token of deleted user is rejected", func(t *testing.T) {
+		require.NoError(t, svc.DeleteUser(context.Background(), 1, entities.StatusChangeCause{}))
+
+		w := serveAs(svc, john, http.MethodGet, "/api/v1/users/1", "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+}
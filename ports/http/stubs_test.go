@@ -0,0 +1,212 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/torwig/user-service/entities"
+	userhttp "github.com/torwig/user-service/ports/http"
+	"github.com/torwig/user-service/ports/http/generated"
+	"go.uber.org/zap"
+)
+
+// stubUserUpdatedAt is the time every user returned by stubUserService was updated at.
+var stubUserUpdatedAt = time.Date(2024, time.March, 1, 12, 30, 15, 500_000_000, time.UTC)
+
+type stubUserService struct{}
+
+func (stubUserService) CreateUser(_ context.Context, params entities.CreateUserParams) (entities.User, error) {
+	return entities.User{
+		ID:          1,
+		FirstName:   params.FirstName,
+		LastName:    params.LastName,
+		PhoneNumber: params.PhoneNumber,
+		Address:     params.Address,
+	}, nil
+}
+
+func (stubUserService) GetUser(_ context.Context, id int64) (entities.User, error) {
+	return entities.User{
+		ID:          id,
+		FirstName:   "John",
+		LastName:    "Doe",
+		PhoneNumber: "+1234567890",
+		Address:     "Springfield",
+		UpdatedAt:   stubUserUpdatedAt,
+	}, nil
+}
+
+func (stubUserService) GetUserByExternalID(_ context.Context, _, _ string) (entities.User, error) {
+	return entities.User{}, entities.ErrUserNotFound
+}
+
+func (stubUserService) ListUsers(_ context.Context, _ entities.ListUsersParams) ([]entities.User, error) {
+	return nil, nil
+}
+
+func (stubUserService) UpdateUser(_ context.Context, id int64, _ entities.UpdateUserParams) (entities.User, error) {
+	return entities.User{ID: id}, nil
+}
+
+func (stubUserService) ReplaceUser(
+	_ context.Context,
+	id int64,
+	_ entities.ReplaceUserParams,
+) (entities.User, error) {
+	return entities.User{ID: id}, nil
+}
+
+func (stubUserService) UpsertUser(
+	_ context.Context,
+	externalID entities.ExternalID,
+	_ entities.ReplaceUserParams,
+) (entities.User, bool, error) {
+	return entities.User{ID: 1, ExternalIDs: []entities.ExternalID{externalID}}, false, nil
+}
+
+func (stubUserService) DeleteUser(_ context.Context, _ int64, _ entities.StatusChangeCause) error {
+	return nil
+}
+
+func (stubUserService) SendEmailVerification(_ context.Context, _ int64) error {
+	return nil
+}
+
+func (stubUserService) VerifyEmail(_ context.Context, id int64, _ string) (entities.User, error) {
+	return entities.User{ID: id}, nil
+}
+
+func (stubUserService) SendPhoneVerification(_ context.Context, _ int64) error {
+	return nil
+}
+
+func (stubUserService) VerifyPhone(_ context.Context, id int64, _ string) (entities.User, error) {
+	return entities.User{ID: id}, nil
+}
+
+func (stubUserService) ChangeUserStatus(
+	_ context.Context,
+	id int64,
+	params entities.ChangeUserStatusParams,
+) (entities.User, error) {
+	return entities.User{ID: id, Status: params.Status}, nil
+}
+
+func (stubUserService) CheckUserStatus(_ context.Context, _ int64) error {
+	return nil
+}
+
+// fakeSMSSender keeps the codes instead of sending them, the last one by phone number.
+type fakeSMSSender struct {
+	mu    sync.Mutex
+	codes map[string]string
+}
+
+func (s *fakeSMSSender) SendPhoneVerification(_ context.Context, phoneNumber, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.codes == nil {
+		s.codes = make(map[string]string)
+	}
+
+	s.codes[phoneNumber] = code
+
+	return nil
+}
+
+func (s *fakeSMSSender) lastCode(phoneNumber string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.codes[phoneNumber]
+}
+
+type stubAPIKeyService struct{}
+
+func (stubAPIKeyService) AuthenticateAPIKey(_ context.Context, _ string) (*entities.AuthenticatedUser, error) {
+	return nil, entities.ErrInvalidAPIKey
+}
+
+func (stubAPIKeyService) CreateAPIKey(
+	_ context.Context,
+	_ entities.CreateAPIKeyParams,
+) (entities.APIKey, string, error) {
+	return entities.APIKey{}, "", nil
+}
+
+func (stubAPIKeyService) GetAPIKey(_ context.Context, _ int64) (entities.APIKey, error) {
+	return entities.APIKey{}, entities.ErrAPIKeyNotFound
+}
+
+func (stubAPIKeyService) ListAPIKeys(_ context.Context, _ int64) ([]entities.APIKey, error) {
+	return nil, nil
+}
+
+func (stubAPIKeyService) UpdateAPIKey(
+	_ context.Context,
+	_ int64,
+	_ entities.UpdateAPIKeyParams,
+) (entities.APIKey, error) {
+	return entities.APIKey{}, entities.ErrAPIKeyNotFound
+}
+
+func (stubAPIKeyService) RevokeAPIKey(_ context.Context, _ int64) error {
+	return nil
+}
+
+// stubAuthenticator accepts any token as the given user.
+type stubAuthenticator struct {
+	user *entities.AuthenticatedUser
+}
+
+func (a stubAuthenticator) ParseAccessToken(_ string) (*entities.AuthenticatedUser, error) {
+	return a.user, nil
+}
+
+// serveAs serves the request with a handler of svc that authenticates it as the caller.
+func serveAs(
+	svc userhttp.UserService,
+	caller *entities.AuthenticatedUser,
+	method, path, body string,
+	headers ...string,
+) *httptest.ResponseRecorder {
+	router := userhttp.NewHandler(svc, stubAPIKeyService{}, stubAuthenticator{user: caller},
+		zap.NewNop().Sugar()).Router()
+
+	return serve(router, method, path, body, headers...)
+}
+
+// serve serves a request with a bearer token and a JSON body, headers are pairs of names and values
+// that are set on top of them.
+func serve(router http.Handler, method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer token")
+	r.Header.Set("Content-Type", "application/json")
+
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	return w
+}
+
+// userOf decodes the user in the body of the response.
+func userOf(t *testing.T, w *httptest.ResponseRecorder) generated.User {
+	t.Helper()
+
+	var user generated.User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+
+	return user
+}
@@ -0,0 +1,176 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ErrNoCertificatesInBundle = errors.New("no certificates found in CA bundle")
+
+type TLSConfig struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+	// ClientCertUsers maps subjects of verified client certificates to users.
+	ClientCertUsers []ClientCertUser
+	// ReloadInterval is how often the files are checked for changes.
+	ReloadInterval time.Duration
+}
+
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" && c.KeyFile != ""
+}
+
+func (c TLSConfig) ClientAuthEnabled() bool {
+	return c.ClientCAFile != ""
+}
+
+// certificateReloader keeps the server certificate and the client CA bundle in memory
+// and reloads them when any of the underlying files is modified.
+type certificateReloader struct {
+	cfg      TLSConfig
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	clientCA *x509.CertPool
+	modTimes map[string]time.Time
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func newCertificateReloader(cfg TLSConfig) (*certificateReloader, error) {
+	cr := &certificateReloader{cfg: cfg, modTimes: make(map[string]time.Time), done: make(chan struct{})}
+
+	if _, err := cr.reloadIfChanged(); err != nil {
+		return nil, err
+	}
+
+	return cr, nil
+}
+
+func (cr *certificateReloader) files() []string {
+	files := []string{cr.cfg.CertFile, cr.cfg.KeyFile}
+	if cr.cfg.ClientAuthEnabled() {
+		files = append(files, cr.cfg.ClientCAFile)
+	}
+
+	return files
+}
+
+func (cr *certificateReloader) reloadIfChanged() (bool, error) {
+	modTimes := make(map[string]time.Time)
+	changed := false
+
+	for _, f := range cr.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to stat %s", f)
+		}
+
+		modTimes[f] = info.ModTime()
+		if !info.ModTime().Equal(cr.modTimes[f]) {
+			changed = true
+		}
+	}
+
+	if !changed {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cr.cfg.CertFile, cr.cfg.KeyFile)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to load server certificate")
+	}
+
+	var clientCA *x509.CertPool
+
+	if cr.cfg.ClientAuthEnabled() {
+		clientCA, err = loadCertPool(cr.cfg.ClientCAFile)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	cr.mu.Lock()
+	cr.cert = &cert
+	cr.clientCA = clientCA
+	cr.modTimes = modTimes
+	cr.mu.Unlock()
+
+	return true, nil
+}
+
+// watch polls the files until stop is called. A broken file (e.g. in the middle of
+// a rotation) keeps the previously loaded certificates in use, the error is passed to onError.
+func (cr *certificateReloader) watch(onReload func(), onError func(err error)) {
+	ticker := time.NewTicker(cr.cfg.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-cr.done:
+			return
+		case <-ticker.C:
+			reloaded, err := cr.reloadIfChanged()
+			if err != nil {
+				onError(err)
+			} else if reloaded {
+				onReload()
+			}
+		}
+	}
+}
+
+func (cr *certificateReloader) stop() {
+	cr.stopOnce.Do(func() { close(cr.done) })
+}
+
+func (cr *certificateReloader) tlsConfig() *tls.Config {
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cr.mu.RLock()
+			defer cr.mu.RUnlock()
+
+			return cr.cert, nil
+		},
+	}
+
+	if !cr.cfg.ClientAuthEnabled() {
+		return base
+	}
+
+	// the CA bundle can be rotated as well, so the config is assembled per connection
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		cr.mu.RLock()
+		defer cr.mu.RUnlock()
+
+		cfg := base.Clone()
+		cfg.GetConfigForClient = nil
+		cfg.ClientCAs = cr.clientCA
+		// clients without a certificate can still authenticate with a token or an API key
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+
+		return cfg, nil
+	}
+
+	return base
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(file) // #nosec G304 -- path comes from the service configuration
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read CA bundle")
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, ErrNoCertificatesInBundle
+	}
+
+	return pool, nil
+}
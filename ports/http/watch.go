@@ -0,0 +1,234 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+	"github.com/torwig/user-service/ports/http/responses"
+)
+
+const (
+	lastEventIDHeader = "Last-Event-ID"
+
+	userChangesPageSize = 100
+	// userChangesRescanWindow is how many change IDs behind the latest streamed one are read again. IDs are taken
+	// before the changes commit, so a change may become visible only after some of the changes following it.
+	userChangesRescanWindow = 100
+	watchKeepAliveInterval  = 15 * time.Second
+	watchRetryDelay         = 5 * time.Second
+)
+
+type UserChangeFeed interface {
+	// Watch returns a channel that receives a value whenever there may be new changes and a func to stop watching.
+	Watch() (<-chan struct{}, func())
+	UserChangesAfter(ctx context.Context, afterID int64, limit uint64) ([]entities.UserChange, error)
+	LatestUserChangeID(ctx context.Context) (int64, error)
+}
+
+// WithUserChangeFeed enables streaming of user changes as Server-Sent Events.
+func WithUserChangeFeed(feed UserChangeFeed) HandlerOption {
+	return func(h *Handler) {
+		h.userChanges = feed
+	}
+}
+
+// watchUsers streams the changes of the users the caller may view as Server-Sent Events. The ID of each event
+// is the ID of the change, so a client resumes after a reconnect by sending the last one in Last-Event-ID.
+// Without it, only the changes made from now on are streamed. Events don't necessarily come in the order of IDs,
+// a change that commits late is streamed as long as it's within the rescan window of the latest one.
+func (h *Handler) watchUsers(w http.ResponseWriter, r *http.Request) {
+	au, err := AuthenticatedUserFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	// the watcher has to be registered before reading the changes, so that none is missed in between
+	changed, unwatch := h.userChanges.Watch()
+	defer unwatch()
+
+	cursor, err := h.watchCursor(r)
+	if err != nil {
+		if errors.Is(err, errParameterNotInteger) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		h.logger(r).Errorf("failed to start watching users: %s", err)
+
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+
+	// the stream is open for as long as the client wants, unlike regular responses
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		h.logger(r).Errorf("failed to disable write timeout: %s", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// stops nginx from buffering the events
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", watchRetryDelay.Milliseconds()); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(watchKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		if err := h.sendUserChanges(w, r, au, cursor); err != nil {
+			if r.Context().Err() == nil {
+				h.logger(r).Errorf("failed to stream user changes: %s", err)
+			}
+
+			return
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case _, ok := <-changed:
+			if !ok {
+				// the service is shutting down
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// watchCursor returns the cursor the stream starts with.
+func (h *Handler) watchCursor(r *http.Request) (*userChangeCursor, error) {
+	lastEventID := r.Header.Get(lastEventIDHeader)
+	if lastEventID != "" {
+		id, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || id < 0 {
+			return nil, errParameterNotInteger
+		}
+
+		return newUserChangeCursor(id), nil
+	}
+
+	latest, err := h.userChanges.LatestUserChangeID(r.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	// the changes visible by now are skipped, the ones behind the latest that commit later are streamed
+	cursor := newUserChangeCursor(max(latest-userChangesRescanWindow, 0))
+
+	changes, err := h.userChanges.UserChangesAfter(r.Context(), cursor.start, userChangesRescanWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, change := range changes {
+		if change.ID <= latest {
+			cursor.advance(change.ID)
+		}
+	}
+
+	cursor.latest = max(cursor.latest, latest)
+
+	return cursor, nil
+}
+
+// userChangeCursor tracks the changes streamed within the rescan window behind the latest one.
+type userChangeCursor struct {
+	// start is the ID the stream started after, the changes up to it are never streamed.
+	start  int64
+	latest int64
+	sent   map[int64]struct{}
+}
+
+func newUserChangeCursor(start int64) *userChangeCursor {
+	return &userChangeCursor{start: start, latest: start, sent: make(map[int64]struct{})}
+}
+
+// rescanFrom returns the ID the changes are read after.
+func (c *userChangeCursor) rescanFrom() int64 {
+	return max(c.latest-userChangesRescanWindow, c.start)
+}
+
+// advance reports whether the change hasn't been streamed yet and remembers it.
+func (c *userChangeCursor) advance(id int64) bool {
+	if _, ok := c.sent[id]; ok || id <= c.start {
+		return false
+	}
+
+	c.sent[id] = struct{}{}
+	c.latest = max(c.latest, id)
+
+	return true
+}
+
+// forget drops the changes that have fallen behind the rescan window.
+func (c *userChangeCursor) forget() {
+	from := c.rescanFrom()
+
+	for id := range c.sent {
+		if id <= from {
+			delete(c.sent, id)
+		}
+	}
+}
+
+// sendUserChanges writes all the changes within the rescan window and after it that haven't been streamed yet
+// and the user may view.
+func (h *Handler) sendUserChanges(
+	w http.ResponseWriter,
+	r *http.Request,
+	au *entities.AuthenticatedUser,
+	cursor *userChangeCursor,
+) error {
+	defer cursor.forget()
+
+	after := cursor.rescanFrom()
+
+	for {
+		changes, err := h.userChanges.UserChangesAfter(r.Context(), after, userChangesPageSize)
+		if err != nil {
+			return err
+		}
+
+		for _, change := range changes {
+			after = change.ID
+
+			if !cursor.advance(change.ID) || !au.CanViewUser(change.User.ID) {
+				continue
+			}
+
+			data, err := json.Marshal(responses.UserFromEntity(change.User))
+			if err != nil {
+				return errors.Wrap(err, "failed to encode user")
+			}
+
+			_, err = fmt.Fprintf(w, "id: %d\nevent: user.%s\ndata: %s\n\n", change.ID, change.Type, data)
+			if err != nil {
+				return errors.Wrap(err, "failed to write event")
+			}
+		}
+
+		if len(changes) < userChangesPageSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package http_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torwig/user-service/entities"
+	userhttp "github.com/torwig/user-service/ports/http"
+	"github.com/torwig/user-service/service"
+	"go.uber.org/zap"
+)
+
+type fakeUserChangeRepository struct {
+	mu      sync.Mutex
+	changes []entities.UserChange
+	notify  chan int64
+}
+
+// add makes the change visible, the changes are kept in the order of IDs regardless of when they're added.
+func (r *fakeUserChangeRepository) add(change entities.UserChange) {
+	r.mu.Lock()
+	r.changes = append(r.changes, change)
+	sort.Slice(r.changes, func(i, j int) bool { return r.changes[i].ID < r.changes[j].ID })
+	r.mu.Unlock()
+
+	r.notify <- change.ID
+}
+
+func (r *fakeUserChangeRepository) UserChangesAfter(
+	_ context.Context,
+	afterID int64,
+	limit uint64,
+) ([]entities.UserChange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var changes []entities.UserChange
+
+	for _, c := range r.changes {
+		if c.ID > afterID && uint64(len(changes)) < limit {
+			changes = append(changes, c)
+		}
+	}
+
+	return changes, nil
+}
+
+func (r *fakeUserChangeRepository) LatestUserChangeID(_ context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.changes[len(r.changes)-1].ID, nil
+}
+
+func (r *fakeUserChangeRepository) ListenUserChanges(ctx context.Context, notify func(changeID int64)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case id := <-r.notify:
+			notify(id)
+		}
+	}
+}
+
+func TestHandler_WatchUsers(t *testing.T) {
+	repo := &fakeUserChangeRepository{
+		changes: []entities.UserChange{
+			{ID: 1, Type: entities.UserCreated, User: entities.User{ID: 7, FirstName: "Ann"}},
+			{ID: 2, Type: entities.UserUpdated, User: entities.User{ID: 8, FirstName: "Bob"}},
+			{ID: 3, Type: entities.UserUpdated, User: entities.User{ID: 7, FirstName: "Anna"}},
+		},
+		notify: make(chan int64),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	feed := service.NewUserChangeFeed(repo)
+	go feed.Run(ctx)
+
+	// the user may view only themselves
+	auth := stubAuthenticator{user: entities.NewAuthenticatedUser(7)}
+	router := userhttp.NewHandler(stubUserService{}, stubAPIKeyService{}, auth, zap.NewNop().Sugar(),
+		userhttp.WithUserChangeFeed(feed)).Router()
+
+	srv := httptest.NewUnstartedServer(router)
+	srv.Config.WriteTimeout = 100 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/v1/users:watch", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Last-Event-ID", "0")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	events := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+
+		var event []string
+		for scanner.Scan() {
+			if scanner.Text() != "" {
+				event = append(event, scanner.Text())
+				continue
+			}
+
+			if len(event) > 0 && strings.HasPrefix(event[0], "id: ") {
+				events <- strings.Join(event, "\n")
+			}
+
+			event = nil
+		}
+	}()
+
+	nextEvent := func() string {
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event was received")
+			return ""
+		}
+	}
+
+	userEvent := func(id int, changeType, firstName string) string {
+		return fmt.Sprintf("id: %d\nevent: user.%s\n"+
+			`data: {"address":"","attributes":{},"created_at":"0001-01-01T00:00:00Z","external_ids":[],"first_name":%q,"id":7,`+
+			`"last_name":"","phone_number":"","status":"","updated_at":"0001-01-01T00:00:00Z"}`, id, changeType, firstName)
+	}
+
+	assert.Equal(t, userEvent(1, "created", "Ann"), nextEvent())
+	assert.Equal(t, userEvent(3, "updated", "Anna"), nextEvent(), "changes of other users must be skipped")
+
+	// the stream outlives the write timeout
+	time.Sleep(200 * time.Millisecond)
+
+	repo.add(entities.UserChange{ID: 4, Type: entities.UserDeleted, User: entities.User{ID: 7, FirstName: "Anna"}})
+	assert.Equal(t, userEvent(4, "deleted", "Anna"), nextEvent())
+
+	// the change with ID 5 commits after the one with ID 6
+	repo.add(entities.UserChange{ID: 6, Type: entities.UserUpdated, User: entities.User{ID: 7, FirstName: "Anne"}})
+	assert.Equal(t, userEvent(6, "updated", "Anne"), nextEvent())
+
+	repo.add(entities.UserChange{ID: 5, Type: entities.UserUpdated, User: entities.User{ID: 7, FirstName: "Ann"}})
+	assert.Equal(t, userEvent(5, "updated", "Ann"), nextEvent(), "late change must be streamed")
+}
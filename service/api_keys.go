@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+	"github.com/torwig/user-service/log"
+)
+
+const (
+	apiKeyMarker             = "usk"
+	apiKeySeparator          = "_"
+	apiKeyPrefixBytes        = 6
+	apiKeySecretBytes        = 32
+	apiKeyLastUsedResolution = time.Minute
+)
+
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, params entities.StoreAPIKeyParams) (entities.APIKey, error)
+	GetAPIKey(ctx context.Context, id int64) (entities.APIKey, error)
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (entities.APIKey, error)
+	ListAPIKeys(ctx context.Context, userID int64) ([]entities.APIKey, error)
+	UpdateAPIKey(ctx context.Context, id int64, params entities.UpdateAPIKeyParams) (entities.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64) error
+	TouchAPIKey(ctx context.Context, id int64, usedAt time.Time) error
+}
+
+type APIKeyService struct {
+	keyRepo  APIKeyRepository
+	userRepo UserRepository
+}
+
+func NewAPIKeyService(keyRepo APIKeyRepository, userRepo UserRepository) *APIKeyService {
+	return &APIKeyService{keyRepo: keyRepo, userRepo: userRepo}
+}
+
+// CreateAPIKey generates a new key and returns it along with its plaintext value.
+// The plaintext value can't be recovered later, only its hash is stored.
+func (s *APIKeyService) CreateAPIKey(
+	ctx context.Context,
+	params entities.CreateAPIKeyParams,
+) (entities.APIKey, string, error) {
+	ctx, span := startSpan(ctx, "APIKeyService.CreateAPIKey")
+	defer span.End()
+
+	if err := validateScopes(params.Scopes); err != nil {
+		return entities.APIKey{}, "", err
+	}
+
+	prefix, err := randomString(apiKeyPrefixBytes, hex.EncodeToString)
+	if err != nil {
+		return entities.APIKey{}, "", errors.Wrap(err, "failed to generate API key prefix")
+	}
+
+	secret, err := randomString(apiKeySecretBytes, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return entities.APIKey{}, "", errors.Wrap(err, "failed to generate API key secret")
+	}
+
+	plaintext := strings.Join([]string{apiKeyMarker, prefix, secret}, apiKeySeparator)
+
+	key, err := s.keyRepo.CreateAPIKey(ctx, entities.StoreAPIKeyParams{
+		CreateAPIKeyParams: params,
+		Prefix:             prefix,
+		KeyHash:            hashAPIKey(plaintext),
+	})
+	if err != nil {
+		return entities.APIKey{}, "", errors.Wrap(err, "failed to create API key in repository")
+	}
+
+	return key, plaintext, nil
+}
+
+func (s *APIKeyService) GetAPIKey(ctx context.Context, id int64) (entities.APIKey, error) {
+	ctx, span := startSpan(ctx, "APIKeyService.GetAPIKey")
+	defer span.End()
+
+	key, err := s.keyRepo.GetAPIKey(ctx, id)
+	if err != nil {
+		return entities.APIKey{}, errors.Wrap(err, "failed to get API key from repository")
+	}
+
+	return key, nil
+}
+
+func (s *APIKeyService) ListAPIKeys(ctx context.Context, userID int64) ([]entities.APIKey, error) {
+	ctx, span := startSpan(ctx, "APIKeyService.ListAPIKeys")
+	defer span.End()
+
+	keys, err := s.keyRepo.ListAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list API keys in repository")
+	}
+
+	return keys, nil
+}
+
+func (s *APIKeyService) UpdateAPIKey(
+	ctx context.Context,
+	id int64,
+	params entities.UpdateAPIKeyParams,
+) (entities.APIKey, error) {
+	ctx, span := startSpan(ctx, "APIKeyService.UpdateAPIKey")
+	defer span.End()
+
+	if params.Scopes != nil {
+		if err := validateScopes(*params.Scopes); err != nil {
+			return entities.APIKey{}, err
+		}
+	}
+
+	existingKey, err := s.keyRepo.GetAPIKey(ctx, id)
+	if err != nil {
+		return entities.APIKey{}, errors.Wrap(err, "failed to get API key from repository")
+	}
+
+	if existingKey.IsRevoked() {
+		return entities.APIKey{}, entities.ErrAPIKeyRevoked
+	}
+
+	updatedKey, err := s.keyRepo.UpdateAPIKey(ctx, id, params)
+	if err != nil {
+		return entities.APIKey{}, errors.Wrap(err, "failed to update API key in repository")
+	}
+
+	return updatedKey, nil
+}
+
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, id int64) error {
+	ctx, span := startSpan(ctx, "APIKeyService.RevokeAPIKey")
+	defer span.End()
+
+	err := s.keyRepo.RevokeAPIKey(ctx, id)
+	if err != nil {
+		return errors.Wrap(err, "failed to revoke API key in repository")
+	}
+
+	return nil
+}
+
+// AuthenticateAPIKey resolves a plaintext key to the user it was issued for,
+// limited to the permissions mapped from the key's scopes. Keys of deleted, suspended or locked users are rejected.
+func (s *APIKeyService) AuthenticateAPIKey(ctx context.Context, plaintext string) (*entities.AuthenticatedUser, error) {
+	ctx, span := startSpan(ctx, "APIKeyService.AuthenticateAPIKey")
+	defer span.End()
+
+	parts := strings.SplitN(plaintext, apiKeySeparator, 3)
+	if len(parts) != 3 || parts[0] != apiKeyMarker {
+		return nil, entities.ErrInvalidAPIKey
+	}
+
+	key, err := s.keyRepo.GetAPIKeyByPrefix(ctx, parts[1])
+	if err != nil {
+		if errors.Is(err, entities.ErrAPIKeyNotFound) {
+			return nil, entities.ErrInvalidAPIKey
+		}
+
+		return nil, errors.Wrap(err, "failed to get API key from repository")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(hashAPIKey(plaintext))) != 1 {
+		return nil, entities.ErrInvalidAPIKey
+	}
+
+	now := time.Now().UTC()
+
+	if key.IsRevoked() {
+		return nil, entities.ErrAPIKeyRevoked
+	}
+
+	if key.IsExpired(now) {
+		return nil, entities.ErrAPIKeyExpired
+	}
+
+	owner, err := s.userRepo.Get(ctx, key.UserID)
+	if err != nil {
+		if errors.Is(err, entities.ErrUserNotFound) {
+			return nil, entities.ErrInvalidAPIKey
+		}
+
+		return nil, errors.Wrap(err, "failed to get user from repository")
+	}
+
+	if owner.IsDeleted() || !owner.Status.CanAuthenticate() {
+		return nil, entities.ErrUserSuspended
+	}
+
+	permissions, err := key.Permissions()
+	if err != nil {
+		return nil, err
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyLastUsedResolution {
+		// last-used tracking is best effort and must not reject a valid key
+		if err := s.keyRepo.TouchAPIKey(ctx, key.ID, now); err != nil {
+			log.FromContext(ctx).Warnf("failed to update last use of API key %d: %s", key.ID, err)
+		}
+	}
+
+	return entities.NewAuthenticatedUser(key.UserID, append(permissions, entities.AuthenticatedWithAPIKey())...), nil
+}
+
+func validateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if _, err := entities.APIKeyScope(scope).Permission(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func hashAPIKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(n int, encode func([]byte) string) (string, error) {
+	b := make([]byte, n)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return encode(b), nil
+}
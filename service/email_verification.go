@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+)
+
+const (
+	DefaultEmailVerificationTTL = 24 * time.Hour
+
+	// emailVerificationAudience tells verification tokens from access tokens should they share the secret.
+	emailVerificationAudience = "email_verification"
+)
+
+// Notifier delivers messages to users, e.g. by email.
+type Notifier interface {
+	// SendEmailVerification sends the token proving the ownership of the email to that email.
+	SendEmailVerification(ctx context.Context, email, token string) error
+}
+
+type EmailVerificationConfig struct {
+	// Secret signs the verification tokens, emails can't be verified without it.
+	Secret []byte
+	// TTL is how long a verification token can be used.
+	TTL time.Duration
+}
+
+func (c EmailVerificationConfig) Enabled() bool {
+	return len(c.Secret) > 0
+}
+
+type emailVerificationClaims struct {
+	jwt.RegisteredClaims
+	Email string `json:"email"`
+}
+
+type emailVerifier struct {
+	cfg      EmailVerificationConfig
+	notifier Notifier
+	now      func() time.Time
+}
+
+// WithEmailVerification lets users verify their emails with signed tokens sent through the notifier.
+func WithEmailVerification(cfg EmailVerificationConfig, notifier Notifier) Option {
+	if cfg.TTL == 0 {
+		cfg.TTL = DefaultEmailVerificationTTL
+	}
+
+	return func(s *Service) {
+		s.emailVerifier = &emailVerifier{cfg: cfg, notifier: notifier, now: time.Now}
+	}
+}
+
+// SendEmailVerification sends a token to the email of the user, the token is then given to VerifyEmail.
+func (s *Service) SendEmailVerification(ctx context.Context, id int64) error {
+	ctx, span := startSpan(ctx, "Service.SendEmailVerification")
+	defer span.End()
+
+	if s.emailVerifier == nil {
+		return entities.ErrVerificationDisabled
+	}
+
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if user.Email == "" {
+		return entities.ErrNoEmail
+	}
+
+	if user.IsEmailVerified() {
+		return entities.ErrEmailAlreadyVerified
+	}
+
+	token, err := s.emailVerifier.issue(user)
+	if err != nil {
+		return err
+	}
+
+	if err := s.emailVerifier.notifier.SendEmailVerification(ctx, user.Email, token); err != nil {
+		return errors.Wrap(err, "failed to send email verification")
+	}
+
+	return nil
+}
+
+// VerifyEmail marks the email of the user as verified if the token was issued for the current email of the user.
+func (s *Service) VerifyEmail(ctx context.Context, id int64, token string) (entities.User, error) {
+	ctx, span := startSpan(ctx, "Service.VerifyEmail")
+	defer span.End()
+
+	if s.emailVerifier == nil {
+		return entities.User{}, entities.ErrVerificationDisabled
+	}
+
+	email, err := s.emailVerifier.parse(id, token)
+	if err != nil {
+		return entities.User{}, err
+	}
+
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return entities.User{}, err
+	}
+
+	// the token of a previous email doesn't verify the new one
+	if user.Email != email {
+		return entities.User{}, entities.ErrInvalidVerificationToken
+	}
+
+	if user.IsEmailVerified() {
+		return user, nil
+	}
+
+	user, err = s.userRepo.VerifyEmail(ctx, id, email)
+	if err != nil {
+		if errors.Is(err, entities.ErrEmailChanged) {
+			return entities.User{}, entities.ErrInvalidVerificationToken
+		}
+
+		return entities.User{}, errors.Wrap(err, "failed to verify email in repository")
+	}
+
+	s.observer.UserUpdated()
+
+	return user, nil
+}
+
+func (v *emailVerifier) issue(user entities.User) (string, error) {
+	now := v.now()
+
+	claims := emailVerificationClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatInt(user.ID, 10),
+			Audience:  jwt.ClaimStrings{emailVerificationAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(v.cfg.TTL)),
+		},
+		Email: user.Email,
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(v.cfg.Secret)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to sign email verification token")
+	}
+
+	return token, nil
+}
+
+// parse returns the email the token was issued for, if it was issued for the user and hasn't expired.
+func (v *emailVerifier) parse(id int64, token string) (string, error) {
+	var claims emailVerificationClaims
+
+	parsed, err := jwt.ParseWithClaims(token, &claims,
+		func(*jwt.Token) (interface{}, error) {
+			return v.cfg.Secret, nil
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithAudience(emailVerificationAudience),
+		jwt.WithSubject(strconv.FormatInt(id, 10)),
+		jwt.WithTimeFunc(v.now),
+	)
+	if err != nil || !parsed.Valid || claims.ExpiresAt == nil || claims.Email == "" {
+		return "", entities.ErrInvalidVerificationToken
+	}
+
+	return claims.Email, nil
+}
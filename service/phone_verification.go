@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+)
+
+const (
+	DefaultPhoneVerificationTTL         = 10 * time.Minute
+	DefaultPhoneVerificationMaxAttempts = 5
+
+	phoneVerificationCodeDigits = 6
+)
+
+type PhoneVerificationRepository interface {
+	// SavePhoneVerification stores the code sent to the user, replacing the previous one along with its attempts.
+	SavePhoneVerification(ctx context.Context, params entities.PhoneVerification) (entities.PhoneVerification, error)
+	GetPhoneVerification(ctx context.Context, userID int64) (entities.PhoneVerification, error)
+	// CountPhoneVerificationAttempt records an attempt to enter the code and returns the verification with it.
+	CountPhoneVerificationAttempt(ctx context.Context, userID int64) (entities.PhoneVerification, error)
+	DeletePhoneVerification(ctx context.Context, userID int64) error
+}
+
+// SMSSender delivers text messages to phone numbers.
+type SMSSender interface {
+	// SendPhoneVerification sends the one-time code proving the ownership of the phone number to that number.
+	SendPhoneVerification(ctx context.Context, phoneNumber, code string) error
+}
+
+type PhoneVerificationConfig struct {
+	// Secret keys the hashes of the codes, phone numbers can't be verified without it.
+	Secret []byte
+	// TTL is how long a code can be entered.
+	TTL time.Duration
+	// MaxAttempts is how many times a code can be entered before a new one has to be sent.
+	MaxAttempts int
+	// ResendInterval is how long a user waits before another code is sent to the same number, zero doesn't limit it.
+	ResendInterval time.Duration
+}
+
+func (c PhoneVerificationConfig) Enabled() bool {
+	return len(c.Secret) > 0
+}
+
+type phoneVerifier struct {
+	cfg    PhoneVerificationConfig
+	repo   PhoneVerificationRepository
+	sender SMSSender
+	now    func() time.Time
+}
+
+// WithPhoneVerification lets users verify their phone numbers with one-time codes sent by the sender.
+func WithPhoneVerification(cfg PhoneVerificationConfig, repo PhoneVerificationRepository, sender SMSSender) Option {
+	if cfg.TTL == 0 {
+		cfg.TTL = DefaultPhoneVerificationTTL
+	}
+
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = DefaultPhoneVerificationMaxAttempts
+	}
+
+	return func(s *Service) {
+		s.phoneVerifier = &phoneVerifier{cfg: cfg, repo: repo, sender: sender, now: time.Now}
+	}
+}
+
+// SendPhoneVerification sends a one-time code to the phone number of the user, the code is then given to VerifyPhone.
+// The code replaces the one sent before, which can't be used anymore.
+func (s *Service) SendPhoneVerification(ctx context.Context, id int64) error {
+	ctx, span := startSpan(ctx, "Service.SendPhoneVerification")
+	defer span.End()
+
+	if s.phoneVerifier == nil {
+		return entities.ErrVerificationDisabled
+	}
+
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if user.IsPhoneVerified() {
+		return entities.ErrPhoneAlreadyVerified
+	}
+
+	now := s.phoneVerifier.now()
+
+	previous, err := s.phoneVerifier.repo.GetPhoneVerification(ctx, id)
+	switch {
+	case errors.Is(err, entities.ErrPhoneVerificationNotFound):
+	case err != nil:
+		return errors.Wrap(err, "failed to get phone verification from repository")
+	case previous.PhoneNumber == user.PhoneNumber &&
+		now.Before(previous.CreatedAt.Add(s.phoneVerifier.cfg.ResendInterval)):
+		return entities.ErrVerificationCodeRecentlySent
+	}
+
+	code, err := generateVerificationCode()
+	if err != nil {
+		return errors.Wrap(err, "failed to generate verification code")
+	}
+
+	_, err = s.phoneVerifier.repo.SavePhoneVerification(ctx, entities.PhoneVerification{
+		UserID:      id,
+		PhoneNumber: user.PhoneNumber,
+		CodeHash:    s.phoneVerifier.hash(id, user.PhoneNumber, code),
+		ExpiresAt:   now.Add(s.phoneVerifier.cfg.TTL),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to save phone verification in repository")
+	}
+
+	if err := s.phoneVerifier.sender.SendPhoneVerification(ctx, user.PhoneNumber, code); err != nil {
+		return errors.Wrap(err, "failed to send phone verification")
+	}
+
+	return nil
+}
+
+// VerifyPhone marks the phone number of the user as verified if the code is the last one sent to the current number.
+// Every attempt counts, whether the code is right or not, and the code is used up once it verifies the number.
+func (s *Service) VerifyPhone(ctx context.Context, id int64, code string) (entities.User, error) {
+	ctx, span := startSpan(ctx, "Service.VerifyPhone")
+	defer span.End()
+
+	if s.phoneVerifier == nil {
+		return entities.User{}, entities.ErrVerificationDisabled
+	}
+
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return entities.User{}, err
+	}
+
+	verification, err := s.phoneVerifier.repo.CountPhoneVerificationAttempt(ctx, id)
+	if err != nil {
+		if errors.Is(err, entities.ErrPhoneVerificationNotFound) {
+			return entities.User{}, entities.ErrInvalidVerificationCode
+		}
+
+		return entities.User{}, errors.Wrap(err, "failed to count phone verification attempt in repository")
+	}
+
+	if verification.Attempts > s.phoneVerifier.cfg.MaxAttempts {
+		return entities.User{}, entities.ErrTooManyVerificationAttempts
+	}
+
+	// the code sent to a previous number doesn't verify the current one
+	if verification.IsExpired(s.phoneVerifier.now()) || verification.PhoneNumber != user.PhoneNumber ||
+		!hmac.Equal([]byte(verification.CodeHash), []byte(s.phoneVerifier.hash(id, user.PhoneNumber, code))) {
+		return entities.User{}, entities.ErrInvalidVerificationCode
+	}
+
+	user, err = s.userRepo.VerifyPhone(ctx, id, verification.PhoneNumber)
+	if err != nil {
+		if errors.Is(err, entities.ErrPhoneChanged) {
+			return entities.User{}, entities.ErrInvalidVerificationCode
+		}
+
+		return entities.User{}, errors.Wrap(err, "failed to verify phone number in repository")
+	}
+
+	s.observer.UserUpdated()
+
+	if err := s.phoneVerifier.repo.DeletePhoneVerification(ctx, id); err != nil {
+		return entities.User{}, errors.Wrap(err, "failed to delete phone verification from repository")
+	}
+
+	return user, nil
+}
+
+// hash binds the code to the user and the phone number it was sent to. The hash is keyed,
+// as a short code could be found by hashing every possible one otherwise.
+func (v *phoneVerifier) hash(userID int64, phoneNumber, code string) string {
+	mac := hmac.New(sha256.New, v.cfg.Secret)
+	mac.Write([]byte(strconv.FormatInt(userID, 10) + ":" + phoneNumber + ":" + code))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// generateVerificationCode returns a random code of decimal digits, leading zeros included.
+func generateVerificationCode() (string, error) {
+	limit := big.NewInt(1)
+	for i := 0; i < phoneVerificationCodeDigits; i++ {
+		limit.Mul(limit, big.NewInt(10))
+	}
+
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%0*d", phoneVerificationCodeDigits, n), nil
+}
@@ -10,28 +10,110 @@ import (
 type UserRepository interface {
 	Create(ctx context.Context, params entities.CreateUserParams) (entities.User, error)
 	Get(ctx context.Context, id int64) (entities.User, error)
+	GetByExternalID(ctx context.Context, source, externalID string) (entities.User, error)
 	Update(ctx context.Context, id int64, params entities.UpdateUserParams) (entities.User, error)
-	Delete(ctx context.Context, id int64) error
+	Replace(ctx context.Context, id int64, params entities.ReplaceUserParams) (entities.User, error)
+	// Upsert replaces the user the external ID belongs to or creates a new user with it,
+	// the flag reports whether it was created.
+	Upsert(
+		ctx context.Context,
+		externalID entities.ExternalID,
+		params entities.ReplaceUserParams,
+	) (entities.User, bool, error)
+	// Delete and Restore record the change of the status caused by them.
+	Delete(ctx context.Context, id int64, cause entities.StatusChangeCause) error
+	List(ctx context.Context, params entities.ListUsersParams) ([]entities.User, error)
+	Restore(ctx context.Context, id int64, cause entities.StatusChangeCause) (entities.User, error)
+	// VerifyEmail marks the email as verified if the user still has it, ErrEmailChanged is returned otherwise.
+	VerifyEmail(ctx context.Context, id int64, email string) (entities.User, error)
+	// VerifyPhone marks the phone number as verified if the user still has it, ErrPhoneChanged is returned otherwise.
+	VerifyPhone(ctx context.Context, id int64, phoneNumber string) (entities.User, error)
+	// ChangeStatus changes the status if the user still has the given one, ErrStatusChanged is returned otherwise.
+	ChangeStatus(
+		ctx context.Context,
+		id int64,
+		from entities.UserStatus,
+		params entities.ChangeUserStatusParams,
+	) (entities.User, error)
+	ListStatusChanges(ctx context.Context, userID int64) ([]entities.UserStatusChange, error)
+}
+
+// Observer is notified about successful changes of users, e.g. to count them.
+type Observer interface {
+	UserCreated()
+	UserUpdated()
+	UserDeleted()
+}
+
+// AttributesValidator checks the custom attributes of a user, e.g. against a JSON Schema.
+// It returns an error wrapping entities.ErrInvalidAttributes if they aren't valid.
+type AttributesValidator interface {
+	ValidateAttributes(attributes entities.Attributes) error
 }
 
 type Service struct {
-	userRepo UserRepository
+	userRepo      UserRepository
+	observer      Observer
+	attributes    AttributesValidator
+	emailVerifier *emailVerifier
+	phoneVerifier *phoneVerifier
+}
+
+type Option func(s *Service)
+
+func WithObserver(o Observer) Option {
+	return func(s *Service) {
+		s.observer = o
+	}
+}
+
+// WithAttributesValidator validates the attributes of every created or changed user, any are accepted without it.
+func WithAttributesValidator(v AttributesValidator) Option {
+	return func(s *Service) {
+		s.attributes = v
+	}
 }
 
-func New(userRepo UserRepository) *Service {
-	return &Service{userRepo: userRepo}
+func New(userRepo UserRepository, options ...Option) *Service {
+	s := &Service{userRepo: userRepo, observer: noopObserver{}}
+
+	for _, o := range options {
+		o(s)
+	}
+
+	return s
 }
 
 func (s *Service) CreateUser(ctx context.Context, params entities.CreateUserParams) (entities.User, error) {
+	ctx, span := startSpan(ctx, "Service.CreateUser")
+	defer span.End()
+
+	if err := s.validateAttributes(params.Attributes); err != nil {
+		return entities.User{}, err
+	}
+
+	switch params.Status {
+	case "", entities.UserStatusPending, entities.UserStatusActive:
+	default:
+		return entities.User{}, errors.Wrap(entities.ErrInvalidStatus, string(params.Status))
+	}
+
+	params.Email = entities.NormalizeEmail(params.Email)
+
 	user, err := s.userRepo.Create(ctx, params)
 	if err != nil {
 		return user, errors.Wrap(err, "failed to create user in repository")
 	}
 
+	s.observer.UserCreated()
+
 	return user, nil
 }
 
 func (s *Service) GetUser(ctx context.Context, id int64) (entities.User, error) {
+	ctx, span := startSpan(ctx, "Service.GetUser")
+	defer span.End()
+
 	user, err := s.userRepo.Get(ctx, id)
 	if err != nil {
 		return entities.User{}, errors.Wrap(err, "failed to get user from repository")
@@ -44,7 +126,27 @@ func (s *Service) GetUser(ctx context.Context, id int64) (entities.User, error)
 	return user, nil
 }
 
+// GetUserByExternalID returns the user the external ID of the source belongs to.
+func (s *Service) GetUserByExternalID(ctx context.Context, source, externalID string) (entities.User, error) {
+	ctx, span := startSpan(ctx, "Service.GetUserByExternalID")
+	defer span.End()
+
+	user, err := s.userRepo.GetByExternalID(ctx, source, externalID)
+	if err != nil {
+		return entities.User{}, errors.Wrap(err, "failed to get user by external ID from repository")
+	}
+
+	if user.IsDeleted() {
+		return entities.User{}, entities.ErrUserNotFound
+	}
+
+	return user, nil
+}
+
 func (s *Service) UpdateUser(ctx context.Context, id int64, params entities.UpdateUserParams) (entities.User, error) {
+	ctx, span := startSpan(ctx, "Service.UpdateUser")
+	defer span.End()
+
 	existingUser, err := s.userRepo.Get(ctx, id)
 	if err != nil {
 		return entities.User{}, errors.Wrap(err, "failed to get user from repository")
@@ -54,24 +156,169 @@ func (s *Service) UpdateUser(ctx context.Context, id int64, params entities.Upda
 		return entities.User{}, entities.ErrUserNotFound
 	}
 
+	if params.Attributes != nil {
+		err := s.validateAttributes(entities.MergeAttributes(existingUser.Attributes, *params.Attributes))
+		if err != nil {
+			return entities.User{}, err
+		}
+	}
+
+	if params.Email != nil {
+		email := entities.NormalizeEmail(*params.Email)
+		params.Email = &email
+	}
+
 	updatedUser, err := s.userRepo.Update(ctx, id, params)
 	if err != nil {
 		return entities.User{}, errors.Wrap(err, "failed to update user in repository")
 	}
 
+	s.observer.UserUpdated()
+
 	return updatedUser, nil
 }
 
-func (s *Service) DeleteUser(ctx context.Context, id int64) error {
+// ReplaceUser sets all the mutable fields of an existing user.
+func (s *Service) ReplaceUser(
+	ctx context.Context,
+	id int64,
+	params entities.ReplaceUserParams,
+) (entities.User, error) {
+	ctx, span := startSpan(ctx, "Service.ReplaceUser")
+	defer span.End()
+
+	if err := s.validateAttributes(params.Attributes); err != nil {
+		return entities.User{}, err
+	}
+
+	existingUser, err := s.userRepo.Get(ctx, id)
+	if err != nil {
+		return entities.User{}, errors.Wrap(err, "failed to get user from repository")
+	}
+
+	if existingUser.IsDeleted() {
+		return entities.User{}, entities.ErrUserNotFound
+	}
+
+	params.Email = entities.NormalizeEmail(params.Email)
+
+	replacedUser, err := s.userRepo.Replace(ctx, id, params)
+	if err != nil {
+		return entities.User{}, errors.Wrap(err, "failed to replace user in repository")
+	}
+
+	s.observer.UserUpdated()
+
+	return replacedUser, nil
+}
+
+// UpsertUser replaces the user the external ID belongs to or creates a new user with it, the flag reports whether
+// it was created. The external ID of a deleted user can't be reused, ErrUserDeleted is returned.
+func (s *Service) UpsertUser(
+	ctx context.Context,
+	externalID entities.ExternalID,
+	params entities.ReplaceUserParams,
+) (entities.User, bool, error) {
+	ctx, span := startSpan(ctx, "Service.UpsertUser")
+	defer span.End()
+
+	if err := s.validateAttributes(params.Attributes); err != nil {
+		return entities.User{}, false, err
+	}
+
+	params.Email = entities.NormalizeEmail(params.Email)
+
+	user, created, err := s.userRepo.Upsert(ctx, externalID, params)
+	if err != nil {
+		return entities.User{}, false, errors.Wrap(err, "failed to upsert user in repository")
+	}
+
+	if created {
+		s.observer.UserCreated()
+	} else {
+		s.observer.UserUpdated()
+	}
+
+	return user, created, nil
+}
+
+func (s *Service) DeleteUser(ctx context.Context, id int64, cause entities.StatusChangeCause) error {
+	ctx, span := startSpan(ctx, "Service.DeleteUser")
+	defer span.End()
+
 	_, err := s.userRepo.Get(ctx, id)
 	if err != nil {
 		return errors.Wrap(err, "failed to get user from repository")
 	}
 
-	err = s.userRepo.Delete(ctx, id)
+	err = s.userRepo.Delete(ctx, id, cause)
 	if err != nil {
 		return errors.Wrap(err, "failed to delete user from repository")
 	}
 
+	s.observer.UserDeleted()
+
 	return nil
 }
+
+// ListUsers returns a page of users, deleted ones are included only if requested.
+func (s *Service) ListUsers(ctx context.Context, params entities.ListUsersParams) ([]entities.User, error) {
+	ctx, span := startSpan(ctx, "Service.ListUsers")
+	defer span.End()
+
+	filter, err := params.Attributes.Normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := entities.ValidateAttributeFilter(filter); err != nil {
+		return nil, err
+	}
+
+	params.Attributes = filter
+
+	users, err := s.userRepo.List(ctx, params)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list users in repository")
+	}
+
+	return users, nil
+}
+
+// RestoreUser undoes the deletion of a user.
+func (s *Service) RestoreUser(ctx context.Context, id int64, cause entities.StatusChangeCause) (entities.User, error) {
+	ctx, span := startSpan(ctx, "Service.RestoreUser")
+	defer span.End()
+
+	user, err := s.userRepo.Get(ctx, id)
+	if err != nil {
+		return entities.User{}, errors.Wrap(err, "failed to get user from repository")
+	}
+
+	if !user.IsDeleted() {
+		return user, nil
+	}
+
+	user, err = s.userRepo.Restore(ctx, id, cause)
+	if err != nil {
+		return entities.User{}, errors.Wrap(err, "failed to restore user in repository")
+	}
+
+	return user, nil
+}
+
+func (s *Service) validateAttributes(attributes entities.Attributes) error {
+	if s.attributes == nil {
+		return nil
+	}
+
+	return s.attributes.ValidateAttributes(attributes)
+}
+
+type noopObserver struct{}
+
+func (noopObserver) UserCreated() {}
+
+func (noopObserver) UserUpdated() {}
+
+func (noopObserver) UserDeleted() {}
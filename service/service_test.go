@@ -0,0 +1,252 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torwig/user-service/adapters/repository"
+	"github.com/torwig/user-service/entities"
+	"github.com/torwig/user-service/service"
+)
+
+type countingObserver struct {
+	created, updated, deleted int
+}
+
+func (o *countingObserver) UserCreated() { o.created++ }
+
+func (o *countingObserver) UserUpdated() { o.updated++ }
+
+func (o *countingObserver) UserDeleted() { o.deleted++ }
+
+// touchCountingRepository counts the updates of the last use of API keys.
+type touchCountingRepository struct {
+	*repository.InMemoryRepository
+	touches int
+}
+
+func (r *touchCountingRepository) TouchAPIKey(ctx context.Context, id int64, usedAt time.Time) error {
+	r.touches++
+
+	return r.InMemoryRepository.TouchAPIKey(ctx, id, usedAt)
+}
+
+func TestService_DeletedUser(t *testing.T) {
+	ctx := context.Background()
+	observer := &countingObserver{}
+	svc := service.New(repository.NewInMemoryRepository(), service.WithObserver(observer))
+
+	user, err := svc.CreateUser(ctx, entities.CreateUserParams{
+		FirstName: "John", LastName: "Wick", PhoneNumber: "+1234567890", Address: "New York",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, svc.DeleteUser(ctx, user.ID, entities.StatusChangeCause{}))
+
+	_, err = svc.GetUser(ctx, user.ID)
+	require.ErrorIs(t, err, entities.ErrUserNotFound)
+
+	address := "Boston"
+	_, err = svc.UpdateUser(ctx, user.ID, entities.UpdateUserParams{Address: &address})
+	require.ErrorIs(t, err, entities.ErrUserNotFound)
+
+	restoredUser, err := svc.RestoreUser(ctx, user.ID, entities.StatusChangeCause{})
+	require.NoError(t, err)
+	assert.False(t, restoredUser.IsDeleted())
+
+	_, err = svc.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+
+	assert.Equal(t, countingObserver{created: 1, deleted: 1}, *observer)
+}
+
+func TestService_DeleteUser_NotFound(t *testing.T) {
+	svc := service.New(repository.NewInMemoryRepository())
+
+	err := svc.DeleteUser(context.Background(), 42, entities.StatusChangeCause{})
+	require.ErrorIs(t, err, entities.ErrUserNotFound)
+}
+
+type lastCodeSender struct {
+	code string
+}
+
+func (s *lastCodeSender) SendPhoneVerification(_ context.Context, _, code string) error {
+	s.code = code
+
+	return nil
+}
+
+func TestService_PhoneVerification(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryRepository()
+	sender := &lastCodeSender{}
+	svc := service.New(repo, service.WithPhoneVerification(service.PhoneVerificationConfig{
+		Secret: []byte("secret"), ResendInterval: time.Hour,
+	}, repo, sender))
+
+	user, err := svc.CreateUser(ctx, entities.CreateUserParams{
+		FirstName: "John", LastName: "Wick", PhoneNumber: "+1234567890", Address: "New York",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, svc.SendPhoneVerification(ctx, user.ID))
+	code := sender.code
+
+	verification, err := repo.GetPhoneVerification(ctx, user.ID)
+	require.NoError(t, err)
+	assert.NotContains(t, verification.CodeHash, code, "only a hash of the code may be stored")
+
+	err = svc.SendPhoneVerification(ctx, user.ID)
+	require.ErrorIs(t, err, entities.ErrVerificationCodeRecentlySent)
+
+	// a new number gets a code right away
+	phoneNumber := "+1234567899"
+	_, err = svc.UpdateUser(ctx, user.ID, entities.UpdateUserParams{PhoneNumber: &phoneNumber})
+	require.NoError(t, err)
+	require.NoError(t, svc.SendPhoneVerification(ctx, user.ID))
+
+	_, err = svc.VerifyPhone(ctx, user.ID, code)
+	require.ErrorIs(t, err, entities.ErrInvalidVerificationCode)
+
+	verifiedUser, err := svc.VerifyPhone(ctx, user.ID, sender.code)
+	require.NoError(t, err)
+	assert.True(t, verifiedUser.IsPhoneVerified())
+
+	_, err = repo.GetPhoneVerification(ctx, user.ID)
+	require.ErrorIs(t, err, entities.ErrPhoneVerificationNotFound, "the code must be used up")
+}
+
+func TestService_ChangeUserStatus(t *testing.T) {
+	ctx := context.Background()
+	svc := service.New(repository.NewInMemoryRepository())
+
+	_, err := svc.CreateUser(ctx, entities.CreateUserParams{
+		FirstName: "John", LastName: "Wick", PhoneNumber: "+1234567890", Address: "New York",
+		Status: entities.UserStatusSuspended,
+	})
+	require.ErrorIs(t, err, entities.ErrInvalidStatus)
+
+	user, err := svc.CreateUser(ctx, entities.CreateUserParams{
+		FirstName: "John", LastName: "Wick", PhoneNumber: "+1234567890", Address: "New York",
+	})
+	require.NoError(t, err)
+	require.Equal(t, entities.UserStatusActive, user.Status)
+
+	_, err = svc.ChangeUserStatus(ctx, user.ID, entities.ChangeUserStatusParams{Status: entities.UserStatusDeleted})
+	require.ErrorIs(t, err, entities.ErrStatusTransition)
+
+	_, err = svc.ChangeUserStatus(ctx, user.ID, entities.ChangeUserStatusParams{Status: entities.UserStatusLocked})
+	require.NoError(t, err)
+	require.ErrorIs(t, svc.CheckUserStatus(ctx, user.ID), entities.ErrUserSuspended)
+
+	_, err = svc.ChangeUserStatus(ctx, user.ID, entities.ChangeUserStatusParams{Status: entities.UserStatusSuspended})
+	require.ErrorIs(t, err, entities.ErrStatusTransition, "a locked user has to be activated first")
+
+	_, err = svc.ChangeUserStatus(ctx, user.ID, entities.ChangeUserStatusParams{Status: entities.UserStatusActive})
+	require.NoError(t, err)
+	require.NoError(t, svc.CheckUserStatus(ctx, user.ID))
+
+	require.NoError(t, svc.DeleteUser(ctx, user.ID, entities.StatusChangeCause{}))
+	require.ErrorIs(t, svc.CheckUserStatus(ctx, user.ID), entities.ErrUserSuspended, "a deleted user can't authenticate")
+
+	_, err = svc.ChangeUserStatus(ctx, user.ID, entities.ChangeUserStatusParams{Status: entities.UserStatusSuspended})
+	require.ErrorIs(t, err, entities.ErrUserNotFound)
+}
+
+func TestAPIKeyService_AuthenticateAPIKey(t *testing.T) {
+	ctx := context.Background()
+	repo := &touchCountingRepository{InMemoryRepository: repository.NewInMemoryRepository()}
+	svc := service.New(repo)
+	keySvc := service.NewAPIKeyService(repo, repo)
+
+	newUser := func() entities.User {
+		user, err := svc.CreateUser(ctx, entities.CreateUserParams{
+			FirstName: "John", LastName: "Wick", PhoneNumber: "+1234567890", Address: "New York",
+		})
+		require.NoError(t, err)
+
+		return user
+	}
+
+	newKey := func(userID int64, expiresAt *time.Time) (entities.APIKey, string) {
+		key, plaintext, err := keySvc.CreateAPIKey(ctx, entities.CreateAPIKeyParams{
+			UserID: userID, Name: "sync", Scopes: []string{string(entities.APIKeyScopeViewUsers)}, ExpiresAt: expiresAt,
+		})
+		require.NoError(t, err)
+
+		return key, plaintext
+	}
+
+	user := newUser()
+	_, plaintext := newKey(user.ID, nil)
+
+	t.Run("Valid key", func(t *testing.T) {
+		au, err := keySvc.AuthenticateAPIKey(ctx, plaintext)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, au.ID())
+		assert.True(t, au.CanViewUser(user.ID+1))
+		assert.False(t, au.CanUpdateUser(user.ID+1))
+		assert.False(t, au.CanManageAPIKeys(user.ID), "a key must not manage keys")
+	})
+
+	t.Run("Last use is throttled", func(t *testing.T) {
+		_, err := keySvc.AuthenticateAPIKey(ctx, plaintext)
+		require.NoError(t, err)
+		assert.Equal(t, 1, repo.touches)
+	})
+
+	t.Run("Malformed key", func(t *testing.T) {
+		prefix := strings.Split(plaintext, "_")[1]
+
+		for _, malformed := range []string{"", "usk", "usk_" + prefix, "key_" + strings.TrimPrefix(plaintext, "usk_")} {
+			_, err := keySvc.AuthenticateAPIKey(ctx, malformed)
+			require.ErrorIs(t, err, entities.ErrInvalidAPIKey, malformed)
+		}
+	})
+
+	t.Run("Wrong secret", func(t *testing.T) {
+		_, err := keySvc.AuthenticateAPIKey(ctx, plaintext+"x")
+		require.ErrorIs(t, err, entities.ErrInvalidAPIKey)
+	})
+
+	t.Run("Expired key", func(t *testing.T) {
+		expiresAt := time.Now().Add(-time.Minute)
+		_, expired := newKey(user.ID, &expiresAt)
+
+		_, err := keySvc.AuthenticateAPIKey(ctx, expired)
+		require.ErrorIs(t, err, entities.ErrAPIKeyExpired)
+	})
+
+	t.Run("Revoked key", func(t *testing.T) {
+		revokedKey, revoked := newKey(user.ID, nil)
+		require.NoError(t, keySvc.RevokeAPIKey(ctx, revokedKey.ID))
+
+		_, err := keySvc.AuthenticateAPIKey(ctx, revoked)
+		require.ErrorIs(t, err, entities.ErrAPIKeyRevoked)
+	})
+
+	t.Run("Suspended owner", func(t *testing.T) {
+		owner := newUser()
+		_, ownerKey := newKey(owner.ID, nil)
+
+		_, err := svc.ChangeUserStatus(ctx, owner.ID, entities.ChangeUserStatusParams{Status: entities.UserStatusSuspended})
+		require.NoError(t, err)
+
+		_, err = keySvc.AuthenticateAPIKey(ctx, ownerKey)
+		require.ErrorIs(t, err, entities.ErrUserSuspended)
+	})
+
+	t.Run("Deleted owner", func(t *testing.T) {
+		owner := newUser()
+		_, ownerKey := newKey(owner.ID, nil)
+		require.NoError(t, svc.DeleteUser(ctx, owner.ID, entities.StatusChangeCause{}))
+
+		_, err := keySvc.AuthenticateAPIKey(ctx, ownerKey)
+		require.ErrorIs(t, err, entities.ErrUserSuspended)
+	})
+}
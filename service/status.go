@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+)
+
+// ChangeUserStatus moves the user to another status of its lifecycle, see entities.UserStatus.CanChangeTo.
+// The change is recorded along with its reason and actor.
+func (s *Service) ChangeUserStatus(
+	ctx context.Context,
+	id int64,
+	params entities.ChangeUserStatusParams,
+) (entities.User, error) {
+	ctx, span := startSpan(ctx, "Service.ChangeUserStatus")
+	defer span.End()
+
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return entities.User{}, err
+	}
+
+	if !user.Status.CanChangeTo(params.Status) {
+		return entities.User{}, errors.Wrapf(entities.ErrStatusTransition, "%s to %s", user.Status, params.Status)
+	}
+
+	user, err = s.userRepo.ChangeStatus(ctx, id, user.Status, params)
+	if err != nil {
+		return entities.User{}, errors.Wrap(err, "failed to change user status in repository")
+	}
+
+	s.observer.UserUpdated()
+
+	return user, nil
+}
+
+// CheckUserStatus returns ErrUserSuspended if the user may not act with their own credentials, i.e. if the user
+// is suspended, locked or deleted. Callers that aren't users of the service, such as other services, pass the check.
+func (s *Service) CheckUserStatus(ctx context.Context, id int64) error {
+	ctx, span := startSpan(ctx, "Service.CheckUserStatus")
+	defer span.End()
+
+	user, err := s.userRepo.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, entities.ErrUserNotFound) {
+			return nil
+		}
+
+		return errors.Wrap(err, "failed to get user from repository")
+	}
+
+	if !user.Status.CanAuthenticate() {
+		return entities.ErrUserSuspended
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/torwig/user-service/entities"
+	"github.com/torwig/user-service/log"
+)
+
+// listening is retried after a delay doubled on every failure in a row
+const (
+	userChangesListenMinRetryDelay = time.Second
+	userChangesListenMaxRetryDelay = time.Minute
+)
+
+type UserChangeRepository interface {
+	UserChangesAfter(ctx context.Context, afterID int64, limit uint64) ([]entities.UserChange, error)
+	LatestUserChangeID(ctx context.Context) (int64, error)
+	// ListenUserChanges calls notify on every change until ctx is done or listening fails.
+	ListenUserChanges(ctx context.Context, notify func(changeID int64)) error
+}
+
+// UserChangeFeed wakes up the watchers of user changes. Watchers read the changes themselves,
+// each from where it has stopped, so a slow watcher doesn't hold back the others.
+type UserChangeFeed struct {
+	repo UserChangeRepository
+
+	mu       sync.Mutex
+	watchers map[chan struct{}]struct{}
+	stopped  bool
+}
+
+func NewUserChangeFeed(repo UserChangeRepository) *UserChangeFeed {
+	return &UserChangeFeed{repo: repo, watchers: make(map[chan struct{}]struct{})}
+}
+
+// Run listens to the changes until ctx is done, then the channels of all the watchers are closed.
+func (f *UserChangeFeed) Run(ctx context.Context) {
+	defer f.stop()
+
+	delay := userChangesListenMinRetryDelay
+
+	for {
+		listened := false
+
+		err := f.repo.ListenUserChanges(ctx, func(int64) {
+			listened = true
+			f.wake()
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		if listened {
+			delay = userChangesListenMinRetryDelay
+		}
+
+		log.FromContext(ctx).Warnf("stopped listening to user changes, retrying in %s: %s", delay, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		delay = min(2*delay, userChangesListenMaxRetryDelay)
+	}
+}
+
+// Watch returns a channel that receives a value whenever there may be new changes, values are coalesced
+// while nobody reads them. The channel is closed when the feed stops. Unwatch has to be called when done.
+func (f *UserChangeFeed) Watch() (<-chan struct{}, func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ch := make(chan struct{}, 1)
+
+	if f.stopped {
+		close(ch)
+
+		return ch, func() {}
+	}
+
+	f.watchers[ch] = struct{}{}
+
+	return ch, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		if _, ok := f.watchers[ch]; ok {
+			delete(f.watchers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (f *UserChangeFeed) UserChangesAfter(
+	ctx context.Context,
+	afterID int64,
+	limit uint64,
+) ([]entities.UserChange, error) {
+	changes, err := f.repo.UserChangesAfter(ctx, afterID, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list user changes in repository")
+	}
+
+	return changes, nil
+}
+
+func (f *UserChangeFeed) LatestUserChangeID(ctx context.Context) (int64, error) {
+	id, err := f.repo.LatestUserChangeID(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get latest user change from repository")
+	}
+
+	return id, nil
+}
+
+func (f *UserChangeFeed) wake() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for ch := range f.watchers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (f *UserChangeFeed) stop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.stopped = true
+
+	for ch := range f.watchers {
+		delete(f.watchers, ch)
+		close(ch)
+	}
+}